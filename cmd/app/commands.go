@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/umputun/go-flags"
+	"makarov.dev/bot/internal/app"
+	"makarov.dev/bot/internal/integration/twitch"
+	"makarov.dev/bot/internal/notify"
+	"makarov.dev/bot/internal/storage/archive"
+	"os"
+	"slices"
+)
+
+func (r *runner) addCommands(parser *flags.Parser) error {
+	commands := []struct {
+		name        string
+		description string
+		data        any
+	}{
+		{"serve", "Start background jobs and web server (default)", &serveCommand{r: r}},
+		{"migrate", "Apply storage migrations and exit", &migrateCommand{r: r}},
+		{"backfill", "Store items missed while the bot was down", &backfillCommand{LostFilm: backfillLostFilmCommand{r: r}}},
+		{"export", "Export all collections to a tar.gz archive", &exportCommand{r: r}},
+		{"import", "Import collections from an export archive into empty storage", &importCommand{r: r}},
+		{"backup", "Write a full backup with collections and stored files to a tar.gz archive", &backupCommand{r: r}},
+		{"restore", "Restore a backup or export archive into empty storage", &restoreCommand{r: r}},
+		{"favorites", "Manage Kinozal favorites", &favoritesCommand{
+			Add:    favoritesAddCommand{r: r},
+			Remove: favoritesRemoveCommand{r: r},
+			List:   favoritesListCommand{r: r},
+		}},
+		{"notify", "Notifier tools", &notifyCommand{Test: notifyTestCommand{r: r}}},
+	}
+	for _, c := range commands {
+		if _, err := parser.AddCommand(c.name, c.description, "", c.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type migrateCommand struct {
+	r *runner
+}
+
+func (c *migrateCommand) Execute([]string) error {
+	a, err := app.New(c.r.cfg, c.r.logger)
+	if err != nil {
+		return fmt.Errorf("init application %w", err)
+	}
+	return a.Migrate(c.r.ctx)
+}
+
+type backfillCommand struct {
+	LostFilm backfillLostFilmCommand `command:"lostfilm" description:"Store LostFilm episodes from new episodes pages"`
+}
+
+type backfillLostFilmCommand struct {
+	r     *runner
+	Since string `long:"since" required:"true" description:"Earliest episode date in 2006-01-02 or RFC3339 format"`
+}
+
+func (c *backfillLostFilmCommand) Execute([]string) error {
+	since, err := twitch.ParseExportTime(c.Since)
+	if err != nil {
+		return fmt.Errorf("wrong since %s %w", c.Since, err)
+	}
+	a, err := c.r.newApp()
+	if err != nil {
+		return err
+	}
+	processed, err := a.LostFilm.Backfill(c.r.ctx, since)
+	a.Logger.Infof("Backfilled %d LostFilm episodes since %s", processed, since.Format("2006-01-02"))
+	return err
+}
+
+type fileArgs struct {
+	File string `positional-arg-name:"file" description:"Archive path"`
+}
+
+type exportCommand struct {
+	r    *runner
+	Args fileArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *exportCommand) Execute([]string) error {
+	a, err := c.r.newApp()
+	if err != nil {
+		return err
+	}
+	return exportArchive(c.r.ctx, a, c.Args.File, archive.Options{})
+}
+
+// exportArchive пишет архив в файл и удаляет недописанный файл при ошибке
+func exportArchive(ctx context.Context, a *app.App, path string, opts archive.Options) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = archive.Export(ctx, a.Storage, f, opts)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	a.Logger.Infof("Exported storage to %s", path)
+	return nil
+}
+
+type importCommand struct {
+	r    *runner
+	Args fileArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *importCommand) Execute([]string) error {
+	a, err := c.r.newApp()
+	if err != nil {
+		return err
+	}
+	return importArchive(c.r.ctx, a, c.Args.File)
+}
+
+func importArchive(ctx context.Context, a *app.App, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	counts, err := archive.Import(ctx, a.Storage, f)
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		a.Logger.Infof("Imported %d records of %s", counts[name], name)
+	}
+	return err
+}
+
+type backupCommand struct {
+	r    *runner
+	Args fileArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *backupCommand) Execute([]string) error {
+	a, err := c.r.newApp()
+	if err != nil {
+		return err
+	}
+	return exportArchive(c.r.ctx, a, c.Args.File, archive.Options{Files: true})
+}
+
+type restoreCommand struct {
+	r    *runner
+	Args fileArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *restoreCommand) Execute([]string) error {
+	a, err := c.r.newApp()
+	if err != nil {
+		return err
+	}
+	return importArchive(c.r.ctx, a, c.Args.File)
+}
+
+type favoritesCommand struct {
+	Add    favoritesAddCommand    `command:"add" description:"Add Kinozal favorite"`
+	Remove favoritesRemoveCommand `command:"remove" description:"Remove Kinozal favorite"`
+	List   favoritesListCommand   `command:"list" description:"List Kinozal favorites"`
+}
+
+type favoriteArgs struct {
+	Id int64 `positional-arg-name:"id" description:"Kinozal detail id"`
+}
+
+type favoritesAddCommand struct {
+	r    *runner
+	Args favoriteArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *favoritesAddCommand) Execute([]string) error {
+	a, err := c.r.newApp()
+	if err != nil {
+		return err
+	}
+	return a.Kinozal.InsertFavorite(c.Args.Id)
+}
+
+type favoritesRemoveCommand struct {
+	r    *runner
+	Args favoriteArgs `positional-args:"yes" required:"yes"`
+}
+
+func (c *favoritesRemoveCommand) Execute([]string) error {
+	a, err := c.r.newApp()
+	if err != nil {
+		return err
+	}
+	return a.Kinozal.DeleteFavorite(c.Args.Id)
+}
+
+type favoritesListCommand struct {
+	r *runner
+}
+
+func (c *favoritesListCommand) Execute([]string) error {
+	a, err := c.r.newApp()
+	if err != nil {
+		return err
+	}
+	favorites, err := a.Kinozal.ListFavorites()
+	if err != nil {
+		return err
+	}
+	for _, f := range favorites {
+		fmt.Println(f.DetailId)
+	}
+	return nil
+}
+
+type notifyCommand struct {
+	Test notifyTestCommand `command:"test" description:"Send a test message through each notifier of the target"`
+}
+
+type notifyTestCommand struct {
+	r    *runner
+	Args struct {
+		Target string `positional-arg-name:"target" description:"Notifiers to test: lostfilm, kinozal, twitch or all"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *notifyTestCommand) Execute([]string) error {
+	if !slices.Contains([]string{"lostfilm", "kinozal", "twitch", "all"}, c.Args.Target) {
+		return fmt.Errorf("unknown notify target %s", c.Args.Target)
+	}
+	a, err := c.r.newApp()
+	if err != nil {
+		return err
+	}
+	if a.Config.Telegram.Enable {
+		if err = a.Telegram.Connect(); err != nil {
+			return fmt.Errorf("connect telegram %w", err)
+		}
+	}
+	targets := a.NotifyTargets()
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		if c.Args.Target == "all" || c.Args.Target == name {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	failed := 0
+	sent := 0
+	for _, name := range names {
+		for _, notifier := range targets[name] {
+			err = notifier.Notify(c.r.ctx, notify.Notification{Text: fmt.Sprintf("Тестовое сообщение (%s)", name)})
+			if err != nil {
+				a.Logger.Errorf("Error while send test message to %s %s %s", name, notifier.Name(), err.Error())
+				failed++
+				continue
+			}
+			a.Logger.Infof("Test message sent to %s %s", name, notifier.Name())
+			sent++
+		}
+	}
+	if sent+failed == 0 {
+		return fmt.Errorf("no notifiers configured for %s", c.Args.Target)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d test messages failed", failed, sent+failed)
+	}
+	return nil
+}
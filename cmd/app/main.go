@@ -2,26 +2,201 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	log "github.com/sirupsen/logrus"
+	"github.com/umputun/go-flags"
+	"makarov.dev/bot/internal/app"
 	"makarov.dev/bot/internal/background"
 	"makarov.dev/bot/internal/config"
 	"makarov.dev/bot/internal/delivery/web"
+	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
+// @securityDefinitions.apikey	AdminToken
+// @in							header
+// @name						Authorization
+// @description				Bearer <admin token>
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	logger := log.New()
-	config.Init(logger)
+	cfg := &config.Config{}
+	r := &runner{ctx: ctx, cfg: cfg, logger: logger}
 
-	background.StartAllBackgroundJobs(ctx)
-	go web.StartWeb(ctx)
+	parser, err := r.newParser(cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	parser.CommandHandler = r.handle
+	if err = r.loadConfigFile(parser); err != nil {
+		logger.Fatal(err)
+	}
+
+	if _, err = parser.Parse(); err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			fmt.Println(err)
+			return
+		}
+		logger.Fatal(err)
+	}
+}
+
+// runner общее состояние подкоманд
+type runner struct {
+	ctx    context.Context
+	cfg    *config.Config
+	logger *log.Logger
+	// fileEnv значения файла конфигурации, выставленные в окружение
+	fileEnv config.FileEnv
+}
+
+func (r *runner) newParser(cfg *config.Config) (*flags.Parser, error) {
+	parser := flags.NewParser(cfg, flags.HelpFlag|flags.PassDoubleDash)
+	// без подкоманды приложение запускается как serve
+	parser.SubcommandsOptional = true
+	return parser, r.addCommands(parser)
+}
+
+// loadConfigFile читает секреты *_FILE и находит путь к файлу конфигурации до основного разбора флагов,
+// так как значения файлов должны попасть в окружение раньше, чем go-flags прочитает переменные
+func (r *runner) loadConfigFile(parser *flags.Parser) error {
+	r.fileEnv.Reset()
+	secrets := envKeys(parser.Groups(), func(option *flags.Option) bool { return option.Field().Tag.Get("secret") == "true" })
+	if err := r.fileEnv.LoadSecrets(secrets); err != nil {
+		return err
+	}
+	opts := struct {
+		ConfigFile string `long:"config" env:"CONFIG_FILE"`
+	}{}
+	if _, err := flags.NewParser(&opts, flags.IgnoreUnknown).Parse(); err != nil {
+		return err
+	}
+	if opts.ConfigFile == "" {
+		return nil
+	}
+	return r.fileEnv.Load(opts.ConfigFile, envKeys(parser.Groups(), func(*flags.Option) bool { return true }))
+}
+
+// envKeys переменные окружения опций, для которых match вернул true
+func envKeys(groups []*flags.Group, match func(option *flags.Option) bool) []string {
+	keys := make([]string, 0)
+	for _, g := range groups {
+		for _, option := range g.Options() {
+			if key := option.EnvKeyWithNamespace(); key != "" && match(option) {
+				keys = append(keys, key)
+			}
+		}
+		keys = append(keys, envKeys(g.Groups(), match)...)
+	}
+	return keys
+}
+
+// reloadConfig заново читает файл, окружение и флаги запуска в новую конфигурацию
+func (r *runner) reloadConfig() (*config.Config, error) {
+	cfg := &config.Config{}
+	parser, err := r.newParser(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// подкоманда уже выполняется, разбор нужен только ради значений
+	parser.CommandHandler = func(flags.Commander, []string) error { return nil }
+	if err = r.loadConfigFile(parser); err != nil {
+		return nil, err
+	}
+	if _, err = parser.Parse(); err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func (r *runner) handle(cmd flags.Commander, args []string) error {
+	if cmd == nil && len(args) > 0 {
+		return fmt.Errorf("unknown command %s", args[0])
+	}
+	if err := r.cfg.Validate(); err != nil {
+		return err
+	}
+	config.Init(r.cfg, r.logger)
+	shutdownTracing, err := config.InitTracing(r.cfg.Tracing, r.logger)
+	if err != nil {
+		return fmt.Errorf("init tracing %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			r.logger.Errorf("Error while flush traces %s", err.Error())
+		}
+	}()
+	if cmd == nil {
+		cmd = &serveCommand{r: r}
+	}
+	return cmd.Execute(args)
+}
+
+// newApp собирает приложение и применяет миграции, если они не отключены
+func (r *runner) newApp() (*app.App, error) {
+	a, err := app.New(r.cfg, r.logger)
+	if err != nil {
+		return nil, fmt.Errorf("init application %w", err)
+	}
+	if !r.cfg.Database.SkipMigrations {
+		if err = a.Migrate(r.ctx); err != nil {
+			return nil, fmt.Errorf("migrate storage %w", err)
+		}
+	}
+	// без таблицы сессий, например с --skip-migrations, работают cookie из конфигурации
+	if err = a.Credentials.Load(r.ctx); err != nil {
+		a.Logger.Errorf("Error while load tracker credentials %s", err.Error())
+	}
+	return a, nil
+}
+
+// shutdownTimeout сколько ждать остановки фоновых задач после сигнала завершения
+const shutdownTimeout = 30 * time.Second
+
+type serveCommand struct {
+	r *runner
+}
+
+func (c *serveCommand) Execute([]string) error {
+	r := c.r
+	a, err := r.newApp()
+	if err != nil {
+		return err
+	}
+
+	jobs := background.StartAllBackgroundJobs(r.ctx, a)
+	go web.StartWeb(r.ctx, a)
 
 	log.Infof("Application started")
 
-	<-ctx.Done()
-	log.Infof("Gracefully shutdown application")
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-r.ctx.Done():
+			log.Infof("Gracefully shutdown application")
+			if !jobs.Wait(shutdownTimeout) {
+				log.Warnf("Background jobs did not stop in %s", shutdownTimeout)
+			}
+			return nil
+		case <-hup:
+			cfg, err := r.reloadConfig()
+			if err != nil {
+				a.Logger.Errorf("Error while reload configuration, keep previous %s", err.Error())
+				continue
+			}
+			if err = a.Reload(cfg); err != nil {
+				a.Logger.Errorf("Error while reload configuration %s", err.Error())
+			}
+		}
+	}
 }
@@ -0,0 +1,311 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/cache"
+	"makarov.dev/bot/internal/config"
+	"makarov.dev/bot/internal/health"
+	"makarov.dev/bot/internal/integration/credential"
+	"makarov.dev/bot/internal/integration/file"
+	"makarov.dev/bot/internal/integration/kinozal"
+	"makarov.dev/bot/internal/integration/lostfilm"
+	"makarov.dev/bot/internal/integration/telegram"
+	"makarov.dev/bot/internal/integration/twitch"
+	"makarov.dev/bot/internal/jobs"
+	"makarov.dev/bot/internal/metrics"
+	"makarov.dev/bot/internal/notify"
+	"makarov.dev/bot/internal/storage"
+	"makarov.dev/bot/internal/storage/filesystem"
+	"makarov.dev/bot/internal/storage/memory"
+	"makarov.dev/bot/internal/storage/mongodb"
+	"makarov.dev/bot/internal/storage/sqlstore"
+	"makarov.dev/bot/pkg/helix"
+	kinozalClient "makarov.dev/bot/pkg/kinozal"
+	lfClient "makarov.dev/bot/pkg/lostfilm"
+	"makarov.dev/bot/pkg/proxypool"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// App контейнер зависимостей приложения. Собирается один раз в main и передается в фоновые задачи и контроллеры
+type App struct {
+	Config     *config.Config
+	Logger     *log.Logger
+	HttpClient *http.Client
+	// Transport соединения клиентов с долгими запросами без ограничений HttpClient, через Proxies если они включены
+	Transport http.RoundTripper
+	Storage   *storage.Storage
+	Cache     cache.Cache
+
+	Telegram *telegram.Bot
+	Files    *file.Service
+	LostFilm *lostfilm.Service
+	Kinozal  *kinozal.Service
+	Twitch   *twitch.Service
+	// Credentials сессии трекеров, сохраненные в базе
+	Credentials *credential.Service
+	// Health замеры скраперов трекеров и оповещения о смене верстки
+	Health *health.Monitor
+	// Jobs фоновые задачи и их состояние
+	Jobs *jobs.Supervisor
+
+	LostFilmClient *lfClient.Client
+	KinozalClient  *kinozalClient.Client
+	// HelixClient клиент Twitch API, nil если не задан client id
+	HelixClient *helix.Client
+	// Proxies пул прокси исходящих соединений, nil если прокси выключены
+	Proxies *proxypool.Pool
+
+	// Started время запуска, от него считается свежесть замеров до первого удачного
+	Started time.Time
+
+	// current конфигурация после последней перезагрузки, nil до первой
+	current atomic.Pointer[config.Config]
+}
+
+func New(cfg *config.Config, logger *log.Logger) (*App, error) {
+	a := &App{
+		Config:  cfg,
+		Logger:  logger,
+		Cache:   cache.NopCache{},
+		Started: time.Now(),
+	}
+	if err := a.initHttp(); err != nil {
+		return nil, err
+	}
+
+	store, err := newStorage(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	a.Storage = store
+	if cfg.Redis.Enable {
+		a.Cache = &cache.RedisCache{Client: config.NewRedis(cfg.Redis)}
+	}
+
+	a.Telegram = telegram.NewBot(cfg.Telegram, a.Transport, logger)
+	a.Files = &file.Service{Store: store.Files, Downloads: store.Downloads, Logger: logger}
+	a.Health = &health.Monitor{Notifiers: a.adminNotifiers(), Logger: logger}
+	a.Jobs = &jobs.Supervisor{Logger: logger}
+
+	a.LostFilmClient = &lfClient.Client{
+		Config: lfClient.ClientConfig{
+			HttpClient:  a.HttpClient,
+			MainPageUrl: cfg.LostFilm.Domain,
+			Cookie:      http.Cookie{Name: cfg.LostFilm.CookieName, Value: cfg.LostFilm.CookieVal},
+			Username:    cfg.LostFilm.Username,
+			Password:    cfg.LostFilm.Password,
+			// сервис сессий создается позже клиентов, поэтому берется из a в момент входа
+			OnLogin: func(cookie http.Cookie) {
+				a.saveSession(credential.LostFilm, cookie.Name, cookie.Value)
+			},
+			OnScrape: func(s lfClient.Scrape) {
+				metrics.Scrape("lostfilm", s.Page, s.Status, s.Latency)
+				a.Health.Record(health.Sample{
+					Provider: "lostfilm", Page: s.Page, Url: s.Url, Status: s.Status,
+					Latency: s.Latency, Items: s.Items, Missing: s.Missing, Err: errorText(s.Err),
+				})
+			},
+		},
+		Logger: logger,
+	}
+	a.LostFilm = &lostfilm.Service{
+		Config:     cfg.LostFilm,
+		WebDomain:  cfg.Web.Domain,
+		Client:     a.LostFilmClient,
+		Items:      store.LostFilmItems,
+		Files:      a.Files,
+		Cache:      a.Cache,
+		HttpClient: a.HttpClient,
+		Notifiers:  a.lostFilmNotifiers(),
+		Logger:     logger,
+	}
+
+	a.KinozalClient = &kinozalClient.Client{
+		Config: kinozalClient.ClientConfig{
+			HttpClient:  a.HttpClient,
+			MainPageUrl: cfg.Kinozal.Domain,
+			Cookie:      cfg.Kinozal.Cookie,
+			Username:    cfg.Kinozal.Username,
+			Password:    cfg.Kinozal.Password,
+			OnLogin: func(cookie string) {
+				a.saveSession(credential.Kinozal, "", cookie)
+			},
+			OnScrape: func(s kinozalClient.Scrape) {
+				metrics.Scrape("kinozal", s.Page, s.Status, s.Latency)
+				a.Health.Record(health.Sample{
+					Provider: "kinozal", Page: s.Page, Url: s.Url, Status: s.Status,
+					Latency: s.Latency, Items: s.Items, Missing: s.Missing, Err: errorText(s.Err),
+				})
+			},
+		},
+		Logger: logger,
+	}
+	a.Kinozal = &kinozal.Service{
+		Client:    a.KinozalClient,
+		Items:     store.KinozalItems,
+		Favorites: store.KinozalFavorites,
+		Files:     a.Files,
+		Cache:     a.Cache,
+		Notifiers: a.telegramNotifiers(cfg.Telegram.KinozalUpdateChannel),
+		Logger:    logger,
+	}
+
+	a.Credentials = &credential.Service{
+		Credentials:        store.Credentials,
+		LostFilmClient:     a.LostFilmClient,
+		KinozalClient:      a.KinozalClient,
+		LostFilmCookieName: cfg.LostFilm.CookieName,
+		Logger:             logger,
+	}
+
+	var twitchNotifier notify.Notifier
+	if cfg.Telegram.Enable && cfg.Twitch.AlertChat != 0 {
+		twitchNotifier = &notify.TelegramNotifier{Sender: a.Telegram, ChatId: cfg.Twitch.AlertChat}
+	}
+	a.Twitch = twitch.NewService(cfg.Twitch, store, a.Files, twitchNotifier, logger)
+	if cfg.Twitch.ClientId != "" {
+		a.HelixClient = &helix.Client{
+			Config: helix.ClientConfig{
+				HttpClient:   a.HttpClient,
+				ClientId:     cfg.Twitch.ClientId,
+				ClientSecret: cfg.Twitch.ClientSecret,
+			},
+			Logger: logger,
+		}
+	}
+
+	return a, nil
+}
+
+// initHttp создает транспорт исходящих соединений, пул прокси и общий клиент
+func (a *App) initHttp() error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if a.Config.Proxy.Enable {
+		pool, err := config.NewProxyPool(a.Config, a.Logger)
+		if err != nil {
+			return fmt.Errorf("create proxy pool %w", err)
+		}
+		a.Proxies = pool
+		transport.DialContext = pool.DialContext
+		names := make([]string, 0, len(pool.Proxies))
+		for _, p := range pool.Proxies {
+			names = append(names, p.Name)
+		}
+		a.Logger.Infof("Proxies %s enabled", strings.Join(names, ", "))
+	}
+	a.Transport = transport
+	a.HttpClient = config.NewHttpClient(a.Config.Http, transport, a.Logger)
+	a.HttpClient.Transport = config.TraceTransport(a.Config.Tracing, a.HttpClient.Transport)
+	return nil
+}
+
+// newStorage подключается к MongoDB или создает хранилище в памяти для локального запуска
+func newStorage(cfg *config.Config, logger *log.Logger) (*storage.Storage, error) {
+	if cfg.Storage == "memory" {
+		logger.Warn("Memory storage enabled, data will be lost on restart")
+		return memory.New(), nil
+	}
+	if cfg.Database.Driver != "mongo" {
+		return newSqlStorage(cfg.Database)
+	}
+	db, err := config.NewDatabase(cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("connect database %w", err)
+	}
+	bucket, err := config.NewBucket(db)
+	if err != nil {
+		return nil, fmt.Errorf("create gridfs bucket %w", err)
+	}
+	return mongodb.New(db, bucket), nil
+}
+
+func newSqlStorage(cfg config.DatabaseConfig) (*storage.Storage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	db, err := sqlstore.Open(ctx, cfg.Driver, cfg.Uri)
+	if err != nil {
+		return nil, fmt.Errorf("connect %s database %w", cfg.Driver, err)
+	}
+	files, err := filesystem.New(cfg.FilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("create files dir %w", err)
+	}
+	return sqlstore.New(db, files), nil
+}
+
+// Migrate применяет недостающие миграции хранилища
+func (a *App) Migrate(ctx context.Context) error {
+	applied, err := a.Storage.Migrator.Migrate(ctx)
+	for _, name := range applied {
+		a.Logger.Infof("Applied storage migration %s", name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		a.Logger.Debugf("Storage schema is up to date")
+	}
+	return nil
+}
+
+// saveSession сохраняет cookie, полученную клиентом трекера после входа, чтобы она пережила перезапуск
+func (a *App) saveSession(tracker string, name string, value string) {
+	if a.Credentials == nil {
+		return
+	}
+	err := a.Credentials.Save(&storage.TrackerCredential{Tracker: tracker, Name: name, Value: value})
+	if err != nil {
+		a.Logger.Errorf("Error while save %s session %s", tracker, err.Error())
+	}
+}
+
+// NotifyTargets каналы доставки по интеграциям, для которых они настроены
+func (a *App) NotifyTargets() map[string][]notify.Notifier {
+	targets := map[string][]notify.Notifier{
+		"lostfilm": a.LostFilm.Notifiers,
+		"kinozal":  a.Kinozal.Notifiers,
+	}
+	if a.Twitch.Notifier != nil {
+		targets["twitch"] = []notify.Notifier{a.Twitch.Notifier}
+	}
+	return targets
+}
+
+func (a *App) lostFilmNotifiers() []notify.Notifier {
+	notifiers := a.telegramNotifiers(a.Config.Telegram.LostFilmUpdateChannel)
+	if a.Config.Mastodon.Enable {
+		notifiers = append(notifiers, &notify.MastodonNotifier{
+			Client:   config.NewMastodonClient(a.Config.Mastodon, a.Transport),
+			Language: "ru",
+		})
+	}
+	return notifiers
+}
+
+// adminNotifiers личные чаты администраторов из cfg.Telegram.AdminIds
+func (a *App) adminNotifiers() []notify.Notifier {
+	notifiers := make([]notify.Notifier, 0, len(a.Config.Telegram.AdminIds))
+	for _, id := range a.Config.Telegram.AdminIds {
+		notifiers = append(notifiers, a.telegramNotifiers(int64(id))...)
+	}
+	return notifiers
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func (a *App) telegramNotifiers(chatId int64) []notify.Notifier {
+	if !a.Config.Telegram.Enable {
+		return nil
+	}
+	return []notify.Notifier{&notify.TelegramNotifier{Sender: a.Telegram, ChatId: chatId}}
+}
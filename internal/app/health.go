@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"makarov.dev/bot/internal/health"
+)
+
+// ReadinessChecks проверки зависимостей для /readyz. Хранилище и Redis критичны, без Telegram, Twitch
+// и свежих замеров трекеров бот работает частично
+func (a *App) ReadinessChecks() []health.Check {
+	cfg := a.Current()
+	checks := []health.Check{
+		{Name: "database", Critical: true, Run: a.Storage.Pinger.Ping},
+		{Name: "files", Critical: true, Run: a.Storage.Files.Ping},
+	}
+	if cfg.Redis.Enable {
+		checks = append(checks, health.Check{Name: "redis", Critical: true, Run: a.Cache.Ping})
+	}
+	if cfg.Telegram.Enable {
+		checks = append(checks, health.Check{Name: "telegram", Run: func(context.Context) error {
+			return a.Telegram.Ping()
+		}})
+	}
+	checks = append(checks, health.Check{Name: "twitch_irc", Run: func(context.Context) error {
+		if !a.Twitch.Connected() {
+			return errors.New("not connected")
+		}
+		return nil
+	}})
+	if cfg.LostFilm.Enable {
+		checks = append(checks, health.Check{
+			Name: "lostfilm_scrape",
+			Run:  a.Health.Fresh("lostfilm", a.Started, cfg.Health.ScrapeMaxAge),
+		})
+	}
+	if cfg.Kinozal.Enable {
+		checks = append(checks, health.Check{
+			Name: "kinozal_scrape",
+			Run:  a.Health.Fresh("kinozal", a.Started, cfg.Health.ScrapeMaxAge),
+		})
+	}
+	return checks
+}
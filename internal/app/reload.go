@@ -0,0 +1,98 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/config"
+	"net/http"
+	"reflect"
+	"slices"
+)
+
+// Current последняя загруженная конфигурация. Config остается конфигурацией запуска,
+// а перезагружаемые настройки читаются отсюда
+func (a *App) Current() *config.Config {
+	if cfg := a.current.Load(); cfg != nil {
+		return cfg
+	}
+	return a.Config
+}
+
+// Reload применяет без перезапуска уровень логирования, cookie трекеров, расписания опроса и каналы Twitch.
+// Об остальных изменениях только предупреждает, они вступят в силу после перезапуска
+func (a *App) Reload(cfg *config.Config) error {
+	logger := a.Logger
+	previous := a.Current()
+
+	level, err := log.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return fmt.Errorf("log level %w", err)
+	}
+	logger.SetLevel(level)
+
+	if cfg.LostFilm.CookieName != previous.LostFilm.CookieName || cfg.LostFilm.CookieVal != previous.LostFilm.CookieVal {
+		a.LostFilmClient.SetCookie(http.Cookie{Name: cfg.LostFilm.CookieName, Value: cfg.LostFilm.CookieVal})
+		logger.Infof("LostFilm cookie reloaded")
+	}
+	if cfg.Kinozal.Cookie != previous.Kinozal.Cookie {
+		a.KinozalClient.SetCookie(cfg.Kinozal.Cookie)
+		logger.Infof("Kinozal cookie reloaded")
+	}
+
+	errs := make([]error, 0)
+	if !slices.Equal(cfg.Twitch.Channels, previous.Twitch.Channels) {
+		if err = a.Twitch.SyncConfigChannels(previous.Twitch.Channels, cfg.Twitch.Channels); err != nil {
+			errs = append(errs, fmt.Errorf("sync twitch channels %w", err))
+		}
+	}
+	a.current.Store(cfg)
+
+	for _, section := range restartRequired(previous, cfg) {
+		logger.Warnf("Changes in %s settings require restart", section)
+	}
+	logger.Infof("Configuration reloaded")
+	return errors.Join(errs...)
+}
+
+// restartRequired секции, изменения которых не применяются перезагрузкой
+func restartRequired(previous *config.Config, cfg *config.Config) []string {
+	// из сравнения убираются поля, которые применяет Reload
+	oldLostFilm, lostFilm := previous.LostFilm, cfg.LostFilm
+	oldLostFilm.CookieName, oldLostFilm.CookieVal = "", ""
+	lostFilm.CookieName, lostFilm.CookieVal = "", ""
+	oldLostFilm.Interval, oldLostFilm.Schedule, oldLostFilm.Jitter, oldLostFilm.QuietHours, oldLostFilm.MaxBackoff = 0, "", 0, "", 0
+	lostFilm.Interval, lostFilm.Schedule, lostFilm.Jitter, lostFilm.QuietHours, lostFilm.MaxBackoff = 0, "", 0, "", 0
+	oldKinozal, kinozal := previous.Kinozal, cfg.Kinozal
+	oldKinozal.Cookie, kinozal.Cookie = "", ""
+	oldKinozal.Interval, oldKinozal.Schedule, oldKinozal.Jitter, oldKinozal.QuietHours, oldKinozal.MaxBackoff = 0, "", 0, "", 0
+	kinozal.Interval, kinozal.Schedule, kinozal.Jitter, kinozal.QuietHours, kinozal.MaxBackoff = 0, "", 0, "", 0
+	oldProxy, proxy := previous.Proxy, cfg.Proxy
+	oldProxy.CheckInterval, proxy.CheckInterval = 0, 0
+	oldTwitch, twitch := previous.Twitch, cfg.Twitch
+	oldTwitch.Channels, twitch.Channels = nil, nil
+
+	sections := []struct {
+		name        string
+		old, actual any
+	}{
+		{"storage", previous.Storage, cfg.Storage},
+		{"database", previous.Database, cfg.Database},
+		{"web", previous.Web, cfg.Web},
+		{"telegram", previous.Telegram, cfg.Telegram},
+		{"proxy", oldProxy, proxy},
+		{"http", previous.Http, cfg.Http},
+		{"redis", previous.Redis, cfg.Redis},
+		{"mastodon", previous.Mastodon, cfg.Mastodon},
+		{"lostfilm", oldLostFilm, lostFilm},
+		{"kinozal", oldKinozal, kinozal},
+		{"twitch", oldTwitch, twitch},
+	}
+	result := make([]string, 0)
+	for _, s := range sections {
+		if !reflect.DeepEqual(s.old, s.actual) {
+			result = append(result, s.name)
+		}
+	}
+	return result
+}
@@ -1,36 +1,45 @@
 package background
 
-import "context"
-
-// Job интерфейс для всех периодических задач
-type Job interface {
-	// Start блокирует текущую горутину. Выполняет какую-либо логику в заданный период
-	Start()
+import (
+	"context"
+	"makarov.dev/bot/internal/app"
+	"makarov.dev/bot/internal/jobs"
+)
+
+// StartAllBackgroundJobs запускает все периодические задачи под наблюдением a.Jobs. Не блокирует текущую горутину
+func StartAllBackgroundJobs(ctx context.Context, a *app.App) *jobs.Supervisor {
+	for _, job := range newJobs(ctx, a) {
+		a.Jobs.Go(ctx, job)
+	}
+	return a.Jobs
 }
 
-var jobs = make([]Job, 0)
+func newJobs(ctx context.Context, a *app.App) []jobs.Job {
+	list := make([]jobs.Job, 0)
 
-// StartAllBackgroundJobs запускает все периодические задачи. Не блокирует текущую горутину
-func StartAllBackgroundJobs(ctx context.Context) {
-	appendJobs(ctx)
-	for _, job := range jobs {
-		go job.Start()
-	}
-}
+	kz := newKinozalBackgroundJob(ctx, a)
+	list = append(list, kz)
+
+	lf := newLostFilmBackgroundJob(ctx, a)
+	list = append(list, lf)
+
+	tg := newTelegramBackgroundJob(ctx, a)
+	list = append(list, tg)
+
+	h := newHealthBackgroundJob(ctx, a)
+	list = append(list, h)
 
-func appendJobs(ctx context.Context) {
-	kz := newKinozalBackgroundJob(ctx)
-	jobs = append(jobs, kz)
+	t := newTwitchBackgroundJob(ctx, a)
+	list = append(list, t)
 
-	lf := newLostFilmBackgroundJob(ctx)
-	jobs = append(jobs, lf)
+	tr := newTwitchRetentionBackgroundJob(ctx, a)
+	list = append(list, tr)
 
-	tg := newTelegramBackgroundJob(ctx)
-	jobs = append(jobs, tg)
+	ts := newTwitchStreamBackgroundJob(ctx, a)
+	list = append(list, ts)
 
-	h := newHealthBackgroundJob(ctx)
-	jobs = append(jobs, h)
+	p := newProxyBackgroundJob(ctx, a)
+	list = append(list, p)
 
-	t := newTwitchBackgroundJob(ctx)
-	jobs = append(jobs, t)
+	return list
 }
@@ -2,28 +2,36 @@ package background
 
 import (
 	"context"
-	"makarov.dev/bot/internal/config"
+	"makarov.dev/bot/internal/app"
+	"makarov.dev/bot/internal/jobs"
 	"time"
 )
 
 type healthBackgroundJob struct {
 	ctx context.Context
+	app *app.App
 }
 
-func newHealthBackgroundJob(ctx context.Context) *healthBackgroundJob {
-	return &healthBackgroundJob{ctx: ctx}
+func newHealthBackgroundJob(ctx context.Context, a *app.App) *healthBackgroundJob {
+	return &healthBackgroundJob{ctx: ctx, app: a}
 }
 
-func (h *healthBackgroundJob) Start() {
-	log := config.GetLogger()
+func (h *healthBackgroundJob) Name() string {
+	return "health"
+}
+
+// Start проверяет замеры скраперов и оповещает администраторов о страницах, которые перестали разбираться
+func (h *healthBackgroundJob) Start(run *jobs.Run) error {
+	log := h.app.Logger
 	for {
+		cfg := h.app.Current().Health
+		h.app.Health.Check(h.ctx, cfg.FailureThreshold)
+		run.Tick()
 		select {
 		case <-h.ctx.Done():
 			log.Infof("Health background job stopped")
-			return
-		default:
-			log.Debug("Health ok")
-			time.Sleep(1 * time.Hour)
+			return nil
+		case <-time.After(cfg.Interval):
 		}
 	}
 }
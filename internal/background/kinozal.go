@@ -1,123 +1,75 @@
 package background
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/app"
 	"makarov.dev/bot/internal/config"
 	"makarov.dev/bot/internal/integration/kinozal"
-	"makarov.dev/bot/internal/integration/telegram"
-	"makarov.dev/bot/pkg"
-	kinozalClient "makarov.dev/bot/pkg/kinozal"
+	"makarov.dev/bot/internal/jobs"
+	"makarov.dev/bot/pkg/schedule"
 	"strconv"
 	"strings"
-	"time"
 )
 
 type kinozalBackgroundJob struct {
-	ctx context.Context
+	ctx     context.Context
+	app     *app.App
+	service *kinozal.Service
 }
 
-func newKinozalBackgroundJob(ctx context.Context) *kinozalBackgroundJob {
-	return &kinozalBackgroundJob{ctx: ctx}
+func newKinozalBackgroundJob(ctx context.Context, a *app.App) *kinozalBackgroundJob {
+	job := &kinozalBackgroundJob{ctx: ctx, app: a, service: a.Kinozal}
+	if a.Config.Kinozal.Enable {
+		job.addTelegramCmd()
+	}
+	return job
+}
+
+func (c *kinozalBackgroundJob) Name() string {
+	return "kinozal"
 }
 
-func (c *kinozalBackgroundJob) Start() {
-	log := config.GetLogger()
-	if !config.GetConfig().Kinozal.Enable {
+func (c *kinozalBackgroundJob) Start(run *jobs.Run) error {
+	log := c.app.Logger
+	if !c.app.Config.Kinozal.Enable {
 		log.Info("Kinozal integration disabled")
-		return
+		return nil
 	}
 
-	addTelegramCmd()
-
 	ch := make(chan int64)
-	client := kinozalClient.Client{
-		Config: kinozalClient.ClientConfig{
-			HttpClient:  pkg.DefaultHttpClient,
-			MainPageUrl: config.GetConfig().Kinozal.Domain,
-			Cookie:      config.GetConfig().Kinozal.Cookie,
-		},
-		Logger: config.GetLogger(),
-	}
-	bucket := config.GetBucket()
+	// listing останавливается и при выходе из задачи после паники, чтобы перезапуск не оставил второе чтение
+	listing, cancel := context.WithCancel(run.Context())
+	defer cancel()
 
-	go client.Listing(ch, time.Minute)
+	wait := pollWait(c.app, "kinozal", run.Tick, func(cfg *config.Config) (*schedule.Schedule, error) {
+		return cfg.Kinozal.PollSchedule()
+	})
+	run.Go(func() { c.app.KinozalClient.Listing(listing, ch, wait) })
 
+	// Listing закрывает канал после отмены ctx, в том числе после паники. Начатое сохранение успевает завершиться или прерваться
 	for id := range ch {
-		select {
-		case <-c.ctx.Done():
-			log.Infof("Kinozal background job stopped")
-			return
-		default:
-			favorite, err := kinozal.IsFavorite(id)
-			if err != nil {
-				log.Error(err.Error())
-				continue
-			}
-			if !favorite {
-				log.Tracef("Kinozal item %d is not favorite", id)
-				continue
-			}
-
-			name, err := client.GetName(id)
-			if err != nil {
-				log.Error(err.Error())
-				continue
-			}
-			exist, err := kinozal.Exist(id, name)
-			if err != nil {
-				log.Error(err.Error())
-				continue
-			}
-			if exist {
-				log.Tracef("Kinozal item %d - %s already stored", id, name)
-				continue
-			}
-
-			element, _ := client.GetElement(id)
-
-			objectID, err := bucket.UploadFromStream(strconv.FormatInt(id, 10)+".torrent", bytes.NewReader(element.Torrent))
-			if err != nil {
-				log.Error("Error while store torrent", err.Error())
-				continue
-			}
-
-			log.Infof("Store KZ item %s (%d)", element.Name, id)
-			if config.GetConfig().Redis.Enable {
-				config.GetRedis().Del(context.Background(), "kz")
-			}
-			item := kinozal.Item{
-				Id:       primitive.NewObjectID(),
-				Name:     element.Name,
-				DetailId: id,
-				GridFsId: objectID,
-				Created:  time.Now(),
-			}
-			err = kinozal.Insert(&item)
-			if err != nil {
-				log.Error(err.Error())
-				continue
-			}
-			kinozal.SendToTelegram(&item)
+		if listing.Err() != nil {
+			continue
 		}
-
+		c.service.StoreElement(c.ctx, id)
 	}
+	log.Infof("Kinozal background job stopped")
+	return nil
 }
 
-func addTelegramCmd() {
-	err := telegram.AddRouterFunc("/add", func(txt string) string {
+func (c *kinozalBackgroundJob) addTelegramCmd() {
+	err := c.app.Telegram.AddRouterFunc("/add", func(txt string) string {
 		if strings.Contains(txt, "kinozal") {
 			txt := strings.ReplaceAll(txt, "kinozal", "")
 			txt = strings.TrimSpace(txt)
 			id, err := strconv.ParseInt(txt, 10, 64)
 			if err != nil {
 				errMsg := fmt.Sprintf("add cmd wrong id %s", txt)
-				config.GetLogger().Error(errMsg)
+				c.app.Logger.Error(errMsg)
 				return errMsg
 			}
-			err = kinozal.InsertFavorite(id)
+			err = c.service.InsertFavorite(id)
 			if err != nil {
 				return err.Error()
 			}
@@ -127,20 +79,20 @@ func addTelegramCmd() {
 		return "wrong add provider"
 	})
 	if err != nil {
-		config.GetLogger().Errorf("Error while add telegram Add cmd %s", err.Error())
+		c.app.Logger.Errorf("Error while add telegram Add cmd %s", err.Error())
 	}
 
-	err = telegram.AddRouterFunc("/delete", func(txt string) string {
+	err = c.app.Telegram.AddRouterFunc("/delete", func(txt string) string {
 		if strings.Contains(txt, "kinozal") {
 			txt := strings.ReplaceAll(txt, "kinozal", "")
 			txt = strings.TrimSpace(txt)
 			id, err := strconv.ParseInt(txt, 10, 64)
 			if err != nil {
 				errMsg := fmt.Sprintf("delete cmd wrong id %s", txt)
-				config.GetLogger().Error(errMsg)
+				c.app.Logger.Error(errMsg)
 				return errMsg
 			}
-			err = kinozal.DeleteFavorite(id)
+			err = c.service.DeleteFavorite(id)
 			if err != nil {
 				return err.Error()
 			}
@@ -150,6 +102,6 @@ func addTelegramCmd() {
 		return "wrong delete provider"
 	})
 	if err != nil {
-		config.GetLogger().Errorf("Error while add telegram Delete cmd %s", err.Error())
+		c.app.Logger.Errorf("Error while add telegram Delete cmd %s", err.Error())
 	}
 }
@@ -2,45 +2,68 @@ package background
 
 import (
 	"context"
+	"makarov.dev/bot/internal/app"
 	"makarov.dev/bot/internal/config"
 	"makarov.dev/bot/internal/integration/lostfilm"
+	"makarov.dev/bot/internal/jobs"
 	lfClient "makarov.dev/bot/pkg/lostfilm"
-	"time"
+	"makarov.dev/bot/pkg/schedule"
+	"sync"
 )
 
 type lostFilmBackgroundJob struct {
-	ctx context.Context
+	ctx     context.Context
+	app     *app.App
+	service *lostfilm.Service
 }
 
-func newLostFilmBackgroundJob(ctx context.Context) *lostFilmBackgroundJob {
-	return &lostFilmBackgroundJob{ctx: ctx}
+func newLostFilmBackgroundJob(ctx context.Context, a *app.App) *lostFilmBackgroundJob {
+	return &lostFilmBackgroundJob{ctx: ctx, app: a, service: a.LostFilm}
 }
 
-func (c *lostFilmBackgroundJob) Start() {
-	log := config.GetLogger()
-	if !config.GetConfig().LostFilm.Enable {
+func (c *lostFilmBackgroundJob) Name() string {
+	return "lostfilm"
+}
+
+func (c *lostFilmBackgroundJob) Start(run *jobs.Run) error {
+	log := c.app.Logger
+	if !c.app.Config.LostFilm.Enable {
 		log.Info("LostFilm integration disabled")
-		return
+		return nil
 	}
 	ch := make(chan lfClient.RootElement)
+	// listing останавливается и при выходе из задачи после паники, чтобы перезапуск не оставил второе чтение
+	listing, cancel := context.WithCancel(run.Context())
+	defer cancel()
 
-	go lostfilm.Client.Listing(ch, time.Minute)
+	wait := pollWait(c.app, "lostfilm", run.Tick, func(cfg *config.Config) (*schedule.Schedule, error) {
+		return cfg.LostFilm.PollSchedule()
+	})
+	run.Go(func() { c.service.Client.Listing(listing, ch, wait) })
 
+	// inflight серии, которые сейчас сохраняются. Перед остановкой задача ждет, пока они сохранятся или прервутся
+	var inflight sync.WaitGroup
+	// Listing закрывает канал после отмены ctx, в том числе после паники
 	for element := range ch {
-		select {
-		case <-c.ctx.Done():
-			log.Infof("LostFilm background job stopped")
-			return
-		default:
-			exist, err := lostfilm.Exists(element.Page)
-			if err != nil {
-				log.Error(err.Error())
-				continue
-			}
-			if exist {
-				continue
-			}
-			go lostfilm.StoreElement(element)
+		if listing.Err() != nil {
+			continue
+		}
+		exist, err := c.service.Exists(element.Page)
+		if err != nil {
+			log.Error(err.Error())
+			continue
+		}
+		if exist {
+			continue
 		}
+		inflight.Add(1)
+		run.Go(func() {
+			defer inflight.Done()
+			c.service.StoreElement(c.ctx, element)
+		})
 	}
+	inflight.Wait()
+	c.service.Wait()
+	log.Infof("LostFilm background job stopped")
+	return nil
 }
@@ -0,0 +1,41 @@
+package background
+
+import (
+	"context"
+	"makarov.dev/bot/internal/app"
+	"makarov.dev/bot/internal/jobs"
+	"time"
+)
+
+type proxyBackgroundJob struct {
+	ctx context.Context
+	app *app.App
+}
+
+func newProxyBackgroundJob(ctx context.Context, a *app.App) *proxyBackgroundJob {
+	return &proxyBackgroundJob{ctx: ctx, app: a}
+}
+
+func (p *proxyBackgroundJob) Name() string {
+	return "proxy"
+}
+
+// Start проверяет прокси, чтобы недоступные вернулись в строй, а неисправные пропускались до первого запроса
+func (p *proxyBackgroundJob) Start(run *jobs.Run) error {
+	log := p.app.Logger
+	pool := p.app.Proxies
+	if pool == nil {
+		log.Info("Proxy health checks disabled")
+		return nil
+	}
+	for {
+		pool.Check(p.ctx)
+		run.Tick()
+		select {
+		case <-p.ctx.Done():
+			log.Infof("Proxy background job stopped")
+			return nil
+		case <-time.After(p.app.Current().Proxy.CheckInterval):
+		}
+	}
+}
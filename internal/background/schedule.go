@@ -0,0 +1,35 @@
+package background
+
+import (
+	"makarov.dev/bot/internal/app"
+	"makarov.dev/bot/internal/config"
+	"makarov.dev/bot/pkg/schedule"
+	"time"
+)
+
+// fallbackPollInterval пауза, если расписание из конфигурации не собралось. Конфигурация проверяется при загрузке,
+// так что это страховка
+const fallbackPollInterval = time.Minute
+
+// pollWait паузы между чтениями по расписанию текущей конфигурации, чтобы оно менялось по SIGHUP.
+// Ошибки чтения подряд увеличивают паузу, tick отмечает каждое чтение в состоянии задачи
+func pollWait(a *app.App, provider string, tick func(), current func(cfg *config.Config) (*schedule.Schedule, error)) func(err error) time.Duration {
+	log := a.Logger
+	backoff := &schedule.Backoff{}
+	return func(err error) time.Duration {
+		tick()
+		failures := backoff.Record(err)
+		s, scheduleErr := current(a.Current())
+		if scheduleErr != nil {
+			log.Errorf("Error while build %s poll schedule %s", provider, scheduleErr.Error())
+			return fallbackPollInterval
+		}
+		delay := s.Delay(time.Now(), failures)
+		if failures > 0 {
+			log.Warnf("Poll of %s failed, next poll in %s", provider, delay)
+		} else {
+			log.Debugf("Next poll of %s in %s", provider, delay)
+		}
+		return delay
+	}
+}
@@ -2,17 +2,79 @@ package background
 
 import (
 	"context"
+	"fmt"
+	"makarov.dev/bot/internal/app"
+	"makarov.dev/bot/internal/integration/credential"
 	"makarov.dev/bot/internal/integration/telegram"
+	"makarov.dev/bot/internal/jobs"
+	"strings"
 )
 
+const cookieUsage = "usage: /cookie set lostfilm|kinozal <value>"
+
+// tickLayout формат времени последней итерации задачи в /jobs
+const tickLayout = "2006-01-02 15:04:05"
+
 type telegramBackgroundJob struct {
 	ctx context.Context
+	app *app.App
+	bot *telegram.Bot
+}
+
+func newTelegramBackgroundJob(ctx context.Context, a *app.App) *telegramBackgroundJob {
+	job := &telegramBackgroundJob{ctx: ctx, app: a, bot: a.Telegram}
+	job.addTelegramCmd()
+	return job
+}
+
+func (t *telegramBackgroundJob) Name() string {
+	return "telegram"
+}
+
+func (t *telegramBackgroundJob) Start(run *jobs.Run) error {
+	return t.bot.Start(run.Context(), run.Tick)
 }
 
-func newTelegramBackgroundJob(ctx context.Context) *telegramBackgroundJob {
-	return &telegramBackgroundJob{ctx: ctx}
+func (t *telegramBackgroundJob) addTelegramCmd() {
+	err := t.bot.AddAdminRouterFunc("/cookie", func(txt string) string {
+		action, args, _ := strings.Cut(txt, " ")
+		tracker, value, _ := strings.Cut(strings.TrimSpace(args), " ")
+		if action != "set" || (tracker != credential.LostFilm && tracker != credential.Kinozal) {
+			return cookieUsage
+		}
+		err := t.app.Credentials.Set(tracker, value)
+		if err != nil {
+			t.app.Logger.Errorf("Error while set %s cookie %s", tracker, err.Error())
+			return err.Error()
+		}
+		return "Ok"
+	})
+	if err != nil {
+		t.app.Logger.Errorf("Error while add telegram Cookie cmd %s", err.Error())
+	}
+	err = t.bot.AddAdminRouterFunc("/jobs", func(string) string {
+		return formatJobs(t.app.Jobs.States())
+	})
+	if err != nil {
+		t.app.Logger.Errorf("Error while add telegram Jobs cmd %s", err.Error())
+	}
 }
 
-func (t *telegramBackgroundJob) Start() {
-	telegram.Start(t.ctx)
+func formatJobs(states []jobs.State) string {
+	if len(states) == 0 {
+		return "no jobs"
+	}
+	lines := make([]string, 0, len(states))
+	for _, state := range states {
+		tick := "never"
+		if !state.LastTick.IsZero() {
+			tick = state.LastTick.Format(tickLayout)
+		}
+		line := fmt.Sprintf("%s: %s, restarts %d, last tick %s", state.Name, state.Status, state.Restarts, tick)
+		if state.LastError != "" {
+			line += ", last error " + state.LastError
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
 }
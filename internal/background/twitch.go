@@ -1,18 +1,172 @@
 package background
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"makarov.dev/bot/internal/app"
+	"makarov.dev/bot/internal/integration/telegram"
 	"makarov.dev/bot/internal/integration/twitch"
+	"makarov.dev/bot/internal/jobs"
+	"strings"
 )
 
+// maxTelegramFileSize предел файла, который бот может отправить в Telegram
+const maxTelegramFileSize = 50 << 20
+
+var errExportTooLarge = errors.New("export is larger than 50 MB, narrow the period")
+
+// limitedBuffer прерывает выгрузку, как только она превышает max
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, errExportTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
 type twitchBackgroundJob struct {
-	ctx context.Context
+	ctx     context.Context
+	app     *app.App
+	service *twitch.Service
 }
 
-func newTwitchBackgroundJob(ctx context.Context) *twitchBackgroundJob {
-	return &twitchBackgroundJob{ctx: ctx}
+func newTwitchBackgroundJob(ctx context.Context, a *app.App) *twitchBackgroundJob {
+	job := &twitchBackgroundJob{ctx: ctx, app: a, service: a.Twitch}
+	job.addTelegramCmd()
+	return job
 }
 
-func (t *twitchBackgroundJob) Start() {
-	twitch.Start(t.ctx)
+func (t *twitchBackgroundJob) Name() string {
+	return "twitch"
+}
+
+func (t *twitchBackgroundJob) Start(run *jobs.Run) error {
+	return t.service.Start(run)
+}
+
+func (t *twitchBackgroundJob) addTelegramCmd() {
+	err := t.app.Telegram.AddUserRouterFunc("/twitch", func(txt string, from *tgbotapi.User) string {
+		split := strings.Fields(txt)
+		if len(split) == 0 || len(split) == 1 && split[0] == "list" {
+			channels, err := t.service.GetChannels()
+			if err != nil {
+				return err.Error()
+			}
+			names := make([]string, 0, len(channels))
+			for _, channel := range channels {
+				names = append(names, channel.Name)
+			}
+			if len(names) == 0 {
+				return "no channels"
+			}
+			return strings.Join(names, ", ")
+		}
+		if len(split) != 2 {
+			return "usage: /twitch join|part <channel> or /twitch list"
+		}
+		if split[0] != "join" && split[0] != "part" {
+			return fmt.Sprintf("wrong twitch cmd %s", split[0])
+		}
+		// подключение к каналам доступно только администраторам: каждый канал добавляет поток сообщений и метрик
+		if !t.app.Telegram.IsAdmin(from) {
+			return telegram.AdminOnlyReply
+		}
+		var err error
+		if split[0] == "join" {
+			_, err = t.service.JoinChannel(split[1])
+		} else {
+			err = t.service.PartChannel(split[1])
+		}
+		if err != nil {
+			return err.Error()
+		}
+		return "Ok"
+	})
+	if err != nil {
+		t.app.Logger.Errorf("Error while add telegram Twitch cmd %s", err.Error())
+	}
+
+	err = t.app.Telegram.AddUserRouterFunc("/alert", func(txt string, from *tgbotapi.User) string {
+		cmd, args, _ := strings.Cut(txt, " ")
+		// правила оповещений меняют только администраторы, по ним бот пишет в чаты
+		if (cmd == "add" || cmd == "remove") && !t.app.Telegram.IsAdmin(from) {
+			return telegram.AdminOnlyReply
+		}
+		switch cmd {
+		case "add":
+			rule, err := twitch.ParseAlertRule(args)
+			if err != nil {
+				return err.Error()
+			}
+			err = t.service.AddAlertRule(rule)
+			if err != nil {
+				return err.Error()
+			}
+			return rule.String()
+		case "list":
+			rules := t.service.GetAlertRules()
+			if len(rules) == 0 {
+				return "no alert rules"
+			}
+			lines := make([]string, 0, len(rules))
+			for _, rule := range rules {
+				lines = append(lines, rule.String())
+			}
+			return strings.Join(lines, "\n")
+		case "remove":
+			err := t.service.RemoveAlertRule(strings.TrimSpace(args))
+			if err != nil {
+				return err.Error()
+			}
+			return "Ok"
+		default:
+			return "usage: /alert add [channel=<channel>] [user=<user>] [rate=<duration>] [pattern=<regex>], /alert list, /alert remove <id>"
+		}
+	})
+	if err != nil {
+		t.app.Logger.Errorf("Error while add telegram Alert cmd %s", err.Error())
+	}
+
+	err = t.app.Telegram.AddFileRouterFunc("/export", func(txt string) (tgbotapi.FileBytes, error) {
+		split := strings.Fields(txt)
+		if len(split) < 2 || len(split) > 5 {
+			return tgbotapi.FileBytes{}, errors.New("usage: /export <channel> <from> [to] [jsonl|csv|vtt|ass] [streamStart]")
+		}
+		opts := twitch.ExportOptions{Channel: split[0], Format: twitch.ExportJsonl}
+		var err error
+		if opts.From, err = twitch.ParseExportTime(split[1]); err != nil {
+			return tgbotapi.FileBytes{}, err
+		}
+		if len(split) > 2 {
+			if opts.To, err = twitch.ParseExportTime(split[2]); err != nil {
+				return tgbotapi.FileBytes{}, err
+			}
+		}
+		if len(split) > 3 {
+			if opts.Format, err = twitch.ParseExportFormat(split[3]); err != nil {
+				return tgbotapi.FileBytes{}, err
+			}
+		}
+		if len(split) > 4 {
+			if opts.StreamStart, err = twitch.ParseExportTime(split[4]); err != nil {
+				return tgbotapi.FileBytes{}, err
+			}
+		}
+		buf := &limitedBuffer{max: maxTelegramFileSize}
+		err = t.service.Export(t.ctx, buf, opts)
+		if err != nil {
+			return tgbotapi.FileBytes{}, err
+		}
+		return tgbotapi.FileBytes{Name: opts.Format.FileName(opts.Channel), Bytes: buf.Bytes()}, nil
+	})
+	if err != nil {
+		t.app.Logger.Errorf("Error while add telegram Export cmd %s", err.Error())
+	}
 }
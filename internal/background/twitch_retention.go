@@ -0,0 +1,40 @@
+package background
+
+import (
+	"context"
+	"makarov.dev/bot/internal/app"
+	"makarov.dev/bot/internal/integration/twitch"
+	"makarov.dev/bot/internal/jobs"
+	"time"
+)
+
+type twitchRetentionBackgroundJob struct {
+	ctx     context.Context
+	app     *app.App
+	service *twitch.Service
+}
+
+func newTwitchRetentionBackgroundJob(ctx context.Context, a *app.App) *twitchRetentionBackgroundJob {
+	return &twitchRetentionBackgroundJob{ctx: ctx, app: a, service: a.Twitch}
+}
+
+func (t *twitchRetentionBackgroundJob) Name() string {
+	return "twitch_retention"
+}
+
+func (t *twitchRetentionBackgroundJob) Start(run *jobs.Run) error {
+	log := t.app.Logger
+	for {
+		err := t.service.ApplyRetention(t.ctx)
+		if err != nil {
+			log.Errorf("Error while apply twitch chat retention %s", err.Error())
+		}
+		run.Tick()
+		select {
+		case <-t.ctx.Done():
+			log.Infof("Twitch retention background job stopped")
+			return nil
+		case <-time.After(1 * time.Hour):
+		}
+	}
+}
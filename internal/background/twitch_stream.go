@@ -0,0 +1,45 @@
+package background
+
+import (
+	"context"
+	"makarov.dev/bot/internal/app"
+	"makarov.dev/bot/internal/integration/twitch"
+	"makarov.dev/bot/internal/jobs"
+	"time"
+)
+
+type twitchStreamBackgroundJob struct {
+	ctx     context.Context
+	app     *app.App
+	service *twitch.Service
+}
+
+func newTwitchStreamBackgroundJob(ctx context.Context, a *app.App) *twitchStreamBackgroundJob {
+	return &twitchStreamBackgroundJob{ctx: ctx, app: a, service: a.Twitch}
+}
+
+func (t *twitchStreamBackgroundJob) Name() string {
+	return "twitch_stream"
+}
+
+func (t *twitchStreamBackgroundJob) Start(run *jobs.Run) error {
+	log := t.app.Logger
+	client := t.app.HelixClient
+	if client == nil {
+		log.Info("Twitch stream alerts disabled")
+		return nil
+	}
+	for {
+		err := t.service.PollStreams(t.ctx, client)
+		if err != nil {
+			log.Errorf("Error while poll twitch streams %s", err.Error())
+		}
+		run.Tick()
+		select {
+		case <-t.ctx.Done():
+			log.Infof("Twitch stream background job stopped")
+			return nil
+		case <-time.After(time.Minute):
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// ErrMiss значения нет в кеше
+var ErrMiss = errors.New("cache miss")
+
+// Cache кеш ответов. Реализации: Redis и пустой кеш, когда Redis выключен
+type Cache interface {
+	Enabled() bool
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ex time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	// Ping проверяет соединение с хранилищем кеша
+	Ping(ctx context.Context) error
+}
+
+type RedisCache struct {
+	Client *redis.Client
+}
+
+func (r *RedisCache) Enabled() bool {
+	return true
+}
+
+func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := r.Client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return b, err
+}
+
+func (r *RedisCache) Set(ctx context.Context, key string, value []byte, ex time.Duration) error {
+	return r.Client.Set(ctx, key, value, ex).Err()
+}
+
+func (r *RedisCache) Del(ctx context.Context, keys ...string) error {
+	return r.Client.Del(ctx, keys...).Err()
+}
+
+func (r *RedisCache) Ping(ctx context.Context) error {
+	return r.Client.Ping(ctx).Err()
+}
+
+// NopCache ничего не хранит
+type NopCache struct {
+}
+
+func (n NopCache) Enabled() bool {
+	return false
+}
+
+func (n NopCache) Get(context.Context, string) ([]byte, error) {
+	return nil, ErrMiss
+}
+
+func (n NopCache) Set(context.Context, string, []byte, time.Duration) error {
+	return nil
+}
+
+func (n NopCache) Del(context.Context, ...string) error {
+	return nil
+}
+
+func (n NopCache) Ping(context.Context) error {
+	return nil
+}
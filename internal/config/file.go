@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv переносит значения файлов в переменные окружения, которые не заданы явно: секреты из *_FILE
+// и файл конфигурации. Ключи файла конфигурации совпадают с переменными окружения: секция lostfilm
+// с ключом cookie_name дает LOSTFILM_COOKIE_NAME. Приоритет: флаги, окружение, *_FILE, файл конфигурации, значения по умолчанию
+type FileEnv struct {
+	// keys переменные, выставленные из файлов
+	keys []string
+}
+
+// Reset убирает выставленные ранее переменные перед повторной загрузкой, чтобы удаленные из файлов значения сбросились
+func (e *FileEnv) Reset() {
+	for _, key := range e.keys {
+		_ = os.Unsetenv(key)
+	}
+	e.keys = e.keys[:0]
+}
+
+// LoadSecrets для каждой переменной из secrets читает файл, путь к которому задан в <переменная>_FILE.
+// Так секреты подключаются из смонтированных файлов Docker и Kubernetes, концевой перевод строки отбрасывается
+func (e *FileEnv) LoadSecrets(secrets []string) error {
+	for _, key := range secrets {
+		path, found := os.LookupEnv(key + "_FILE")
+		if !found || path == "" {
+			continue
+		}
+		if _, found = os.LookupEnv(key); found {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s_FILE %w", key, err)
+		}
+		if err = e.set(key, strings.TrimRight(string(b), "\r\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load читает YAML или TOML файл. known допустимые переменные окружения, неизвестный ключ файла - ошибка
+func (e *FileEnv) Load(path string, known []string) error {
+	values, err := readFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file %s %w", path, err)
+	}
+	unknown := make([]string, 0)
+	for key := range values {
+		if !slices.Contains(known, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("config file %s has unknown keys %s", path, strings.Join(unknown, ", "))
+	}
+
+	for key, value := range values {
+		if _, found := os.LookupEnv(key); found {
+			continue
+		}
+		if err = e.set(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *FileEnv) set(key string, value string) error {
+	if err := os.Setenv(key, value); err != nil {
+		return err
+	}
+	e.keys = append(e.keys, key)
+	return nil
+}
+
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tree := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &tree)
+	case ".toml":
+		err = toml.Unmarshal(b, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported format %s, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	if err = flatten("", tree, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flatten разворачивает вложенные секции в ключи переменных окружения, списки склеиваются через запятую
+func flatten(prefix string, tree map[string]any, values map[string]string) error {
+	for key, value := range tree {
+		key = prefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		switch v := value.(type) {
+		case map[string]any:
+			if err := flatten(key+"_", v, values); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			return fmt.Errorf("empty value of %s", key)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
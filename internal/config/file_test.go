@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileEnv_Load(t *testing.T) {
+	known := []string{"LOG_LEVEL", "LOSTFILM_COOKIE_NAME", "LOSTFILM_INTERVAL", "TWITCH_CHANNELS", "DATABASE_URI"}
+	for _, key := range known {
+		t.Setenv(key, "")
+		_ = os.Unsetenv(key)
+	}
+	t.Setenv("DATABASE_URI", "env.db")
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"yaml", "bot.yaml", "log_level: INFO\nlostfilm:\n  cookie-name: a\n  interval: 2m\ntwitch:\n  channels: [one, two]\ndatabase:\n  uri: file.db\n"},
+		{"toml", "bot.toml", "log_level = \"INFO\"\n[lostfilm]\ncookie_name = \"a\"\ninterval = \"2m\"\n[twitch]\nchannels = [\"one\", \"two\"]\n[database]\nuri = \"file.db\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			e := &FileEnv{}
+			if err := e.Load(path, known); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			want := map[string]string{
+				"LOG_LEVEL":            "INFO",
+				"LOSTFILM_COOKIE_NAME": "a",
+				"LOSTFILM_INTERVAL":    "2m",
+				"TWITCH_CHANNELS":      "one,two",
+				"DATABASE_URI":         "env.db",
+			}
+			for key, value := range want {
+				if got := os.Getenv(key); got != value {
+					t.Errorf("%s = %s, want %s", key, got, value)
+				}
+			}
+
+			if err := os.WriteFile(path, []byte(strings.Split(tt.content, "\n")[0]), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			e.Reset()
+			if err := e.Load(path, known); err != nil {
+				t.Fatalf("second Load() error = %v", err)
+			}
+			if _, found := os.LookupEnv("LOSTFILM_COOKIE_NAME"); found {
+				t.Errorf("LOSTFILM_COOKIE_NAME is still set after removal from file")
+			}
+			if os.Getenv("DATABASE_URI") != "env.db" {
+				t.Errorf("DATABASE_URI from environment was changed")
+			}
+			_ = os.Unsetenv("LOG_LEVEL")
+		})
+	}
+}
+
+func TestFileEnv_LoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	unknown := filepath.Join(dir, "bot.yaml")
+	if err := os.WriteFile(unknown, []byte("lostfilm:\n  cookie: a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := (&FileEnv{}).Load(unknown, []string{"LOSTFILM_COOKIE_NAME"})
+	if err == nil || !strings.Contains(err.Error(), "LOSTFILM_COOKIE") {
+		t.Errorf("Load() unknown key error = %v", err)
+	}
+	ini := filepath.Join(dir, "bot.ini")
+	if err = os.WriteFile(ini, []byte("a=b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = (&FileEnv{}).Load(ini, nil); err == nil {
+		t.Errorf("Load() ini error = nil")
+	}
+}
+
+func TestFileEnv_LoadSecrets(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "cookie")
+	if err := os.WriteFile(secret, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KINOZAL_COOKIE", "")
+	_ = os.Unsetenv("KINOZAL_COOKIE")
+	t.Setenv("KINOZAL_COOKIE_FILE", secret)
+	t.Setenv("TELEGRAM_TOKEN", "from-env")
+	t.Setenv("TELEGRAM_TOKEN_FILE", secret)
+
+	e := &FileEnv{}
+	if err := e.LoadSecrets([]string{"KINOZAL_COOKIE", "TELEGRAM_TOKEN", "REDIS_PASSWORD"}); err != nil {
+		t.Fatalf("LoadSecrets() error = %v", err)
+	}
+	if got := os.Getenv("KINOZAL_COOKIE"); got != "from-file" {
+		t.Errorf("KINOZAL_COOKIE = %q, want from-file", got)
+	}
+	if got := os.Getenv("TELEGRAM_TOKEN"); got != "from-env" {
+		t.Errorf("TELEGRAM_TOKEN = %q, explicit env must win", got)
+	}
+	e.Reset()
+	if _, found := os.LookupEnv("KINOZAL_COOKIE"); found {
+		t.Errorf("KINOZAL_COOKIE is still set after Reset()")
+	}
+
+	t.Setenv("REDIS_PASSWORD_FILE", filepath.Join(dir, "missing"))
+	if err := e.LoadSecrets([]string{"REDIS_PASSWORD"}); err == nil {
+		t.Errorf("LoadSecrets() missing file error = nil")
+	}
+}
@@ -1,29 +1,19 @@
 package config
 
 import (
+	"net/http"
+
 	"github.com/mattn/go-mastodon"
-	"sync"
 )
 
-var onceMastodonClient = sync.Once{}
-var mastodonClient *mastodon.Client
-
-func NewMastodonClient() *mastodon.Client {
-	cfg := GetConfig().Mastodon
-	mastodonClient = mastodon.NewClient(&mastodon.Config{
+func NewMastodonClient(cfg MastodonConfig, transport http.RoundTripper) *mastodon.Client {
+	client := mastodon.NewClient(&mastodon.Config{
 		Server:       cfg.Server,
 		ClientID:     cfg.ClientKey,
 		ClientSecret: cfg.ClientSecret,
 		AccessToken:  cfg.AccessToken,
 	})
-	return mastodonClient
-}
-
-func GetMastodonClient() *mastodon.Client {
-	if mastodonClient == nil {
-		onceMastodonClient.Do(func() {
-			mastodonClient = NewMastodonClient()
-		})
-	}
-	return mastodonClient
+	// соединения идут через прокси, если Mastodon в их маршруте
+	client.Transport = transport
+	return client
 }
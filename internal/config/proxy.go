@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/metrics"
+	"makarov.dev/bot/pkg/proxypool"
+)
+
+// proxyProviders провайдеры, которых можно направить через прокси
+var proxyProviders = []string{"lostfilm", "kinozal", "twitch", "telegram", "mastodon"}
+
+// ProxyUrls адреса прокси пула по порядку перебора
+func (cfg ProxyConfig) ProxyUrls() []string {
+	urls := make([]string, 0, len(cfg.Urls)+1)
+	if cfg.Socks5Addr != "" {
+		u := &url.URL{Scheme: "socks5", Host: cfg.Socks5Addr}
+		if cfg.Socks5User != "" && cfg.Socks5Password != "" {
+			u.User = url.UserPassword(cfg.Socks5User, cfg.Socks5Password)
+		}
+		urls = append(urls, u.String())
+	}
+	for _, raw := range cfg.Urls {
+		if raw = strings.TrimSpace(raw); raw != "" {
+			urls = append(urls, raw)
+		}
+	}
+	return urls
+}
+
+// proxyHosts хосты провайдеров из cfg.Proxy.Providers
+func (cfg *Config) proxyHosts() ([]string, error) {
+	hosts := make([]string, 0)
+	domain := func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			return raw
+		}
+		return u.Hostname()
+	}
+	for _, provider := range cfg.Proxy.Providers {
+		switch strings.ToLower(strings.TrimSpace(provider)) {
+		case "lostfilm":
+			hosts = append(hosts, domain(cfg.LostFilm.Domain))
+		case "kinozal":
+			hosts = append(hosts, domain(cfg.Kinozal.Domain))
+		case "twitch":
+			// только Twitch API, IRC клиент irc.chat.twitch.tv соединяется сам в обход пула
+			hosts = append(hosts, "twitch.tv")
+		case "telegram":
+			hosts = append(hosts, "api.telegram.org")
+		case "mastodon":
+			hosts = append(hosts, domain(cfg.Mastodon.Server))
+		default:
+			return nil, fmt.Errorf("proxy provider %s must be one of %s", provider, strings.Join(proxyProviders, ", "))
+		}
+	}
+	return hosts, nil
+}
+
+// NewProxyPool создает пул прокси по конфигурации
+func NewProxyPool(cfg *Config, logger *log.Logger) (*proxypool.Pool, error) {
+	hosts, err := cfg.proxyHosts()
+	if err != nil {
+		return nil, err
+	}
+	pool := &proxypool.Pool{
+		Hosts:          hosts,
+		FallbackDirect: cfg.Proxy.FallbackDirect,
+		CheckAddr:      cfg.Proxy.CheckAddr,
+		OnChange: func(p *proxypool.Proxy, err error) {
+			if err != nil {
+				metrics.ProxyUp.WithLabelValues(p.Name).Set(0)
+				logger.Warnf("Proxy %s is down %s", p.Name, err.Error())
+				return
+			}
+			metrics.ProxyUp.WithLabelValues(p.Name).Set(1)
+			logger.Infof("Proxy %s is up", p.Name)
+		},
+	}
+	for _, raw := range cfg.Proxy.ProxyUrls() {
+		p, err := proxypool.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		pool.Proxies = append(pool.Proxies, p)
+		metrics.ProxyUp.WithLabelValues(p.Name).Set(1)
+	}
+	return pool, nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"time"
+
+	"makarov.dev/bot/pkg/schedule"
+)
+
+// PollSchedule расписание чтения страницы новых серий
+func (c LostFilmConfig) PollSchedule() (*schedule.Schedule, error) {
+	return newSchedule(c.Schedule, c.Interval, c.Jitter, c.QuietHours, c.MaxBackoff)
+}
+
+// PollSchedule расписание чтения главной страницы
+func (c KinozalConfig) PollSchedule() (*schedule.Schedule, error) {
+	return newSchedule(c.Schedule, c.Interval, c.Jitter, c.QuietHours, c.MaxBackoff)
+}
+
+func newSchedule(spec string, interval time.Duration, jitter time.Duration, quiet string, maxBackoff time.Duration) (*schedule.Schedule, error) {
+	if spec == "" {
+		spec = interval.String()
+	}
+	s := &schedule.Schedule{Jitter: jitter, MaxBackoff: maxBackoff}
+	var err error
+	if s.Spec, err = schedule.Parse(spec); err != nil {
+		return nil, err
+	}
+	if s.Quiet, err = schedule.ParseQuietHours(quiet); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"context"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InitTracing отправляет трассировку в OTLP коллектор.
+// Без cfg.Endpoint остается провайдер no-op. Возвращаемая функция дописывает накопленные span перед выходом
+func InitTracing(cfg TracingConfig, logger *log.Logger) (func(ctx context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	logger.Infof("Tracing to %s enabled", cfg.Endpoint)
+	return provider.Shutdown, nil
+}
+
+// TraceTransport добавляет span исходящим запросам, если трассировка включена
+func TraceTransport(cfg TracingConfig, transport http.RoundTripper) http.RoundTripper {
+	if cfg.Endpoint == "" {
+		return transport
+	}
+	return otelhttp.NewTransport(transport)
+}
+
+// traceHook добавляет в запись trace_id и span_id, если она создана через WithContext с активным span
+type traceHook struct {
+}
+
+func (traceHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (traceHook) Fire(entry *log.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	sc := trace.SpanContextFromContext(entry.Context)
+	if !sc.IsValid() {
+		return nil
+	}
+	entry.Data["trace_id"] = sc.TraceID().String()
+	entry.Data["span_id"] = sc.SpanID().String()
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceHook(t *testing.T) {
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "span")
+	defer span.End()
+
+	entry := log.NewEntry(log.New()).WithContext(ctx)
+	if err := (traceHook{}).Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Data["trace_id"] != span.SpanContext().TraceID().String() || entry.Data["span_id"] != span.SpanContext().SpanID().String() {
+		t.Errorf("fields = %v", entry.Data)
+	}
+
+	entry = log.NewEntry(log.New()).WithContext(context.Background())
+	_ = (traceHook{}).Fire(entry)
+	if _, found := entry.Data["trace_id"]; found {
+		t.Errorf("trace_id without span %v", entry.Data)
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/pkg/proxypool"
+)
+
+// Validate проверяет только включенные подсистемы и возвращает все найденные ошибки разом
+func (cfg *Config) Validate() error {
+	errs := make([]string, 0)
+	require := func(enabled bool, subsystem string, value string, name string) {
+		if enabled && value == "" {
+			errs = append(errs, fmt.Sprintf("%s is enabled but %s is empty", subsystem, name))
+		}
+	}
+
+	// с логином и паролем cookie можно не задавать, клиент войдет сам
+	lfLogin := cfg.LostFilm.Username != ""
+	require(cfg.LostFilm.Enable && !lfLogin, "lostfilm", cfg.LostFilm.CookieName, "--cookie-name (LOSTFILM_COOKIE_NAME)")
+	require(cfg.LostFilm.Enable && !lfLogin, "lostfilm", cfg.LostFilm.CookieVal, "--cookie-val (LOSTFILM_COOKIE_VAL)")
+	require(cfg.LostFilm.Enable && lfLogin, "lostfilm login", cfg.LostFilm.Password, "--lostfilm-password (LOSTFILM_PASSWORD)")
+	kinozalLogin := cfg.Kinozal.Username != ""
+	require(cfg.Kinozal.Enable && !kinozalLogin, "kinozal", cfg.Kinozal.Cookie, "--kinozal-cookie (KINOZAL_COOKIE)")
+	require(cfg.Kinozal.Enable && kinozalLogin, "kinozal login", cfg.Kinozal.Password, "--kinozal-password (KINOZAL_PASSWORD)")
+	require(cfg.Telegram.Enable, "telegram", cfg.Telegram.BotToken, "--telegram-bot-token (TELEGRAM_TOKEN)")
+	require(cfg.Proxy.Enable, "proxy", strings.Join(cfg.Proxy.ProxyUrls(), ","), "--proxy-socks5-addr (PROXY_ADDR) or --proxy-url (PROXY_URLS)")
+	require(cfg.Redis.Enable, "redis", cfg.Redis.Addr, "--redis-addr (REDIS_ADDR)")
+	require(cfg.Mastodon.Enable, "mastodon", cfg.Mastodon.Server, "--mastodon-server (MASTODON_SERVER)")
+	require(cfg.Twitch.ClientId != "", "twitch helix", cfg.Twitch.ClientSecret, "--twitch-client-secret (TWITCH_CLIENT_SECRET)")
+	require(cfg.Storage == "database", "database storage", cfg.Database.Uri, "--uri (DATABASE_URI)")
+
+	if _, err := log.ParseLevel(cfg.LogLevel); err != nil {
+		errs = append(errs, "log level "+err.Error())
+	}
+	if cfg.LostFilm.Enable {
+		if cfg.LostFilm.Schedule == "" && cfg.LostFilm.Interval <= 0 {
+			errs = append(errs, "lostfilm --lostfilm-interval (LOSTFILM_INTERVAL) must be positive")
+		} else if _, err := cfg.LostFilm.PollSchedule(); err != nil {
+			errs = append(errs, "lostfilm "+err.Error())
+		}
+	}
+	if cfg.Kinozal.Enable {
+		if cfg.Kinozal.Schedule == "" && cfg.Kinozal.Interval <= 0 {
+			errs = append(errs, "kinozal --kinozal-interval (KINOZAL_INTERVAL) must be positive")
+		} else if _, err := cfg.Kinozal.PollSchedule(); err != nil {
+			errs = append(errs, "kinozal "+err.Error())
+		}
+	}
+	if cfg.Health.FailureThreshold < 1 {
+		errs = append(errs, "health --health-failure-threshold (HEALTH_FAILURE_THRESHOLD) must be at least 1")
+	}
+	if cfg.Health.Interval <= 0 {
+		errs = append(errs, "health --health-interval (HEALTH_INTERVAL) must be positive")
+	}
+	if cfg.Health.ScrapeMaxAge <= 0 {
+		errs = append(errs, "health --health-scrape-max-age (HEALTH_SCRAPE_MAX_AGE) must be positive")
+	}
+	if cfg.Proxy.Enable {
+		for _, raw := range cfg.Proxy.ProxyUrls() {
+			if _, err := proxypool.Parse(raw); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if _, err := cfg.proxyHosts(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if cfg.Proxy.CheckInterval <= 0 {
+			errs = append(errs, "proxy --proxy-check-interval (PROXY_CHECK_INTERVAL) must be positive")
+		}
+	}
+	if cfg.Web.ProxyApiKey != "" && len(cfg.Web.ProxyAllowedHosts) == 0 {
+		errs = append(errs, "web /proxy is enabled but --proxy-allowed-host (WEB_PROXY_ALLOWED_HOSTS) is empty")
+	}
+	if cfg.Web.ProxyMaxBytes < 0 {
+		errs = append(errs, "web --proxy-max-bytes (WEB_PROXY_MAX_BYTES) must not be negative")
+	}
+	if cfg.Http.RateLimit < 0 || cfg.Http.Burst < 0 || cfg.Http.MaxConcurrent < 0 || cfg.Http.MaxRetries < 0 ||
+		cfg.Http.RetryBackoff < 0 || cfg.Http.MaxRetryAfter < 0 {
+		errs = append(errs, "http limits must not be negative")
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		errs = append(errs, "tracing --tracing-sample-ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1")
+	}
+	for _, rule := range cfg.Twitch.ChannelRetentionDays {
+		_, days, found := strings.Cut(rule, ":")
+		if _, err := strconv.Atoi(strings.TrimSpace(days)); !found || err != nil {
+			errs = append(errs, fmt.Sprintf("twitch channel retention %s must be in channel:days format", rule))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
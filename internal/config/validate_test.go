@@ -0,0 +1,104 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{LogLevel: "INFO", Storage: "memory", Health: HealthConfig{FailureThreshold: 3, Interval: time.Minute, ScrapeMaxAge: time.Hour}}
+	}
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr []string
+	}{
+		{"disabled integrations need no cookies", func(cfg *Config) {}, nil},
+		{"lostfilm without cookies", func(cfg *Config) {
+			cfg.LostFilm.Enable = true
+			cfg.LostFilm.Interval = time.Minute
+		}, []string{"LOSTFILM_COOKIE_NAME", "LOSTFILM_COOKIE_VAL"}},
+		{"lostfilm with login needs no cookies", func(cfg *Config) {
+			cfg.LostFilm.Enable = true
+			cfg.LostFilm.Interval = time.Minute
+			cfg.LostFilm.Username = "user@example.com"
+			cfg.LostFilm.Password = "secret"
+		}, nil},
+		{"kinozal login without password", func(cfg *Config) {
+			cfg.Kinozal.Enable = true
+			cfg.Kinozal.Interval = time.Minute
+			cfg.Kinozal.Username = "user"
+		}, []string{"KINOZAL_PASSWORD"}},
+		{"kinozal without interval", func(cfg *Config) {
+			cfg.Kinozal.Enable = true
+			cfg.Kinozal.Cookie = "c"
+		}, []string{"KINOZAL_INTERVAL"}},
+		{"kinozal cron schedule replaces interval", func(cfg *Config) {
+			cfg.Kinozal.Enable = true
+			cfg.Kinozal.Cookie = "c"
+			cfg.Kinozal.Schedule = "*/5 * * * *"
+			cfg.Kinozal.QuietHours = "01:00-07:00"
+		}, nil},
+		{"lostfilm wrong schedule and quiet hours", func(cfg *Config) {
+			cfg.LostFilm.Enable = true
+			cfg.LostFilm.CookieName, cfg.LostFilm.CookieVal = "n", "v"
+			cfg.LostFilm.Schedule = "every minute"
+			cfg.Kinozal.Enable = true
+			cfg.Kinozal.Cookie = "c"
+			cfg.Kinozal.Interval = time.Minute
+			cfg.Kinozal.QuietHours = "night"
+		}, []string{"lostfilm schedule", "kinozal quiet hours"}},
+		{"telegram and wrong level", func(cfg *Config) {
+			cfg.Telegram.Enable = true
+			cfg.LogLevel = "LOUD"
+		}, []string{"TELEGRAM_TOKEN", "log level"}},
+		{"zero health threshold", func(cfg *Config) {
+			cfg.Health.FailureThreshold = 0
+		}, []string{"HEALTH_FAILURE_THRESHOLD"}},
+		{"wrong retention", func(cfg *Config) {
+			cfg.Twitch.ChannelRetentionDays = []string{"a:7", "b"}
+		}, []string{"retention b "}},
+		{"proxy without address", func(cfg *Config) {
+			cfg.Proxy.Enable = true
+			cfg.Proxy.CheckInterval = time.Minute
+		}, []string{"PROXY_URLS"}},
+		{"proxy wrong scheme and provider", func(cfg *Config) {
+			cfg.Proxy.Enable = true
+			cfg.Proxy.CheckInterval = time.Minute
+			cfg.Proxy.Urls = []string{"socks5://127.0.0.1:1080", "ftp://127.0.0.1:21"}
+			cfg.Proxy.Providers = []string{"kinozal", "vk"}
+		}, []string{"scheme must be socks5 or http", "provider vk"}},
+		{"proxy endpoint without allowed hosts", func(cfg *Config) {
+			cfg.Web.ProxyApiKey = "key"
+		}, []string{"WEB_PROXY_ALLOWED_HOSTS"}},
+		{"proxy with legacy socks5 address", func(cfg *Config) {
+			cfg.Proxy.Enable = true
+			cfg.Proxy.CheckInterval = time.Minute
+			cfg.Proxy.Socks5Addr = "127.0.0.1:1080"
+			cfg.Proxy.Providers = []string{"kinozal"}
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() error = nil, want %v", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want mention of %s", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/jobs"
+	"makarov.dev/bot/internal/storage"
+	"makarov.dev/bot/internal/storage/archive"
+	"net/http"
+	"time"
+)
+
+type AdminController struct {
+	Storage *storage.Storage
+	Jobs    *jobs.Supervisor
+	Logger  *logrus.Logger
+}
+
+func (c *AdminController) Add(g *gin.RouterGroup) {
+	g.GET("backup", c.backup())
+	g.POST("restore", c.restore())
+	g.GET("jobs", c.listJobs())
+}
+
+//	@Tags		Admin controller
+//	@Security	AdminToken
+//	@Produce	application/gzip
+//	@Success	200	{file}		file
+//	@Failure	401	{object}	HTTPError
+//	@Router		/admin/backup [get]
+func (c *AdminController) backup() func(ctx *gin.Context) {
+	log := c.Logger
+	return func(ctx *gin.Context) {
+		name := fmt.Sprintf("backup-%s.tar.gz", time.Now().Format("20060102-150405"))
+		ctx.Header("Content-Type", "application/gzip")
+		ctx.Header("Content-Disposition", "attachment; filename=\""+name+"\"")
+		ctx.Status(http.StatusOK)
+		// архив пишется прямо в ответ, после первого байта статус уже не поменять
+		err := archive.Export(ctx, c.Storage, ctx.Writer, archive.Options{Files: true})
+		if err != nil {
+			log.Errorf("Error while write backup %s", err.Error())
+			ctx.Abort()
+		}
+	}
+}
+
+//	@Tags		Admin controller
+//	@Security	AdminToken
+//	@Accept		application/gzip
+//	@Produce	json
+//	@Success	200	{object}	map[string]int
+//	@Failure	400,401,409	{object}	HTTPError
+//	@Router		/admin/restore [post]
+func (c *AdminController) restore() func(ctx *gin.Context) {
+	log := c.Logger
+	return func(ctx *gin.Context) {
+		counts, err := archive.Import(ctx, c.Storage, ctx.Request.Body)
+		if errors.Is(err, archive.ErrNotEmpty) {
+			NewError(ctx, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			log.Errorf("Error while restore backup %s", err.Error())
+			NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		log.Infof("Restored backup %v", counts)
+		ctx.JSON(http.StatusOK, counts)
+	}
+}
+
+//	@Tags		Admin controller
+//	@Security	AdminToken
+//	@Produce	json
+//	@Success	200	{array}		jobs.State
+//	@Failure	401	{object}	HTTPError
+//	@Router		/admin/jobs [get]
+func (c *AdminController) listJobs() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, c.Jobs.States())
+	}
+}
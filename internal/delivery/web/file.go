@@ -3,8 +3,9 @@ package web
 import (
 	"errors"
 	"fmt"
-	"makarov.dev/bot/internal/config"
+	"github.com/sirupsen/logrus"
 	"makarov.dev/bot/internal/integration/file"
+	"makarov.dev/bot/internal/storage"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +13,8 @@ import (
 )
 
 type FileController struct {
+	Files  *file.Service
+	Logger *logrus.Logger
 }
 
 func (c *FileController) Add(g *gin.RouterGroup) {
@@ -23,10 +26,10 @@ func (c *FileController) Add(g *gin.RouterGroup) {
 //	@Produce	octet-stream
 //	@Produce	json
 //	@Success	200		{file}		file
-//	@Failure	400,500	{object}	HTTPError
+//	@Failure	400,404,500	{object}	HTTPError
 //	@Router		/dl/{fileId} [get]
 func (c *FileController) downloadFile() func(ctx *gin.Context) {
-	log := config.GetLogger()
+	log := c.Logger
 	return func(ctx *gin.Context) {
 		fileId := ctx.Param("fileId")
 		if fileId == "" {
@@ -40,21 +43,25 @@ func (c *FileController) downloadFile() func(ctx *gin.Context) {
 			return
 		}
 
-		reader, err := file.GetFile(&objectID)
+		f, err := c.Files.GetFile(objectID)
+		if errors.Is(err, storage.ErrNotFound) {
+			NewError(ctx, 404, err)
+			return
+		}
 		if err != nil {
 			NewError(ctx, 500, err)
 			return
 		}
+		defer f.Close()
 		go func() {
-			err = file.Log(ctx, objectID)
+			err = c.Files.Log(ctx, objectID)
 			if err != nil {
 				log.Error(fmt.Sprintf("Error while log download fileId=%s", objectID), err.Error())
 			}
 		}()
-		f := reader.GetFile()
 		extraHeaders := map[string]string{
-			"Content-Disposition": "attachment; filename=\"" + f.Name + "\"",
+			"Content-Disposition": "attachment; filename=\"" + f.Name() + "\"",
 		}
-		ctx.DataFromReader(http.StatusOK, f.Length, f.Name, reader, extraHeaders)
+		ctx.DataFromReader(http.StatusOK, f.Length(), f.Name(), f, extraHeaders)
 	}
 }
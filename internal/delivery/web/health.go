@@ -0,0 +1,54 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"makarov.dev/bot/internal/health"
+	"net/http"
+	"time"
+)
+
+type HealthController struct {
+	// Checks собирает проверки на каждый запрос, чтобы учесть перезагрузку конфигурации
+	Checks  func() []health.Check
+	Started time.Time
+}
+
+// Liveness ответ /healthz
+type Liveness struct {
+	Status string `json:"status" example:"ok"`
+	Uptime string `json:"uptime" example:"1h2m3s"`
+}
+
+func (c *HealthController) Add(g *gin.RouterGroup) {
+	g.GET("healthz", c.healthz())
+	g.GET("readyz", c.readyz())
+}
+
+//	@Tags		Health controller
+//	@Produce	json
+//	@Success	200	{object}	Liveness
+//	@Router		/healthz [get]
+func (c *HealthController) healthz() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, Liveness{
+			Status: health.StatusOk,
+			Uptime: time.Since(c.Started).Round(time.Second).String(),
+		})
+	}
+}
+
+//	@Tags		Health controller
+//	@Produce	json
+//	@Success	200	{object}	health.Report	"ok or degraded"
+//	@Failure	503	{object}	health.Report	"critical dependency failed"
+//	@Router		/readyz [get]
+func (c *HealthController) readyz() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		report := health.Run(ctx, c.Checks())
+		status := http.StatusOK
+		if report.Status == health.StatusFail {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, report)
+	}
+}
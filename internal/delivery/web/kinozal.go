@@ -2,16 +2,23 @@ package web
 
 import (
 	"github.com/gin-gonic/gin"
-	"makarov.dev/bot/internal/config"
+	"github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/cache"
 	"makarov.dev/bot/internal/integration/kinozal"
 	"time"
 )
 
 type KinozalController struct {
+	Service *kinozal.Service
+	Cache   cache.Cache
+	Domain  string
+	Logger  *logrus.Logger
 }
 
 func (c *KinozalController) Add(g *gin.RouterGroup) {
 	cacheMiddleware := CacheMiddleware(
+		c.Cache,
+		c.Logger,
 		200,
 		"application/xml; charset=utf-8",
 		func(c *gin.Context) string {
@@ -30,7 +37,7 @@ func (c *KinozalController) Add(g *gin.RouterGroup) {
 //	@Router		/kinozal/rss [get]
 func (c *KinozalController) rss() func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
-		episodes, err := kinozal.LastEpisodes(ctx)
+		episodes, err := c.Service.LastEpisodes(ctx)
 		if err != nil {
 			NewError(ctx, 500, err)
 			return
@@ -54,7 +61,7 @@ func (c *KinozalController) rss() func(ctx *gin.Context) {
 		for _, episode := range episodes {
 			rss.Channel.Items = append(rss.Channel.Items, RssChannelItem{
 				Title:   episode.Name,
-				Link:    config.GetConfig().Web.Domain + "/dl/" + episode.GridFsId.Hex(),
+				Link:    c.Domain + "/dl/" + episode.GridFsId.Hex(),
 				PubDate: episode.Created.Format(dateLayout),
 				Uid:     episode.Id.Hex(),
 			})
@@ -2,7 +2,8 @@ package web
 
 import (
 	"github.com/gin-gonic/gin"
-	"makarov.dev/bot/internal/config"
+	"github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/cache"
 	"makarov.dev/bot/internal/integration/lostfilm"
 	"time"
 )
@@ -33,10 +34,16 @@ type RssChannelItem struct {
 }
 
 type LostFilmController struct {
+	Service *lostfilm.Service
+	Cache   cache.Cache
+	Domain  string
+	Logger  *logrus.Logger
 }
 
 func (c *LostFilmController) Add(g *gin.RouterGroup) {
 	cacheMiddleware := CacheMiddleware(
+		c.Cache,
+		c.Logger,
 		200,
 		"application/xml; charset=utf-8",
 		func(c *gin.Context) string {
@@ -57,7 +64,7 @@ func (c *LostFilmController) Add(g *gin.RouterGroup) {
 func (c *LostFilmController) rss() func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
 		quality := ctx.Query("quality")
-		episodes, err := lostfilm.FindLatest(ctx)
+		episodes, err := c.Service.FindLatest(ctx)
 		if err != nil {
 			NewError(ctx, 500, err)
 			return
@@ -81,7 +88,7 @@ func (c *LostFilmController) rss() func(ctx *gin.Context) {
 				}
 				rss.Channel.Items = append(rss.Channel.Items, RssChannelItem{
 					Title:        episode.Name + ". " + episode.EpisodeNameFull,
-					Link:         config.GetConfig().Web.Domain + "/dl/" + file.GridFsId.Hex(),
+					Link:         c.Domain + "/dl/" + file.GridFsId.Hex(),
 					PubDate:      episode.Created.Format(dateLayout),
 					Description:  file.Description,
 					OriginalDate: episode.Date.Format(dateLayout),
@@ -3,9 +3,13 @@ package web
 import (
 	"bytes"
 	"context"
+	"crypto/subtle"
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
-	"makarov.dev/bot/internal/config"
+	"github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/cache"
+	"makarov.dev/bot/internal/metrics"
+	"net/http"
 	"time"
 )
 
@@ -24,10 +28,36 @@ func (w bodyLogWriter) WriteString(s string) (int, error) {
 	return w.ResponseWriter.WriteString(s)
 }
 
-func CacheMiddleware(okCode int, contentType string, keyGen func(c *gin.Context) string, ex time.Duration) gin.HandlerFunc {
+// AdminMiddleware пропускает только запросы с заголовком Authorization: Bearer <token>
+func AdminMiddleware(token string) gin.HandlerFunc {
+	expected := []byte("Bearer " + token)
 	return func(c *gin.Context) {
-		cfg := config.GetConfig().Redis
-		if !cfg.Enable {
+		actual := []byte(c.GetHeader("Authorization"))
+		if subtle.ConstantTimeCompare(actual, expected) != 1 {
+			NewError(c, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ApiKeyMiddleware пропускает только запросы с ключом в заголовке X-Api-Key. Параметр запроса не принимается:
+// ключ из url попадает в логи и историю браузера
+func ApiKeyMiddleware(key string) gin.HandlerFunc {
+	expected := []byte(key)
+	return func(c *gin.Context) {
+		actual := c.GetHeader("X-Api-Key")
+		if subtle.ConstantTimeCompare([]byte(actual), expected) != 1 {
+			NewError(c, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		c.Next()
+	}
+}
+
+func CacheMiddleware(cache cache.Cache, logger *logrus.Logger, okCode int, contentType string, keyGen func(c *gin.Context) string, ex time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cache.Enabled() {
 			return
 		}
 
@@ -35,19 +65,24 @@ func CacheMiddleware(okCode int, contentType string, keyGen func(c *gin.Context)
 		c.Writer = blw
 
 		key := keyGen(c)
-		b, err := config.GetRedis().Get(c, key).Bytes()
-		if err == nil || err != redis.Nil {
-			config.GetLogger().Tracef("Used redis cache for %s", key)
+		b, err := cache.Get(c, key)
+		if err == nil {
+			metrics.CacheRequests.WithLabelValues("hit").Inc()
+			logger.Tracef("Used cache for %s", key)
 			c.Data(okCode, contentType, b)
 			c.Abort()
 			return
 		}
+		metrics.CacheRequests.WithLabelValues("miss").Inc()
 
 		c.Next()
 
 		go func() {
-			config.GetLogger().Tracef("Set cache %s", key)
-			config.GetRedis().Set(context.Background(), key, blw.body.String(), ex)
+			logger.Tracef("Set cache %s", key)
+			err := cache.Set(context.Background(), key, blw.body.Bytes(), ex)
+			if err != nil {
+				logger.Errorf("Error while set cache %s %s", key, err.Error())
+			}
 		}()
 
 	}
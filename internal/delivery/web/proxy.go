@@ -1,29 +1,84 @@
 package web
 
 import (
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"io"
 	intg "makarov.dev/bot/internal/integration/proxy"
+	"net/http"
+	"strconv"
 )
 
+// proxyHeaders заголовки ответа сайта, которые передаются клиенту
+var proxyHeaders = []string{"ETag", "Last-Modified", "Cache-Control", "Expires"}
+
 type ProxyController struct {
+	Fetcher *intg.Fetcher
+	Logger  *logrus.Logger
 }
 
-func (p ProxyController) Add(g *gin.RouterGroup) {
-	g.GET("", proxy)
+func (p *ProxyController) Add(g *gin.RouterGroup) {
+	g.GET("", p.proxy())
 }
 
 //	@Tags		Proxy controller
-//	@Param		url							query	string	true	"Url for proxied GET request"
+//	@Param		X-Api-Key					header	string	true	"API key"
+//	@Param		url							query	string	true	"Url for proxied GET request, host must be allowed"
 //	@Param		responseHeaderContentType	query	string	false	"override content-type header"
+//	@Param		raw							query	bool	false	"Do not convert response charset to UTF-8"
 //	@Produce	plain
+//	@Success	200				{string}	string	"Response of the requested url with its status code"
+//	@Failure	400,401,403,413,502	{object}	HTTPError
+//	@Failure	504				{object}	HTTPError
 //	@Router		/proxy [get]
-func proxy(ctx *gin.Context) {
-	url := ctx.Query("url")
-	responseHeaderContentType := ctx.Query("responseHeaderContentType")
-	res, header := intg.Get(url)
-	if responseHeaderContentType == "" {
-		responseHeaderContentType = header.Get("Content-Type")
+func (p *ProxyController) proxy() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		res, err := p.Fetcher.Get(ctx.Request.Context(), ctx.Query("url"))
+		switch {
+		case errors.Is(err, intg.ErrBadUrl):
+			NewError(ctx, http.StatusBadRequest, err)
+			return
+		case errors.Is(err, intg.ErrHostNotAllowed), errors.Is(err, intg.ErrPrivateAddress):
+			NewError(ctx, http.StatusForbidden, err)
+			return
+		case errors.Is(err, intg.ErrTooLarge):
+			NewError(ctx, http.StatusRequestEntityTooLarge, err)
+			return
+		case errors.Is(err, context.DeadlineExceeded):
+			NewError(ctx, http.StatusGatewayTimeout, err)
+			return
+		case err != nil:
+			NewError(ctx, http.StatusBadGateway, err)
+			return
+		}
+		defer res.Body.Close()
+
+		body, contentType := io.Reader(res.Body), res.Header.Get("Content-Type")
+		if raw, _ := strconv.ParseBool(ctx.Query("raw")); raw {
+			if res.Header.Get("Content-Length") != "" {
+				ctx.Header("Content-Length", res.Header.Get("Content-Length"))
+			}
+		} else {
+			body, contentType = intg.Decode(res)
+		}
+		if override := ctx.Query("responseHeaderContentType"); override != "" {
+			contentType = override
+		}
+		ctx.Header("Content-Type", contentType)
+		for _, header := range proxyHeaders {
+			if value := res.Header.Get(header); value != "" {
+				ctx.Header(header, value)
+			}
+		}
+		ctx.Status(res.StatusCode)
+		if _, err := io.Copy(ctx.Writer, body); err != nil {
+			p.Logger.Errorf("Error while proxy %s %s", ctx.Query("url"), err.Error())
+			// статус уже отправлен, обрыв соединения не даст принять неполный ответ за целый
+			if conn, _, hijackErr := ctx.Writer.Hijack(); hijackErr == nil {
+				_ = conn.Close()
+			}
+		}
 	}
-	ctx.Header("Content-Type", responseHeaderContentType)
-	ctx.String(200, res)
 }
@@ -1,28 +1,48 @@
 package web
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"makarov.dev/bot/internal/integration/twitch"
+	"strconv"
+	"time"
 )
 
 type TwitchController struct {
+	Service *twitch.Service
+	Logger  *logrus.Logger
+}
+
+type TwitchChannelRequest struct {
+	Name string `json:"name" example:"tushqa"`
 }
 
 func (c *TwitchController) Add(g *gin.RouterGroup) {
 	g.GET("/messages", c.messages())
 	g.GET("/tushqa", c.tushqaQuotes())
+	g.GET("/channels", c.channels())
+	g.GET("/export", c.export())
+	g.GET("/stats", c.stats())
+	g.GET("/streams", c.streams())
+}
+
+// AddAdmin регистрирует изменение списка каналов, g должна быть защищена AdminMiddleware
+func (c *TwitchController) AddAdmin(g *gin.RouterGroup) {
+	g.POST("/channels", c.joinChannel())
+	g.DELETE("/channels/:channel", c.partChannel())
 }
 
 //	@Tags		Twitch controller
 //	@Param		channel	query	string	false	"Channel filter"
 //	@Param		limit	query	int		false	"Message list limit"	maximum(100)
 //	@Produce	json
-//	@Success	200		{array}		twitch.ChatMessage
+//	@Success	200		{array}		storage.ChatMessage
 //	@Failure	400,500	{object}	HTTPError
 //	@Router		/twitch/messages [get]
 func (c *TwitchController) messages() func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
-		data, err := twitch.GetLastMessages(ctx.Query("channel"), ctx.Query("limit"))
+		data, err := c.Service.GetLastMessages(ctx.Query("channel"), ctx.Query("limit"))
 		if err != nil {
 			NewError(ctx, 500, err)
 			return
@@ -34,12 +54,28 @@ func (c *TwitchController) messages() func(ctx *gin.Context) {
 //	@Tags		Twitch controller
 //	@Param		limit	query	int	false	"Quotes limit"	maximum(100)
 //	@Produce	json
-//	@Success	200		{array}		twitch.TushqaQuote
+//	@Success	200		{array}		storage.TushqaQuote
 //	@Failure	400,500	{object}	HTTPError
 //	@Router		/twitch/tushqa [get]
 func (c *TwitchController) tushqaQuotes() func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
-		data, err := twitch.GetTushqaQuotes(ctx.Query("limit"))
+		data, err := c.Service.GetTushqaQuotes(ctx.Query("limit"))
+		if err != nil {
+			NewError(ctx, 500, err)
+			return
+		}
+		ctx.JSON(200, &data)
+	}
+}
+
+//	@Tags		Twitch controller
+//	@Produce	json
+//	@Success	200	{array}		storage.TwitchChannel
+//	@Failure	500	{object}	HTTPError
+//	@Router		/twitch/channels [get]
+func (c *TwitchController) channels() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		data, err := c.Service.GetChannels()
 		if err != nil {
 			NewError(ctx, 500, err)
 			return
@@ -47,3 +83,166 @@ func (c *TwitchController) tushqaQuotes() func(ctx *gin.Context) {
 		ctx.JSON(200, &data)
 	}
 }
+
+//	@Tags		Twitch controller
+//	@Security	AdminToken
+//	@Param		channel	body	TwitchChannelRequest	true	"Channel to join"
+//	@Accept		json
+//	@Produce	json
+//	@Success	200				{object}	storage.TwitchChannel
+//	@Failure	400,401,409,500	{object}	HTTPError
+//	@Router		/twitch/channels [post]
+func (c *TwitchController) joinChannel() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		req := TwitchChannelRequest{}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			NewError(ctx, 400, err)
+			return
+		}
+		channel, err := c.Service.JoinChannel(req.Name)
+		if err != nil {
+			NewError(ctx, channelErrorStatus(err), err)
+			return
+		}
+		ctx.JSON(200, channel)
+	}
+}
+
+//	@Tags		Twitch controller
+//	@Security	AdminToken
+//	@Param		channel	path	string	true	"Channel name"
+//	@Produce	json
+//	@Success	204
+//	@Failure	400,401,404,500	{object}	HTTPError
+//	@Router		/twitch/channels/{channel} [delete]
+func (c *TwitchController) partChannel() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		err := c.Service.PartChannel(ctx.Param("channel"))
+		if err != nil {
+			NewError(ctx, channelErrorStatus(err), err)
+			return
+		}
+		ctx.Status(204)
+	}
+}
+
+//	@Tags		Twitch controller
+//	@Param		channel		query	string	true	"Channel"
+//	@Param		from		query	string	true	"Range start (RFC3339 or 2006-01-02)"
+//	@Param		to			query	string	false	"Range end (RFC3339 or 2006-01-02), now by default"
+//	@Param		format		query	string	false	"Export format"	Enums(jsonl, csv, vtt, ass)	default(jsonl)
+//	@Param		streamStart	query	string	false	"Stream start for vtt and ass cue alignment, from by default"
+//	@Produce	plain
+//	@Success	200		{file}		file
+//	@Failure	400,500	{object}	HTTPError
+//	@Router		/twitch/export [get]
+func (c *TwitchController) export() func(ctx *gin.Context) {
+	log := c.Logger
+	return func(ctx *gin.Context) {
+		opts := twitch.ExportOptions{Channel: ctx.Query("channel")}
+		if opts.Channel == "" {
+			NewError(ctx, 400, twitch.ErrEmptyExportChannel)
+			return
+		}
+		var err error
+		if opts.Format, err = twitch.ParseExportFormat(ctx.Query("format")); err != nil {
+			NewError(ctx, 400, err)
+			return
+		}
+		if opts.From, err = twitch.ParseExportTime(ctx.Query("from")); err != nil {
+			NewError(ctx, 400, err)
+			return
+		}
+		if opts.To, err = parseOptionalTime(ctx.Query("to")); err != nil {
+			NewError(ctx, 400, err)
+			return
+		}
+		if opts.StreamStart, err = parseOptionalTime(ctx.Query("streamStart")); err != nil {
+			NewError(ctx, 400, err)
+			return
+		}
+
+		ctx.Header("Content-Type", opts.Format.ContentType())
+		ctx.Header("Content-Disposition", "attachment; filename=\""+opts.Format.FileName(opts.Channel)+"\"")
+		ctx.Status(200)
+		err = c.Service.Export(ctx, ctx.Writer, opts)
+		if err != nil {
+			log.Errorf("Error while export twitch channel %s %s", opts.Channel, err.Error())
+		}
+	}
+}
+
+//	@Tags		Twitch controller
+//	@Param		channel	query	string	false	"Channel filter"
+//	@Param		from	query	string	true	"Range start (RFC3339 or 2006-01-02)"
+//	@Param		to		query	string	false	"Range end (RFC3339 or 2006-01-02), now by default"
+//	@Produce	json
+//	@Success	200		{array}		storage.ChatRollup
+//	@Failure	400,500	{object}	HTTPError
+//	@Router		/twitch/stats [get]
+func (c *TwitchController) stats() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		from, err := twitch.ParseExportTime(ctx.Query("from"))
+		if err != nil {
+			NewError(ctx, 400, err)
+			return
+		}
+		to, err := parseOptionalTime(ctx.Query("to"))
+		if err != nil {
+			NewError(ctx, 400, err)
+			return
+		}
+		if to.IsZero() {
+			to = time.Now()
+		}
+		data, err := c.Service.GetRollups(ctx, ctx.Query("channel"), from, to)
+		if err != nil {
+			NewError(ctx, 500, err)
+			return
+		}
+		ctx.JSON(200, &data)
+	}
+}
+
+//	@Tags		Twitch controller
+//	@Param		channel	query	string	false	"Channel filter"
+//	@Param		limit	query	int		false	"Stream list limit"	maximum(100)
+//	@Produce	json
+//	@Success	200		{array}		storage.StreamSession
+//	@Failure	400,500	{object}	HTTPError
+//	@Router		/twitch/streams [get]
+func (c *TwitchController) streams() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		limit, err := strconv.ParseInt(ctx.DefaultQuery("limit", "20"), 10, 64)
+		if err != nil || limit <= 0 || limit > 100 {
+			NewError(ctx, 400, errors.New("wrong limit"))
+			return
+		}
+		data, err := c.Service.GetStreamSessions(ctx, ctx.Query("channel"), limit)
+		if err != nil {
+			NewError(ctx, 500, err)
+			return
+		}
+		ctx.JSON(200, &data)
+	}
+}
+
+func parseOptionalTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return twitch.ParseExportTime(s)
+}
+
+func channelErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, twitch.ErrEmptyChannel):
+		return 400
+	case errors.Is(err, twitch.ErrChannelNotWatched):
+		return 404
+	case errors.Is(err, twitch.ErrChannelAlreadyWatched):
+		return 409
+	default:
+		return 500
+	}
+}
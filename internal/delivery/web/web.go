@@ -13,7 +13,9 @@ import (
 	"github.com/gin-gonic/gin"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
-	"makarov.dev/bot/internal/config"
+	"makarov.dev/bot/internal/app"
+	intg "makarov.dev/bot/internal/integration/proxy"
+	"makarov.dev/bot/internal/metrics"
 )
 
 type Controller interface {
@@ -35,10 +37,10 @@ type HTTPError struct {
 	Message string `json:"message" example:"status bad request"`
 }
 
-func StartWeb(ctx context.Context) {
-	cfg := config.GetConfig()
+func StartWeb(ctx context.Context, a *app.App) {
+	cfg := a.Config
 	webCfg := cfg.Web
-	log := config.GetLogger()
+	log := a.Logger
 
 	docs.SwaggerInfo.BasePath = "/"
 
@@ -63,42 +65,64 @@ func StartWeb(ctx context.Context) {
 
 	gin.SetMode(webCfg.Mode)
 	r := gin.New()
-	r.Use(logger, gin.Recovery())
+	r.Use(logger, gin.Recovery(), metrics.Middleware())
 
 	if cfg.Debug {
 		pprof.Register(r, "dev/pprof")
 	}
 
+	healthGroup := r.Group("/")
+	{
+		ctr := HealthController{Checks: a.ReadinessChecks, Started: a.Started}
+		ctr.Add(healthGroup)
+	}
+
 	lfGroup := r.Group("/lostfilm")
 	{
-		ctr := LostFilmController{}
+		ctr := LostFilmController{Service: a.LostFilm, Cache: a.Cache, Domain: webCfg.Domain, Logger: log}
 		ctr.Add(lfGroup)
 	}
 
 	kinozalGroup := r.Group("/kinozal")
 	{
-		ctr := KinozalController{}
+		ctr := KinozalController{Service: a.Kinozal, Cache: a.Cache, Domain: webCfg.Domain, Logger: log}
 		ctr.Add(kinozalGroup)
 	}
 
 	fileGroup := r.Group("/dl")
 	{
-		ctr := FileController{}
+		ctr := FileController{Files: a.Files, Logger: log}
 		ctr.Add(fileGroup)
 	}
 
 	twitchGroup := r.Group("/twitch")
 	{
-		ctr := TwitchController{}
+		ctr := TwitchController{Service: a.Twitch, Logger: log}
 		ctr.Add(twitchGroup)
+		// без токена каналы меняются только из конфигурации
+		if webCfg.AdminToken != "" {
+			ctr.AddAdmin(r.Group("/twitch", AdminMiddleware(webCfg.AdminToken)))
+		}
 	}
 
-	proxyGroup := r.Group("/proxy")
-	{
-		ctr := ProxyController{}
-		ctr.Add(proxyGroup)
+	if webCfg.ProxyApiKey != "" {
+		proxyGroup := r.Group("/proxy", ApiKeyMiddleware(webCfg.ProxyApiKey))
+		{
+			fetcher := &intg.Fetcher{AllowedHosts: webCfg.ProxyAllowedHosts, MaxBytes: webCfg.ProxyMaxBytes}
+			ctr := ProxyController{Fetcher: fetcher, Logger: log}
+			ctr.Add(proxyGroup)
+		}
+	}
+
+	if webCfg.AdminToken != "" {
+		adminGroup := r.Group("/admin", AdminMiddleware(webCfg.AdminToken))
+		{
+			ctr := AdminController{Storage: a.Storage, Jobs: a.Jobs, Logger: log}
+			ctr.Add(adminGroup)
+		}
 	}
 
+	r.GET("/metrics", metrics.Handler())
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
 	srv := &http.Server{
@@ -113,9 +137,12 @@ func StartWeb(ctx context.Context) {
 	}()
 
 	<-ctx.Done()
-	err := srv.Shutdown(ctx)
+	// ctx уже отменен, запросам в обработке дается отдельное время на завершение
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
 	if err != nil {
-		log.Fatalf("Error while shutdown web %s", err.Error())
+		log.Errorf("Error while shutdown web %s", err.Error())
 	}
 
 	log.Infof("Web stopped")
@@ -0,0 +1,154 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/notify"
+)
+
+// Sample замер одного разбора страницы трекера
+type Sample struct {
+	Provider string        `json:"provider"`
+	Page     string        `json:"page"`
+	Url      string        `json:"url"`
+	Status   int           `json:"status"`
+	Latency  time.Duration `json:"latency"`
+	Items    int           `json:"items"`
+	Missing  []string      `json:"missing,omitempty"`
+	Err      string        `json:"error,omitempty"`
+	Time     time.Time     `json:"time"`
+}
+
+// Ok страница загружена и разобрана полностью
+func (s Sample) Ok() bool {
+	return s.Err == "" && s.Items > 0 && len(s.Missing) == 0
+}
+
+// problem причина неудачного замера для оповещения
+func (s Sample) problem() string {
+	switch {
+	case s.Err != "":
+		return "ошибка " + s.Err
+	case len(s.Missing) > 0:
+		return "не найдены селекторы " + strings.Join(s.Missing, ", ")
+	default:
+		return "ноль элементов"
+	}
+}
+
+// State состояние страницы провайдера
+type State struct {
+	Last Sample `json:"last"`
+	// Failures неудачные замеры подряд
+	Failures int `json:"failures"`
+	// Alerted оповещение о сбое отправлено и еще не снято восстановлением
+	Alerted bool `json:"alerted"`
+}
+
+// Monitor собирает замеры скраперов и оповещает администраторов, когда страница несколько опросов подряд
+// не разбирается. Обычно это значит, что сайт поменял верстку или истекла сессия
+type Monitor struct {
+	// Notifiers каналы доставки оповещений администраторам
+	Notifiers []notify.Notifier
+	Logger    *log.Logger
+
+	mutex  sync.Mutex
+	states map[string]*State
+	// lastOk время последнего удачного замера по провайдерам
+	lastOk map[string]time.Time
+}
+
+// Record сохраняет замер. Безопасен для вызова из разных горутин
+func (m *Monitor) Record(s Sample) {
+	if s.Time.IsZero() {
+		s.Time = time.Now()
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.states == nil {
+		m.states = make(map[string]*State)
+		m.lastOk = make(map[string]time.Time)
+	}
+	key := s.Provider + "/" + s.Page
+	state, found := m.states[key]
+	if !found {
+		state = &State{}
+		m.states[key] = state
+	}
+	state.Last = s
+	if s.Ok() {
+		state.Failures = 0
+		m.lastOk[s.Provider] = s.Time
+	} else {
+		state.Failures++
+		m.Logger.Warnf("Scrape of %s %s failed %d times in a row: %s", s.Provider, s.Page, state.Failures, s.problem())
+	}
+}
+
+// LastSuccess время последней удачно разобранной страницы провайдера, нулевое если такой еще не было
+func (m *Monitor) LastSuccess(provider string) time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.lastOk[provider]
+}
+
+// States копия состояний, отсортированная по провайдеру и странице
+func (m *Monitor) States() []State {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	states := make([]State, 0, len(m.states))
+	for _, state := range m.states {
+		states = append(states, *state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Last.Provider != states[j].Last.Provider {
+			return states[i].Last.Provider < states[j].Last.Provider
+		}
+		return states[i].Last.Page < states[j].Last.Page
+	})
+	return states
+}
+
+// Check отправляет оповещения о страницах, которые не разбираются threshold опросов подряд, и о восстановлении
+// страниц после такого оповещения. Каждое оповещение отправляется один раз
+func (m *Monitor) Check(ctx context.Context, threshold int) {
+	messages := m.alerts(threshold)
+	for _, text := range messages {
+		m.Logger.Warnf("Scraper health alert: %s", text)
+		notify.NotifyAll(ctx, m.Notifiers, notify.Notification{Text: text}, func(notifier notify.Notifier, err error) {
+			m.Logger.Errorf("Error while send health alert to %s %s", notifier.Name(), err.Error())
+		})
+	}
+	if len(messages) == 0 {
+		m.Logger.Debugf("Scrapers health ok")
+	}
+}
+
+func (m *Monitor) alerts(threshold int) []string {
+	messages := make([]string, 0)
+	for _, state := range m.States() {
+		s := state.Last
+		switch {
+		case state.Failures >= threshold && !state.Alerted:
+			messages = append(messages, fmt.Sprintf("%s %s: %d опросов подряд не разобраны, %s. %s",
+				s.Provider, s.Page, state.Failures, s.problem(), s.Url))
+			m.setAlerted(s, true)
+		case state.Failures == 0 && state.Alerted:
+			messages = append(messages, fmt.Sprintf("%s %s: снова разбирается, элементов %d", s.Provider, s.Page, s.Items))
+			m.setAlerted(s, false)
+		}
+	}
+	return messages
+}
+
+func (m *Monitor) setAlerted(s Sample, alerted bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.states[s.Provider+"/"+s.Page].Alerted = alerted
+}
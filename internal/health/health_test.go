@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/notify"
+)
+
+type notifierMock struct {
+	sent []string
+}
+
+func (n *notifierMock) Name() string {
+	return "mock"
+}
+
+func (n *notifierMock) Notify(_ context.Context, msg notify.Notification) error {
+	n.sent = append(n.sent, msg.Text)
+	return nil
+}
+
+func TestMonitor_Check(t *testing.T) {
+	n := &notifierMock{}
+	m := &Monitor{Notifiers: []notify.Notifier{n}, Logger: log.New()}
+	ctx := context.Background()
+
+	m.Record(Sample{Provider: "kinozal", Page: "root", Items: 50})
+	m.Record(Sample{Provider: "kinozal", Page: "root", Missing: []string{"tr a[href*=id]"}})
+	m.Check(ctx, 2)
+	if len(n.sent) != 0 {
+		t.Fatalf("alert after 1 failure: %v", n.sent)
+	}
+
+	m.Record(Sample{Provider: "kinozal", Page: "root", Err: errors.New("wrong status code").Error()})
+	m.Check(ctx, 2)
+	m.Check(ctx, 2)
+	if len(n.sent) != 1 || !strings.Contains(n.sent[0], "kinozal root: 2") {
+		t.Fatalf("want one alert after 2 failures, got %v", n.sent)
+	}
+
+	m.Record(Sample{Provider: "kinozal", Page: "root", Items: 50})
+	m.Check(ctx, 2)
+	if len(n.sent) != 2 || !strings.Contains(n.sent[1], "снова разбирается") {
+		t.Fatalf("want recovery alert, got %v", n.sent)
+	}
+	if states := m.States(); len(states) != 1 || states[0].Failures != 0 || states[0].Alerted {
+		t.Errorf("States() = %+v", states)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return errors.New("down") }
+	tests := []struct {
+		name   string
+		checks []Check
+		want   string
+	}{
+		{"all ok", []Check{{Name: "database", Critical: true, Run: ok}, {Name: "telegram", Run: ok}}, StatusOk},
+		{"optional failed", []Check{{Name: "database", Critical: true, Run: ok}, {Name: "telegram", Run: fail}}, StatusDegraded},
+		{"critical failed", []Check{{Name: "database", Critical: true, Run: fail}, {Name: "telegram", Run: fail}}, StatusFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Run(context.Background(), tt.checks)
+			if report.Status != tt.want || len(report.Checks) != len(tt.checks) {
+				t.Errorf("Run() = %+v, want status %s", report, tt.want)
+			}
+			if report.Checks[0].Name != "database" {
+				t.Errorf("Run() changed check order %+v", report.Checks)
+			}
+		})
+	}
+}
+
+func TestMonitor_Fresh(t *testing.T) {
+	m := &Monitor{Logger: log.New()}
+	ctx := context.Background()
+	if err := m.Fresh("kinozal", time.Now(), time.Minute)(ctx); err != nil {
+		t.Errorf("just started Fresh() error = %v", err)
+	}
+	if err := m.Fresh("kinozal", time.Now().Add(-time.Hour), time.Minute)(ctx); err == nil {
+		t.Errorf("Fresh() without successful scrapes since an hour error = nil")
+	}
+	m.Record(Sample{Provider: "kinozal", Page: "root", Items: 1, Time: time.Now().Add(-2 * time.Minute)})
+	if err := m.Fresh("kinozal", time.Now().Add(-time.Hour), time.Minute)(ctx); err == nil {
+		t.Errorf("Fresh() with stale scrape error = nil")
+	}
+	m.Record(Sample{Provider: "kinozal", Page: "root", Items: 1})
+	if err := m.Fresh("kinozal", time.Now().Add(-time.Hour), time.Minute)(ctx); err != nil {
+		t.Errorf("Fresh() error = %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOk       = "ok"
+	StatusDegraded = "degraded"
+	StatusFail     = "fail"
+)
+
+// checkTimeout время на одну проверку, чтобы зависшая зависимость не задерживала весь отчет
+const checkTimeout = 5 * time.Second
+
+// Check проверка зависимости для /readyz
+type Check struct {
+	Name string
+	// Critical без этой зависимости сервис не готов. Сбой остальных только помечает отчет как degraded
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Name     string `json:"name"`
+	Ok       bool   `json:"ok"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Latency  string `json:"latency"`
+}
+
+// Report результат всех проверок. Status: ok, degraded или fail
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Run выполняет проверки параллельно. Порядок результатов совпадает с порядком checks
+func Run(ctx context.Context, checks []Check) Report {
+	results := make([]CheckResult, len(checks))
+	wg := sync.WaitGroup{}
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOk, Checks: results}
+	for _, r := range results {
+		switch {
+		case r.Ok:
+		case r.Critical:
+			report.Status = StatusFail
+		case report.Status == StatusOk:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	start := time.Now()
+	// не каждая зависимость принимает контекст, поэтому таймаут соблюдается и без ее участия
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Run(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	r := CheckResult{Name: check.Name, Ok: err == nil, Critical: check.Critical, Latency: time.Since(start).String()}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// Fresh проверка, что провайдер удачно разбирал страницы не позже maxAge назад. До первого удачного
+// замера отсчет идет от since, чтобы только что запущенный сервис не считался сломанным
+func (m *Monitor) Fresh(provider string, since time.Time, maxAge time.Duration) func(ctx context.Context) error {
+	return func(context.Context) error {
+		last := m.LastSuccess(provider)
+		if last.IsZero() {
+			if time.Since(since) <= maxAge {
+				return nil
+			}
+			return fmt.Errorf("no successful scrape since start %s", since.Format(time.RFC3339))
+		}
+		if age := time.Since(last); age > maxAge {
+			return fmt.Errorf("last successful scrape %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+}
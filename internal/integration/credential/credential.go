@@ -0,0 +1,99 @@
+package credential
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/storage"
+	kinozalClient "makarov.dev/bot/pkg/kinozal"
+	lfClient "makarov.dev/bot/pkg/lostfilm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	LostFilm = "lostfilm"
+	Kinozal  = "kinozal"
+)
+
+var (
+	ErrUnknownTracker = errors.New("unknown tracker, expected lostfilm or kinozal")
+	ErrEmptyCookie    = errors.New("empty cookie")
+)
+
+// Service сессии трекеров: хранит cookie в базе и применяет их к клиентам без перезапуска
+type Service struct {
+	Credentials    storage.TrackerCredentialRepository
+	LostFilmClient *lfClient.Client
+	KinozalClient  *kinozalClient.Client
+	// LostFilmCookieName имя cookie LostFilm, если в команде передано только значение
+	LostFilmCookieName string
+	Logger             *log.Logger
+}
+
+// Load применяет сохраненные сессии. Они заменяют cookie из конфигурации, так как сохраняются позже нее
+func (s *Service) Load(ctx context.Context) error {
+	credentials, err := s.Credentials.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range credentials {
+		if err = s.apply(&c); err != nil {
+			s.Logger.Warnf("Skip stored %s credential %s", c.Tracker, err.Error())
+			continue
+		}
+		s.Logger.Infof("Loaded %s cookie stored at %s", c.Tracker, c.Updated.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Set сохраняет cookie трекера и сразу применяет ее. Для LostFilm value принимается и в виде name=value
+func (s *Service) Set(tracker string, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ErrEmptyCookie
+	}
+	c := &storage.TrackerCredential{Tracker: tracker, Value: value}
+	if tracker == LostFilm {
+		c.Name = s.LostFilmCookieName
+		if name, val, found := strings.Cut(value, "="); found && name != "" && !strings.Contains(val, ";") {
+			c.Name, c.Value = name, val
+		}
+	}
+	return s.Save(c)
+}
+
+// Save заменяет сохраненную сессию трекера и применяет ее к клиенту
+func (s *Service) Save(c *storage.TrackerCredential) error {
+	if err := s.apply(c); err != nil {
+		return err
+	}
+	c.Id = primitive.NewObjectID()
+	c.Updated = time.Now()
+	ctx, cancel := getContext()
+	defer cancel()
+	if err := s.Credentials.Upsert(ctx, c); err != nil {
+		return fmt.Errorf("store %s cookie %w", c.Tracker, err)
+	}
+	s.Logger.Infof("Stored new %s cookie", c.Tracker)
+	return nil
+}
+
+func (s *Service) apply(c *storage.TrackerCredential) error {
+	switch c.Tracker {
+	case LostFilm:
+		s.LostFilmClient.SetCookie(http.Cookie{Name: c.Name, Value: c.Value})
+	case Kinozal:
+		s.KinozalClient.SetCookie(c.Value)
+	default:
+		return ErrUnknownTracker
+	}
+	return nil
+}
+
+func getContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 10*time.Second)
+}
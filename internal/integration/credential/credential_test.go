@@ -0,0 +1,60 @@
+package credential
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/storage/memory"
+	kinozalClient "makarov.dev/bot/pkg/kinozal"
+	lfClient "makarov.dev/bot/pkg/lostfilm"
+)
+
+func newService() *Service {
+	return &Service{
+		Credentials:        memory.New().Credentials,
+		LostFilmClient:     &lfClient.Client{},
+		KinozalClient:      &kinozalClient.Client{},
+		LostFilmCookieName: "lf_session",
+		Logger:             log.New(),
+	}
+}
+
+func TestService_Set(t *testing.T) {
+	s := newService()
+	if err := s.Set(LostFilm, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if c := s.LostFilmClient.Config.Cookie; c.Name != "lf_session" || c.Value != "abc" {
+		t.Errorf("lostfilm cookie = %s=%s", c.Name, c.Value)
+	}
+	if err := s.Set(LostFilm, "other=def"); err != nil {
+		t.Fatal(err)
+	}
+	if c := s.LostFilmClient.Config.Cookie; c.Name != "other" || c.Value != "def" {
+		t.Errorf("lostfilm named cookie = %s=%s", c.Name, c.Value)
+	}
+	if err := s.Set(Kinozal, "uid=1; pass=2"); err != nil {
+		t.Fatal(err)
+	}
+	if s.KinozalClient.Config.Cookie != "uid=1; pass=2" {
+		t.Errorf("kinozal cookie = %s", s.KinozalClient.Config.Cookie)
+	}
+	if err := s.Set("rutracker", "a"); !errors.Is(err, ErrUnknownTracker) {
+		t.Errorf("Set() unknown tracker error = %v", err)
+	}
+	if err := s.Set(Kinozal, " "); !errors.Is(err, ErrEmptyCookie) {
+		t.Errorf("Set() empty cookie error = %v", err)
+	}
+
+	// новый процесс с cookie из конфигурации получает сохраненные значения
+	restarted := newService()
+	restarted.Credentials = s.Credentials
+	if err := restarted.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if restarted.KinozalClient.Config.Cookie != "uid=1; pass=2" || restarted.LostFilmClient.Config.Cookie.Value != "def" {
+		t.Errorf("loaded cookies = %+v %s", restarted.LostFilmClient.Config.Cookie, restarted.KinozalClient.Config.Cookie)
+	}
+}
@@ -3,44 +3,44 @@ package file
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/gridfs"
-	"makarov.dev/bot/internal/config"
+	"makarov.dev/bot/internal/storage"
 	"time"
-
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type DownloadEntry struct {
-	Id         primitive.ObjectID `bson:"_id"`
-	FileId     primitive.ObjectID `bson:"file_id"`
-	RemoteAddr string             `bson:"remote_addr"`
-	UserAgent  string             `bson:"user_agent"`
-	Created    time.Time          `bson:"created"`
+// Service хранилище торрент-файлов и журнал скачиваний
+type Service struct {
+	Store     storage.FileStore
+	Downloads storage.DownloadRepository
+	Logger    *log.Logger
 }
 
-func Log(ctx *gin.Context, fileId primitive.ObjectID) error {
-	log := config.GetLogger()
-	collection := getCollection()
-	entry := DownloadEntry{
+func (s *Service) Log(ctx *gin.Context, fileId primitive.ObjectID) error {
+	entry := storage.DownloadEntry{
 		Id:         primitive.NewObjectID(),
 		FileId:     fileId,
 		RemoteAddr: ctx.ClientIP(),
 		UserAgent:  ctx.Request.UserAgent(),
 		Created:    time.Now(),
 	}
-	_, err := collection.InsertOne(ctx, entry)
+	err := s.Downloads.Insert(ctx, &entry)
 	if err != nil {
-		log.Error(fmt.Sprintf("Error while persist download log %s", entry), err.Error())
+		s.Logger.Error(fmt.Sprintf("Error while persist download log %s", entry), err.Error())
 		return err
 	}
 	return nil
 }
 
-func GetFile(fileId *primitive.ObjectID) (*gridfs.DownloadStream, error) {
-	return config.GetBucket().OpenDownloadStream(fileId)
+func (s *Service) GetFile(fileId primitive.ObjectID) (storage.File, error) {
+	return s.Store.Open(fileId)
+}
+
+func (s *Service) Upload(name string, content []byte) (primitive.ObjectID, error) {
+	return s.Store.Upload(name, content)
 }
 
-func getCollection() *mongo.Collection {
-	return config.GetDatabase().Collection("file_downloads")
+// OpenUploadStream открывает поток для записи файла, размер которого заранее неизвестен
+func (s *Service) OpenUploadStream(name string) (storage.FileUpload, error) {
+	return s.Store.OpenUpload(name)
 }
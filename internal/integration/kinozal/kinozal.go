@@ -2,140 +2,190 @@ package kinozal
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
-	"go.mongodb.org/mongo-driver/bson"
+	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"makarov.dev/bot/internal/config"
-	"makarov.dev/bot/internal/integration/telegram"
+	"go.opentelemetry.io/otel/attribute"
+	"makarov.dev/bot/internal/cache"
+	"makarov.dev/bot/internal/integration/file"
+	"makarov.dev/bot/internal/metrics"
+	"makarov.dev/bot/internal/notify"
+	"makarov.dev/bot/internal/storage"
+	"makarov.dev/bot/internal/tracing"
+	"makarov.dev/bot/pkg/keylock"
+	"makarov.dev/bot/pkg/kinozal"
+	"strconv"
 	"time"
 )
 
-type Favorite struct {
-	Id       primitive.ObjectID `bson:"_id"`
-	DetailId int64              `bson:"detail_id"`
+// Client методы клиента Kinozal, которые использует сервис
+type Client interface {
+	GetName(ctx context.Context, id int64) (string, error)
+	GetElement(ctx context.Context, id int64) (*kinozal.Element, error)
 }
 
-type Item struct {
-	Id       primitive.ObjectID `bson:"_id"`
-	Name     string             `bson:"name"`
-	DetailId int64              `bson:"detail_id"`
-	GridFsId primitive.ObjectID `bson:"grid_fs_id"`
-	Created  time.Time          `bson:"created"`
+type Service struct {
+	Client    Client
+	Items     storage.KinozalItemRepository
+	Favorites storage.KinozalFavoriteRepository
+	Files     *file.Service
+	Cache     cache.Cache
+	Notifiers []notify.Notifier
+	Logger    *log.Logger
+
+	// ids раздачи, которые сейчас сохраняются
+	ids keylock.Locks
 }
 
-func SendToTelegram(item *Item) {
-	cfg := config.GetConfig()
-	_, err := telegram.SendMessage(tgbotapi.NewMessage(cfg.Telegram.KinozalUpdateChannel,
-		fmt.Sprintf("Вышла новая серия - %s (%d)", item.Name, item.DetailId)))
+// StoreElement сохраняет новую серию избранной раздачи и рассылает оповещение
+func (s *Service) StoreElement(ctx context.Context, id int64) {
+	ctx, span := tracing.Start(ctx, "kinozal.StoreElement", attribute.Int64("kinozal.id", id))
+	defer span.End()
+	_ = tracing.Error(span, s.store(ctx, id))
+}
+
+func (s *Service) store(ctx context.Context, id int64) error {
+	log := s.Logger.WithContext(ctx)
+	key := strconv.FormatInt(id, 10)
+	if !s.ids.TryLock(key) {
+		log.Debugf("Kinozal item %d is already being stored", id)
+		return nil
+	}
+	defer s.ids.Unlock(key)
+
+	favorite, err := s.IsFavorite(id)
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+	if !favorite {
+		log.Tracef("Kinozal item %d is not favorite", id)
+		return nil
+	}
+
+	name, err := tracing.Step(ctx, "kinozal.GetName", func(ctx context.Context) (string, error) {
+		return s.Client.GetName(ctx, id)
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+	exist, err := s.Exist(id, name)
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+	if exist {
+		log.Tracef("Kinozal item %d - %s already stored", id, name)
+		return nil
+	}
+
+	element, err := tracing.Step(ctx, "kinozal.GetElement", func(ctx context.Context) (*kinozal.Element, error) {
+		return s.Client.GetElement(ctx, id)
+	})
+	if err != nil {
+		log.Errorf("Error while get kinozal element %d %s", id, err.Error())
+		return err
+	}
+
+	objectID, err := tracing.Step(ctx, "files.Upload", func(context.Context) (primitive.ObjectID, error) {
+		return s.Files.Upload(key+".torrent", element.Torrent)
+	}, attribute.Int("files.size", len(element.Torrent)))
+	if err != nil {
+		log.Errorf("Error while store torrent %s", err.Error())
+		return err
+	}
+
+	item := storage.KinozalItem{
+		Id:       primitive.NewObjectID(),
+		Name:     element.Name,
+		DetailId: id,
+		GridFsId: objectID,
+		Created:  time.Now(),
+	}
+	inserted, err := tracing.Step(ctx, "kinozal.insert", func(context.Context) (bool, error) {
+		return s.insert(&item)
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+	if !inserted {
+		log.Debugf("Kinozal item %d - %s stored concurrently", id, item.Name)
+		return nil
+	}
+	log.Infof("Store KZ item %s (%d)", item.Name, id)
+	metrics.ItemsIngested.WithLabelValues("kinozal").Inc()
+	metrics.TorrentBytesStored.WithLabelValues("kinozal").Add(float64(len(element.Torrent)))
+	err = s.Cache.Del(context.Background(), "kz")
 	if err != nil {
-		config.GetLogger().Errorf("%s (channel id %d) %s",
-			"Error while send kinozal item to telegram channel",
-			config.GetConfig().Telegram.KinozalUpdateChannel,
-			err.Error(),
-		)
-		return
+		log.Errorf("Error while invalidate cache %s", err.Error())
 	}
+	// серия уже сохранена, оповещение отправляется и после отмены ctx
+	s.Notify(context.WithoutCancel(ctx), &item)
+	return nil
 }
 
-func IsFavorite(id int64) (bool, error) {
-	ctx, cancelFunc := getContext()
-	defer cancelFunc()
-	result := getFavoriteCollection().FindOne(ctx, bson.D{{"detail_id", id}})
-	if result.Err() != nil {
-		if !errors.Is(result.Err(), mongo.ErrNoDocuments) {
-			return false, result.Err()
-		} else {
-			return false, nil
-		}
+func (s *Service) Notify(ctx context.Context, item *storage.KinozalItem) {
+	ctx, span := tracing.Start(ctx, "kinozal.Notify", attribute.Int64("kinozal.id", item.DetailId))
+	defer span.End()
+	n := notify.Notification{
+		Text: fmt.Sprintf("Вышла новая серия - %s (%d)", item.Name, item.DetailId),
 	}
-	return true, nil
+	notify.NotifyAll(ctx, s.Notifiers, n, func(notifier notify.Notifier, err error) {
+		s.Logger.WithContext(ctx).Errorf("Error while send kinozal item %d to %s %s", item.DetailId, notifier.Name(), err.Error())
+	})
 }
 
-func Exist(id int64, name string) (bool, error) {
+func (s *Service) IsFavorite(id int64) (bool, error) {
 	ctx, cancelFunc := getContext()
 	defer cancelFunc()
-	result := getItemsCollection().FindOne(ctx, bson.M{"detail_id": id, "name": name})
-	if result.Err() != nil {
-		if !errors.Is(result.Err(), mongo.ErrNoDocuments) {
-			return false, result.Err()
-		} else {
-			return false, nil
-		}
-	}
-	return true, nil
+	return s.Favorites.Exists(ctx, id)
 }
 
-func Insert(item *Item) error {
+func (s *Service) Exist(id int64, name string) (bool, error) {
 	ctx, cancelFunc := getContext()
 	defer cancelFunc()
-
-	_, err := getItemsCollection().InsertOne(ctx, item)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.Items.Exists(ctx, id, name)
 }
 
-func InsertFavorite(detailId int64) error {
+// insert возвращает false, если такая серия уже сохранена
+func (s *Service) insert(item *storage.KinozalItem) (bool, error) {
 	ctx, cancelFunc := getContext()
 	defer cancelFunc()
+	return s.Items.Upsert(ctx, item)
+}
 
-	_, err := getFavoriteCollection().InsertOne(ctx, Favorite{
+func (s *Service) InsertFavorite(detailId int64) error {
+	ctx, cancelFunc := getContext()
+	defer cancelFunc()
+	return s.Favorites.Upsert(ctx, &storage.KinozalFavorite{
 		Id:       primitive.NewObjectID(),
 		DetailId: detailId,
 	})
-	if err != nil {
-		return err
-	}
-
-	return nil
 }
 
-func DeleteFavorite(detailId int64) error {
+func (s *Service) DeleteFavorite(detailId int64) error {
 	ctx, cancelFunc := getContext()
 	defer cancelFunc()
+	return s.Favorites.Delete(ctx, detailId)
+}
 
-	_, err := getFavoriteCollection().DeleteOne(ctx, bson.M{"detail_id": detailId})
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (s *Service) ListFavorites() ([]storage.KinozalFavorite, error) {
+	ctx, cancelFunc := getContext()
+	defer cancelFunc()
+	return s.Favorites.List(ctx)
 }
 
-func LastEpisodes(ctx context.Context) ([]Item, error) {
-	log := config.GetLogger()
-	limit := int64(50)
-	cursor, err := getItemsCollection().Find(ctx, bson.D{}, &options.FindOptions{
-		Sort:  bson.D{{"created", -1}},
-		Limit: &limit,
-	})
+func (s *Service) LastEpisodes(ctx context.Context) ([]storage.KinozalItem, error) {
+	items, err := s.Items.Latest(ctx, 50)
 	if err != nil {
-		log.Error(err.Error())
-		return nil, err
-	}
-	items := make([]Item, 0)
-	err = cursor.All(ctx, &items)
-	if err != nil {
-		log.Error(err.Error())
+		s.Logger.Error(err.Error())
 		return nil, err
 	}
 	return items, nil
 }
 
-func getFavoriteCollection() *mongo.Collection {
-	return config.GetDatabase().Collection("kinozal_favorites")
-}
-
-func getItemsCollection() *mongo.Collection {
-	return config.GetDatabase().Collection("kinozal_items")
-}
-
 func getContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 10*time.Second)
 }
@@ -1,85 +1,109 @@
 package lostfilm
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
-	"github.com/mattn/go-mastodon"
-	"go.mongodb.org/mongo-driver/bson"
+	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
 	"io"
+	"makarov.dev/bot/internal/cache"
 	"makarov.dev/bot/internal/config"
-	"makarov.dev/bot/internal/integration/telegram"
-	"makarov.dev/bot/pkg"
+	"makarov.dev/bot/internal/integration/file"
+	"makarov.dev/bot/internal/metrics"
+	"makarov.dev/bot/internal/notify"
+	"makarov.dev/bot/internal/storage"
+	"makarov.dev/bot/internal/tracing"
+	"makarov.dev/bot/pkg/keylock"
 	"makarov.dev/bot/pkg/lostfilm"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
-type Item struct {
-	Id              primitive.ObjectID `bson:"_id"`
-	Page            string             `bson:"page"`
-	Name            string             `bson:"name"`
-	EpisodeName     string             `bson:"episode_name"`
-	EpisodeNameFull string             `bson:"episode_name_full"`
-	Date            time.Time          `bson:"date"`
-	Created         time.Time          `bson:"created"`
-	ItemFiles       []ItemFile         `bson:"item_files"`
-	Poster          string             `bson:"poster"`
-	RetryCount      int                `bson:"retry_count"`
+// maxBackfillPages ограничение глубины догрузки по страницам новинок
+const maxBackfillPages = 100
+
+// Client методы клиента LostFilm, которые использует сервис
+type Client interface {
+	GetRootPage(ctx context.Context, page int) ([]lostfilm.RootElement, error)
+	GetEpisode(ctx context.Context, page string) (*lostfilm.Episode, error)
+	GetTorrentRefs(ctx context.Context, episodeId int64) ([]lostfilm.TorrentRef, error)
+	GetTorrent(ctx context.Context, url string) ([]byte, error)
+	Listing(ctx context.Context, ch chan<- lostfilm.RootElement, wait func(err error) time.Duration)
 }
 
-type ItemFile struct {
-	Quality     string             `bson:"quality"`
-	Description string             `bson:"description"`
-	GridFsId    primitive.ObjectID `bson:"grid_fs_id"`
+type Service struct {
+	Config config.LostFilmConfig
+	// WebDomain адрес веб-сервера для ссылок на скачивание
+	WebDomain  string
+	Client     Client
+	Items      storage.LostFilmItemRepository
+	Files      *file.Service
+	Cache      cache.Cache
+	HttpClient lostfilm.HttpClient
+	Notifiers  []notify.Notifier
+	Logger     *log.Logger
+
+	// pages страницы, которые сейчас сохраняются
+	pages keylock.Locks
+	// notifying оповещения, которые сейчас отправляются
+	notifying sync.WaitGroup
 }
 
-var Client = lostfilm.Client{
-	Config: lostfilm.ClientConfig{
-		HttpClient:  pkg.DefaultHttpClient,
-		MainPageUrl: config.GetConfig().LostFilm.Domain,
-		Cookie:      http.Cookie{Name: config.GetConfig().LostFilm.CookieName, Value: config.GetConfig().LostFilm.CookieVal},
-	},
-	Logger: config.GetLogger(),
+// StoreElement сохраняет серию с торрентами и рассылает оповещение, когда собраны все качества.
+// Каждый шаг пишется отдельным span под общим span серии
+func (s *Service) StoreElement(ctx context.Context, element lostfilm.RootElement) {
+	ctx, span := tracing.Start(ctx, "lostfilm.StoreElement", attribute.String("lostfilm.page", element.Page))
+	defer span.End()
+	_ = tracing.Error(span, s.store(ctx, element))
 }
 
-func StoreElement(element lostfilm.RootElement) {
-	cfg := config.GetConfig()
-	lfCfg := cfg.LostFilm
-	log := config.GetLogger()
-	item, err := getByPage(element.Page)
-	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
-		log.Errorf("Error while get item by page %s", element.Page)
-		return
+func (s *Service) store(ctx context.Context, element lostfilm.RootElement) error {
+	lfCfg := s.Config
+	log := s.Logger.WithContext(ctx)
+	if !s.pages.TryLock(element.Page) {
+		log.Debugf("LF item %s is already being stored", element.Page)
+		return nil
+	}
+	defer s.pages.Unlock(element.Page)
+	item, err := s.getByPage(element.Page)
+	if err != nil {
+		log.Errorf("Error while get item by page %s %s", element.Page, err.Error())
+		return err
 	}
 	if item == nil {
 		log.Infof("Store LF item %s", element.Page)
 	} else {
 		log.Infof("Try append torrent %s", element.Page)
 	}
-	episode, err := Client.GetEpisode(element.Page)
+	episode, err := tracing.Step(ctx, "lostfilm.GetEpisode", func(ctx context.Context) (*lostfilm.Episode, error) {
+		return s.Client.GetEpisode(ctx, element.Page)
+	})
 	if err != nil {
 		log.Errorf("Error while get episode %s", err.Error())
-		return
+		return err
+	}
+	if episode == nil {
+		log.Errorf("Episode not found on page %s", element.Page)
+		return errors.New("episode not found")
 	}
 
-	refs, err := Client.GetTorrentRefs(episode.Id)
+	refs, err := tracing.Step(ctx, "lostfilm.GetTorrentRefs", func(ctx context.Context) ([]lostfilm.TorrentRef, error) {
+		return s.Client.GetTorrentRefs(ctx, episode.Id)
+	}, attribute.Int64("lostfilm.episode_id", episode.Id))
 	if err != nil {
 		log.Errorf("Error while get episode refs %s", err.Error())
-		return
+		return err
 	}
 
 	nameFull := ""
 	if strings.HasPrefix(element.Page, "/movies") {
 		nameFull = "Фильм"
 	}
-	itemFiles := make([]ItemFile, 0, 3)
+	itemFiles := make([]storage.LostFilmItemFile, 0, 3)
 
 	for _, ref := range refs {
 		alreadyExist := false
@@ -95,28 +119,33 @@ func StoreElement(element lostfilm.RootElement) {
 			continue
 		}
 
-		if cfg.Redis.Enable {
-			config.GetRedis().Del(context.Background(), "lf-"+ref.Quality)
+		err = s.Cache.Del(context.Background(), "lf-"+ref.Quality)
+		if err != nil {
+			log.Errorf("Error while invalidate cache %s", err.Error())
 		}
 
 		if nameFull == "" {
 			nameFull = ref.NameFull
 		}
-		torrent, err := Client.GetTorrent(ref.TorrentUrl)
+		quality := attribute.String("lostfilm.quality", ref.Quality)
+		torrent, err := tracing.Step(ctx, "lostfilm.GetTorrent", func(ctx context.Context) ([]byte, error) {
+			return s.Client.GetTorrent(ctx, ref.TorrentUrl)
+		}, quality)
 		if err != nil {
 			log.Errorf("Error while get torrent %s", err.Error())
-			return
+			return err
 		}
 
-		objectID, err := config.
-			GetBucket().
-			UploadFromStream(element.Name+". "+nameFull+".torrent", bytes.NewReader(torrent))
+		objectID, err := tracing.Step(ctx, "files.Upload", func(context.Context) (primitive.ObjectID, error) {
+			return s.Files.Upload(element.Name+". "+nameFull+".torrent", torrent)
+		}, quality, attribute.Int("files.size", len(torrent)))
 		if err != nil {
 			log.Errorf("Error while store torrent %s", err.Error())
-			return
+			return err
 		}
+		metrics.TorrentBytesStored.WithLabelValues("lostfilm").Add(float64(len(torrent)))
 
-		itemFiles = append(itemFiles, ItemFile{
+		itemFiles = append(itemFiles, storage.LostFilmItemFile{
 			Quality:     ref.Quality,
 			Description: ref.Description,
 			GridFsId:    objectID,
@@ -126,13 +155,15 @@ func StoreElement(element lostfilm.RootElement) {
 	if item != nil {
 		item.RetryCount++
 		item.ItemFiles = append(item.ItemFiles, itemFiles...)
-		err := update(item)
+		_, err := tracing.Step(ctx, "lostfilm.save", func(context.Context) (any, error) {
+			return nil, s.save(item)
+		})
 		if err != nil {
 			log.Errorf("Error while update item %s %s", item.Id.Hex(), err.Error())
-			return
+			return err
 		}
 	} else {
-		item = &Item{
+		item = &storage.LostFilmItem{
 			Id:              primitive.NewObjectID(),
 			Page:            element.Page,
 			Name:            element.Name,
@@ -143,41 +174,80 @@ func StoreElement(element lostfilm.RootElement) {
 			ItemFiles:       itemFiles,
 			Poster:          element.Poster,
 		}
-		err = insert(item)
+		_, err = tracing.Step(ctx, "lostfilm.save", func(context.Context) (any, error) {
+			return nil, s.save(item)
+		})
 		if err != nil {
 			log.Errorf("Error while save item %s", err.Error())
-			return
+			return err
 		}
+		metrics.ItemsIngested.WithLabelValues("lostfilm").Inc()
 	}
 	if len(item.ItemFiles) == 3 || (len(item.ItemFiles) > 0 && item.RetryCount >= lfCfg.MaxRetries) {
-		go sendToTelegram(item)
-		go sendToMastodon(*item)
+		// оповещение переживает отмену ctx, но остается в той же трассировке
+		s.notifying.Add(1)
+		go func(item storage.LostFilmItem) {
+			defer s.notifying.Done()
+			s.notify(context.WithoutCancel(ctx), item)
+		}(*item)
 	}
+	return nil
 }
 
-func FindLatest(ctx context.Context) ([]Item, error) {
-	log := config.GetLogger()
-	limit := int64(50)
-	cursor, err := getCollection().Find(ctx, bson.D{}, &options.FindOptions{
-		Sort:  bson.D{{"date", -1}, {"created", -1}},
-		Limit: &limit,
-	})
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+// Wait ждет отправки начатых оповещений
+func (s *Service) Wait() {
+	s.notifying.Wait()
+}
+
+// Backfill сохраняет пропущенные серии со страниц новинок, вышедшие не раньше since. Возвращает количество обработанных серий
+func (s *Service) Backfill(ctx context.Context, since time.Time) (int, error) {
+	processed := 0
+	for page := 1; page <= maxBackfillPages; page++ {
+		if err := ctx.Err(); err != nil {
+			return processed, err
+		}
+		elements, err := s.Client.GetRootPage(ctx, page)
+		if err != nil {
+			return processed, err
+		}
+		if len(elements) == 0 {
+			break
+		}
+		reachedSince := false
+		for _, element := range elements {
+			if element.Date.Before(since) {
+				reachedSince = true
+				continue
+			}
+			exists, err := s.Exists(element.Page)
+			if err != nil {
+				return processed, err
+			}
+			if exists {
+				continue
+			}
+			s.StoreElement(ctx, element)
+			processed++
+		}
+		if reachedSince {
+			break
+		}
 	}
-	items := make([]Item, 0)
-	err = cursor.All(ctx, &items)
+	return processed, nil
+}
+
+func (s *Service) FindLatest(ctx context.Context) ([]storage.LostFilmItem, error) {
+	items, err := s.Items.Latest(ctx, 50)
 	if err != nil {
-		log.Error(err.Error())
+		s.Logger.Error(err.Error())
 		return nil, err
 	}
 	return items, nil
 }
 
-func Exists(page string) (bool, error) {
-	cfg := config.GetConfig().LostFilm
-	item, err := getByPage(page)
+func (s *Service) Exists(page string) (bool, error) {
+	cfg := s.Config
+	item, err := s.getByPage(page)
 	if err != nil {
 		return false, err
 	}
@@ -187,154 +257,65 @@ func Exists(page string) (bool, error) {
 	return len(item.ItemFiles) >= 3 || item.RetryCount >= cfg.MaxRetries, nil
 }
 
-func insert(item *Item) error {
+func (s *Service) save(item *storage.LostFilmItem) error {
 	ctx, cancel := getContext()
 	defer cancel()
-
-	_, err := getCollection().InsertOne(ctx, item)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.Items.Upsert(ctx, item)
 }
 
-func update(item *Item) error {
+// getByPage возвращает nil, если серии еще нет
+func (s *Service) getByPage(page string) (*storage.LostFilmItem, error) {
 	ctx, cancel := getContext()
 	defer cancel()
-
-	_, err := getCollection().UpdateOne(ctx, bson.D{{"_id", item.Id}}, bson.M{"$set": item})
-	if err != nil {
-		return err
+	item, err := s.Items.GetByPage(ctx, page)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
 	}
-
-	return nil
-}
-
-func getByPage(page string) (*Item, error) {
-	ctx, cancel := getContext()
-	defer cancel()
-
-	result := getCollection().FindOne(ctx, bson.D{{"page", page}})
-	if result.Err() != nil {
-		if result.Err() != mongo.ErrNoDocuments {
-			return nil, result.Err()
-		} else {
-			return nil, nil
-		}
-	}
-	item := Item{}
-	err := result.Decode(&item)
-	if err != nil {
-		return nil, err
-	}
-
-	return &item, nil
-}
-
-func getCollection() *mongo.Collection {
-	return config.GetDatabase().Collection("lostfilm_items")
+	return item, err
 }
 
 func getContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 10*time.Second)
 }
 
-func sendToTelegram(item *Item) {
-	cfg := config.GetConfig()
-	domain := cfg.Web.Domain
-
-	posterRequest, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https:%s", item.Poster), nil)
-	if err != nil {
-		return
-	}
-
-	response, err := pkg.DefaultHttpClient.Do(posterRequest)
-	if err != nil {
-		return
-	}
-	defer response.Body.Close()
-
-	markups := tgbotapi.InlineKeyboardMarkup{
-		InlineKeyboard: make([][]tgbotapi.InlineKeyboardButton, 0),
+// notify рассылает оповещение о новой серии во все каналы доставки
+func (s *Service) notify(ctx context.Context, item storage.LostFilmItem) {
+	ctx, span := tracing.Start(ctx, "lostfilm.notify", attribute.String("lostfilm.page", item.Page))
+	defer span.End()
+	log := s.Logger.WithContext(ctx)
+	n := notify.Notification{
+		Text:  fmt.Sprintf("%s. %s", item.Name, item.EpisodeNameFull),
+		Links: make([]notify.Link, 0, len(item.ItemFiles)),
 	}
-	buttons := make([]tgbotapi.InlineKeyboardButton, 0)
-	for _, file := range item.ItemFiles {
-		url := domain + "/dl/" + file.GridFsId.Hex()
-		buttons = append(buttons, tgbotapi.InlineKeyboardButton{
-			Text: file.Quality,
-			URL:  &url,
+	for _, f := range item.ItemFiles {
+		n.Links = append(n.Links, notify.Link{
+			Title: f.Quality,
+			Url:   s.WebDomain + "/dl/" + f.GridFsId.Hex(),
 		})
 	}
-	markups.InlineKeyboard = append(markups.InlineKeyboard, buttons)
-
-	msg := tgbotapi.PhotoConfig{
-		BaseFile: tgbotapi.BaseFile{
-			BaseChat: tgbotapi.BaseChat{
-				ChatID:      cfg.Telegram.LostFilmUpdateChannel,
-				ReplyMarkup: markups,
-			},
-			File: tgbotapi.FileReader{
-				Name:   "img",
-				Reader: response.Body,
-				Size:   response.ContentLength,
-			},
-		},
-		Caption: fmt.Sprintf("%s. %s", item.Name, item.EpisodeNameFull),
-	}
-
-	_, err = telegram.SendMessage(msg)
+	poster, err := s.downloadPoster(ctx, item.Poster)
 	if err != nil {
-		config.GetLogger().Errorf("%s (channel id %d) %s",
-			"Error while send lostfilm item to telegram channel",
-			config.GetConfig().Telegram.LostFilmUpdateChannel,
-			err.Error(),
-		)
-		return
+		log.Errorf("Error while download poster %s %s", item.Poster, err.Error())
 	}
+	n.Image = poster
+
+	notify.NotifyAll(ctx, s.Notifiers, n, func(notifier notify.Notifier, err error) {
+		log.Errorf("Error while send lostfilm item %s to %s %s", item.Page, notifier.Name(), err.Error())
+	})
 }
 
-func sendToMastodon(item Item) {
-	if len(item.ItemFiles) <= 0 {
-		return
+func (s *Service) downloadPoster(ctx context.Context, poster string) ([]byte, error) {
+	if poster == "" {
+		return nil, nil
 	}
-	client := config.GetMastodonClient()
-	posterRequest, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https:%s", item.Poster), nil)
+	posterRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https:%s", poster), nil)
 	if err != nil {
-		config.GetLogger().Errorf("Error while download poster for Mastodon status %s", err.Error())
-		return
+		return nil, err
 	}
-	response, err := client.Client.Do(posterRequest)
+	response, err := s.HttpClient.Do(posterRequest)
 	if err != nil {
-		config.GetLogger().Errorf("Error while download poster for Mastodon status %s", err.Error())
-		return
+		return nil, err
 	}
 	defer response.Body.Close()
-	imgBytes, err := io.ReadAll(response.Body)
-	if err != nil {
-		config.GetLogger().Errorf("Error while download poster for Mastodon status %s", err.Error())
-		return
-	}
-	attachment, err := client.UploadMediaFromBytes(context.Background(), imgBytes)
-	if err != nil {
-		config.GetLogger().Errorf("Error while upload media for Mastodon status %s", err.Error())
-		return
-	}
-
-	status := fmt.Sprintf("%s. %s\n", item.Name, item.EpisodeNameFull)
-	domain := config.GetConfig().Web.Domain
-	for _, f := range item.ItemFiles {
-		status += fmt.Sprintf("\n%s %s/dl/%s", f.Quality, domain, f.GridFsId.Hex())
-	}
-	_, err = client.PostStatus(context.Background(), &mastodon.Toot{
-		Status:     status,
-		Visibility: mastodon.VisibilityPublic,
-		Language:   "ru",
-		MediaIDs:   []mastodon.ID{attachment.ID},
-	})
-	if err != nil {
-		config.GetLogger().Errorf("Error while send Mastodon status %s", err.Error())
-	} else {
-		config.GetLogger().Debugf("Posted status to Mastodon for item %s", item.Page)
-	}
+	return io.ReadAll(response.Body)
 }
@@ -0,0 +1,180 @@
+package lostfilm
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"makarov.dev/bot/internal/cache"
+	"makarov.dev/bot/internal/config"
+	"makarov.dev/bot/internal/integration/file"
+	"makarov.dev/bot/internal/notify"
+	"makarov.dev/bot/internal/storage"
+	"makarov.dev/bot/internal/storage/memory"
+	"makarov.dev/bot/pkg/lostfilm"
+	"testing"
+	"time"
+)
+
+type clientMock struct {
+	refs []lostfilm.TorrentRef
+	// pages страницы новинок, начиная с первой
+	pages [][]lostfilm.RootElement
+}
+
+func (c *clientMock) GetRootPage(_ context.Context, page int) ([]lostfilm.RootElement, error) {
+	if page > len(c.pages) {
+		return nil, nil
+	}
+	return c.pages[page-1], nil
+}
+
+func (c *clientMock) GetEpisode(context.Context, string) (*lostfilm.Episode, error) {
+	return &lostfilm.Episode{Id: 1}, nil
+}
+
+func (c *clientMock) GetTorrentRefs(context.Context, int64) ([]lostfilm.TorrentRef, error) {
+	return c.refs, nil
+}
+
+func (c *clientMock) GetTorrent(_ context.Context, url string) ([]byte, error) {
+	return []byte(url), nil
+}
+
+func (c *clientMock) Listing(context.Context, chan<- lostfilm.RootElement, func(error) time.Duration) {
+}
+
+type notifierMock struct {
+	ch chan notify.Notification
+}
+
+func (n *notifierMock) Name() string {
+	return "mock"
+}
+
+func (n *notifierMock) Notify(_ context.Context, notification notify.Notification) error {
+	n.ch <- notification
+	return nil
+}
+
+func newTestService(refs []lostfilm.TorrentRef) (*Service, *storage.Storage, *notifierMock) {
+	store := memory.New()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	notifier := &notifierMock{ch: make(chan notify.Notification, 1)}
+	s := &Service{
+		Config:    config.LostFilmConfig{MaxRetries: 5},
+		WebDomain: "http://localhost",
+		Client:    &clientMock{refs: refs},
+		Items:     store.LostFilmItems,
+		Files:     &file.Service{Store: store.Files, Downloads: store.Downloads, Logger: logger},
+		Cache:     cache.NopCache{},
+		Notifiers: []notify.Notifier{notifier},
+		Logger:    logger,
+	}
+	return s, store, notifier
+}
+
+func TestService_StoreElement(t *testing.T) {
+	refs := []lostfilm.TorrentRef{
+		{NameFull: "Пилот", Quality: "SD", TorrentUrl: "sd"},
+		{NameFull: "Пилот", Quality: "1080", TorrentUrl: "1080"},
+		{NameFull: "Пилот", Quality: "MP4", TorrentUrl: "mp4"},
+	}
+	s, store, notifier := newTestService(refs)
+	page := "/series/Heels/season_1/episode_1/"
+
+	s.StoreElement(context.Background(), lostfilm.RootElement{Page: page, Name: "Хилы"})
+
+	item, err := store.LostFilmItems.GetByPage(context.Background(), page)
+	if err != nil {
+		t.Fatalf("GetByPage() error = %v", err)
+	}
+	if item.EpisodeNameFull != "Пилот" || len(item.ItemFiles) != 3 {
+		t.Errorf("unexpected item %+v", item)
+	}
+	f, err := store.Files.Open(item.ItemFiles[1].GridFsId)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	content, _ := io.ReadAll(f)
+	if string(content) != "1080" || f.Name() != "Хилы. Пилот.torrent" {
+		t.Errorf("unexpected file %s %s", f.Name(), content)
+	}
+	exists, err := s.Exists(page)
+	if err != nil || !exists {
+		t.Errorf("Exists() = %v, %v, want true", exists, err)
+	}
+
+	select {
+	case n := <-notifier.ch:
+		if n.Text != "Хилы. Пилот" || len(n.Links) != 3 {
+			t.Errorf("unexpected notification %+v", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notification not sent")
+	}
+}
+
+func TestService_StoreElementAppendsMissingQuality(t *testing.T) {
+	refs := []lostfilm.TorrentRef{{NameFull: "Пилот", Quality: "SD", TorrentUrl: "sd"}}
+	s, store, notifier := newTestService(refs)
+	page := "/series/Heels/season_1/episode_1/"
+
+	s.StoreElement(context.Background(), lostfilm.RootElement{Page: page, Name: "Хилы"})
+	s.Client = &clientMock{refs: append(refs, lostfilm.TorrentRef{NameFull: "Пилот", Quality: "1080", TorrentUrl: "1080"})}
+	s.StoreElement(context.Background(), lostfilm.RootElement{Page: page, Name: "Хилы"})
+
+	item, err := store.LostFilmItems.GetByPage(context.Background(), page)
+	if err != nil {
+		t.Fatalf("GetByPage() error = %v", err)
+	}
+	if len(item.ItemFiles) != 2 || item.RetryCount != 1 {
+		t.Errorf("unexpected item %+v", item)
+	}
+	select {
+	case n := <-notifier.ch:
+		t.Errorf("unexpected notification %+v", n)
+	default:
+	}
+}
+
+func TestService_StoreElementSkipsPageInProgress(t *testing.T) {
+	refs := []lostfilm.TorrentRef{{NameFull: "Пилот", Quality: "SD", TorrentUrl: "sd"}}
+	s, store, _ := newTestService(refs)
+	page := "/series/Heels/season_1/episode_1/"
+
+	s.pages.TryLock(page)
+	s.StoreElement(context.Background(), lostfilm.RootElement{Page: page, Name: "Хилы"})
+	if _, err := store.LostFilmItems.GetByPage(context.Background(), page); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetByPage() error = %v, want ErrNotFound", err)
+	}
+
+	s.pages.Unlock(page)
+	s.StoreElement(context.Background(), lostfilm.RootElement{Page: page, Name: "Хилы"})
+	if _, err := store.LostFilmItems.GetByPage(context.Background(), page); err != nil {
+		t.Fatalf("GetByPage() error = %v", err)
+	}
+}
+
+func TestService_Backfill(t *testing.T) {
+	refs := []lostfilm.TorrentRef{{NameFull: "Пилот", Quality: "SD", TorrentUrl: "sd"}}
+	s, store, _ := newTestService(refs)
+	since := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	s.Client = &clientMock{refs: refs, pages: [][]lostfilm.RootElement{
+		{{Page: "/series/a/", Date: since.Add(48 * time.Hour)}, {Page: "/series/b/", Date: since}},
+		{{Page: "/series/c/", Date: since.Add(-24 * time.Hour)}},
+		{{Page: "/series/d/", Date: since.Add(-48 * time.Hour)}},
+	}}
+
+	processed, err := s.Backfill(context.Background(), since)
+	if err != nil || processed != 2 {
+		t.Fatalf("Backfill() = %d, %v, want 2", processed, err)
+	}
+	for page, want := range map[string]bool{"/series/a/": true, "/series/b/": true, "/series/c/": false, "/series/d/": false} {
+		_, err := store.LostFilmItems.GetByPage(context.Background(), page)
+		if got := err == nil; got != want {
+			t.Errorf("item %s stored = %v, want %v", page, got, want)
+		}
+	}
+}
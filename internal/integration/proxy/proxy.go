@@ -1,40 +1,207 @@
 package proxy
 
 import (
-	"golang.org/x/text/encoding/charmap"
+	"context"
+	"errors"
+	"fmt"
 	"io"
-	"makarov.dev/bot/pkg"
+	"mime"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
 )
 
 const (
-	cp1251            = "windows-1251"
-	utf8              = "utf-8"
 	contentTypeHeader = "Content-Type"
+	maxRedirects      = 5
+	defaultTimeout    = 30 * time.Second
+)
+
+var (
+	ErrBadUrl         = errors.New("url must be absolute http or https url")
+	ErrHostNotAllowed = errors.New("host is not allowed")
+	ErrPrivateAddress = errors.New("private address is not allowed")
+	ErrTooLarge       = errors.New("response is too large")
 )
 
-// Get exec http get request to specific url
-func Get(url string) (string, http.Header) {
-	resp, err := pkg.DefaultHttpClient.Get(url)
+// Response ответ сайта. Body нужно закрыть, его чтение вернет ErrTooLarge, если тело длиннее MaxBytes
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       io.ReadCloser
+}
+
+// Fetcher выполняет GET запросы от имени /proxy. Запросы идут напрямую, а не через пул прокси,
+// чтобы проверять адрес, с которым на самом деле устанавливается соединение
+type Fetcher struct {
+	// AllowedHosts хосты и их поддомены, которые можно запрашивать, * разрешает любой публичный хост
+	AllowedHosts []string
+	// MaxBytes предел тела ответа, 0 без предела
+	MaxBytes int64
+	Timeout  time.Duration
+
+	// allowPrivate отключает проверку адресов в тестах
+	allowPrivate bool
+	once         sync.Once
+	client       *http.Client
+}
+
+// Get запрашивает rawUrl. Адрес проверяется после разрешения имени при каждом соединении,
+// в том числе после редиректов, поэтому имя, которое указывает на внутренний адрес, не пройдет
+func (f *Fetcher) Get(ctx context.Context, rawUrl string) (*Response, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, ErrBadUrl
+	}
+	if !f.allowed(u.Hostname()) {
+		return nil, ErrHostNotAllowed
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
-		return err.Error(), nil
+		return nil, ErrBadUrl
 	}
-	defer resp.Body.Close()
-	bytes, err := io.ReadAll(resp.Body)
+	res, err := f.httpClient().Do(req)
 	if err != nil {
-		return err.Error(), nil
-	}
-	body := string(bytes)
-	contentType := resp.Header.Get(contentTypeHeader)
-	if strings.Contains(contentType, cp1251) {
-		decoder := charmap.Windows1251.NewDecoder()
-		body, err = decoder.String(body)
-		if err != nil {
-			return err.Error(), nil
+		// ошибки проверок возвращаются как есть, чтобы вызывающий выбрал код ответа
+		for _, known := range []error{ErrHostNotAllowed, ErrPrivateAddress} {
+			if errors.Is(err, known) {
+				return nil, known
+			}
 		}
-		body = strings.Replace(body, cp1251, utf8, -1)
+		return nil, err
+	}
+	if f.MaxBytes > 0 && res.ContentLength > f.MaxBytes {
+		_ = res.Body.Close()
+		return nil, ErrTooLarge
+	}
+	body := &limitedBody{ReadCloser: res.Body, max: f.MaxBytes}
+	return &Response{StatusCode: res.StatusCode, Header: res.Header, Body: body}, nil
+}
+
+// Decode перекодирует тело ответа в UTF-8 по charset из Content-Type и возвращает новый Content-Type.
+// Ответ в UTF-8 или без известной кодировки возвращается без изменений
+func Decode(res *Response) (io.Reader, string) {
+	contentType := res.Header.Get(contentTypeHeader)
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["charset"] == "" {
+		return res.Body, contentType
+	}
+	enc, err := htmlindex.Get(params["charset"])
+	if err != nil || enc == encoding.Nop {
+		return res.Body, contentType
+	}
+	if name, _ := htmlindex.Name(enc); name == "utf-8" {
+		return res.Body, contentType
+	}
+	params["charset"] = "utf-8"
+	return transform.NewReader(res.Body, enc.NewDecoder()), mime.FormatMediaType(mediaType, params)
+}
+
+func (f *Fetcher) httpClient() *http.Client {
+	f.once.Do(f.init)
+	return f.client
+}
+
+func (f *Fetcher) init() {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: f.control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	timeout := f.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	f.client = &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrBadUrl
+			}
+			if !f.allowed(req.URL.Hostname()) {
+				return ErrHostNotAllowed
+			}
+			return nil
+		},
+	}
+}
+
+// control проверяет адрес перед соединением, когда имя уже разрешено
+func (f *Fetcher) control(_ string, address string, _ syscall.RawConn) error {
+	if f.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if private(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+func (f *Fetcher) allowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, h := range f.AllowedHosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "*" || host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedAddressSpace 100.64.0.0/10 адреса операторов за NAT
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// private адреса, недоступные из интернета: loopback, частные сети, link-local, multicast и unspecified
+func private(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// limitedBody возвращает ErrTooLarge, если тело длиннее max
+type limitedBody struct {
+	io.ReadCloser
+	// max 0 без предела
+	max  int64
+	read int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.max <= 0 {
+		return b.ReadCloser.Read(p)
+	}
+	if b.read >= b.max {
+		// предел исчерпан, еще один байт означает слишком длинный ответ
+		var probe [1]byte
+		if _, err := io.ReadAtLeast(b.ReadCloser, probe[:], 1); err != nil {
+			return 0, err
+		}
+		return 0, ErrTooLarge
+	}
+	if left := b.max - b.read; int64(len(p)) > left {
+		p = p[:left]
 	}
-	resp.Header.Set(contentType, strings.Replace(contentType, cp1251, utf8, 1))
-	return body, resp.Header
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
 }
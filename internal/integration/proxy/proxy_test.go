@@ -1,15 +1,92 @@
 package proxy
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
+
+	"golang.org/x/text/encoding/charmap"
 )
 
-func TestProxyGet(t *testing.T) {
-	resp, header := Get("https://google.com")
-	if resp == "" {
-		t.Errorf("empty response")
+func TestFetcher_Get(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cp1251":
+			w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+			body, _ := charmap.Windows1251.NewEncoder().String("Привет")
+			_, _ = w.Write([]byte(body))
+		case "/koi8":
+			w.Header().Set("Content-Type", "text/plain; charset=koi8-r")
+			body, _ := charmap.KOI8R.NewEncoder().String("Мир")
+			_, _ = w.Write([]byte(body))
+		case "/large":
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+		case "/redirect":
+			http.Redirect(w, r, "http://example.com/", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("missing"))
+		}
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{AllowedHosts: []string{"127.0.0.1"}, MaxBytes: 50, allowPrivate: true}
+	read := func(path string) (*Response, string, error) {
+		t.Helper()
+		res, err := f.Get(context.Background(), srv.URL+path)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", path, err)
+		}
+		defer res.Body.Close()
+		body, contentType := Decode(res)
+		b, err := io.ReadAll(body)
+		return res, string(b) + "|" + contentType, err
+	}
+
+	if _, got, _ := read("/cp1251"); got != "Привет|text/html; charset=utf-8" {
+		t.Errorf("windows-1251 = %s", got)
+	}
+	if _, got, _ := read("/koi8"); got != "Мир|text/plain; charset=utf-8" {
+		t.Errorf("koi8-r = %s", got)
+	}
+	if res, got, _ := read("/missing"); res.StatusCode != http.StatusNotFound || !strings.HasPrefix(got, "missing|") {
+		t.Errorf("status = %d, body = %s", res.StatusCode, got)
+	}
+	if _, _, err := read("/large"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("large body error = %v", err)
+	}
+	if _, err := f.Get(context.Background(), srv.URL+"/redirect"); !errors.Is(err, ErrHostNotAllowed) {
+		t.Errorf("redirect to other host error = %v", err)
+	}
+	if _, err := f.Get(context.Background(), "ftp://127.0.0.1/"); !errors.Is(err, ErrBadUrl) {
+		t.Errorf("ftp error = %v", err)
+	}
+	if _, err := f.Get(context.Background(), "http://example.com/"); !errors.Is(err, ErrHostNotAllowed) {
+		t.Errorf("not allowed host error = %v", err)
+	}
+
+	// loopback закрыт даже для разрешенного хоста
+	strict := &Fetcher{AllowedHosts: []string{"*"}}
+	if _, err := strict.Get(context.Background(), srv.URL); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("loopback error = %v", err)
+	}
+}
+
+func TestPrivate(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "10.1.2.3", "192.168.0.1", "169.254.169.254", "100.64.0.1", "::1", "fd00::1", "::ffff:127.0.0.1", "0.0.0.0"} {
+		if !private(netip.MustParseAddr(addr)) {
+			t.Errorf("private(%s) = false", addr)
+		}
 	}
-	if header == nil {
-		t.Errorf("nil header")
+	for _, addr := range []string{"8.8.8.8", "2a00:1450::1"} {
+		if private(netip.MustParseAddr(addr)) {
+			t.Errorf("private(%s) = true", addr)
+		}
 	}
 }
@@ -3,7 +3,6 @@ package telegram
 import (
 	"fmt"
 	"github.com/nleeper/goment"
-	"makarov.dev/bot/internal/config"
 	"strings"
 	"time"
 )
@@ -17,14 +16,6 @@ var beautifulDay, _ = goment.New(goment.DateTime{
 	Minute:   30,
 	Location: location})
 
-func init() {
-	err := AddRouterFunc("/dd", ddCmd)
-	if err != nil {
-		config.GetLogger().Errorf("Error while add telegram DD cmd %s", err.Error())
-		return
-	}
-}
-
 func ddCmd(txt string) string {
 	var from goment.Goment
 	var to goment.Goment
@@ -2,11 +2,16 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	log "github.com/sirupsen/logrus"
 	"makarov.dev/bot/internal/config"
 )
 
@@ -15,35 +20,71 @@ const (
 	day             = time.Hour * 24
 )
 
-var mrBot *tgbotapi.BotAPI
-var router = make(map[string]func(txt string) string)
+var ErrNotConnected = errors.New("telegram bot not connected")
+
+// AdminOnlyReply ответ на команду, доступную только администраторам
+const AdminOnlyReply = "Команда доступна только администраторам"
+
+// Bot бот Telegram с роутером команд
+type Bot struct {
+	cfg config.TelegramConfig
+	log *log.Logger
+	// transport соединения с Telegram API, nil для http.DefaultTransport
+	transport http.RoundTripper
+
+	mutex      sync.RWMutex
+	api        *tgbotapi.BotAPI
+	router     map[string]func(txt string, from *tgbotapi.User) string
+	fileRouter map[string]func(txt string) (tgbotapi.FileBytes, error)
+	// adminCommands команды, доступные только пользователям из cfg.AdminIds
+	adminCommands map[string]any
+}
 
 type telegramLogger struct {
+	log *log.Logger
 }
 
 func (t *telegramLogger) Println(v ...any) {
-	config.GetLogger().Debug(v...)
+	t.log.Debug(v...)
 }
 
 func (t *telegramLogger) Printf(format string, v ...any) {
-	config.GetLogger().Debug(v...)
+	t.log.Debug(v...)
 }
 
-func Start(ctx context.Context) {
-	log := config.GetLogger()
-	cfg := config.GetConfig().Telegram
+func NewBot(cfg config.TelegramConfig, transport http.RoundTripper, logger *log.Logger) *Bot {
+	b := &Bot{
+		cfg:           cfg,
+		log:           logger,
+		transport:     transport,
+		router:        make(map[string]func(txt string, from *tgbotapi.User) string),
+		fileRouter:    make(map[string]func(txt string) (tgbotapi.FileBytes, error)),
+		adminCommands: make(map[string]any),
+	}
+	err := b.AddRouterFunc("/dd", ddCmd)
+	if err != nil {
+		logger.Errorf("Error while add telegram DD cmd %s", err.Error())
+	}
+	return b
+}
+
+// Start принимает команды до отмены ctx, tick вызывается на каждое обновление. Ошибка подключения возвращается,
+// повторный запуск остается за вызывающим
+func (b *Bot) Start(ctx context.Context, tick func()) error {
+	log := b.log
+	cfg := b.cfg
 	if !cfg.Enable {
 		log.Info("Telegram integration disabled")
-		return
+		return nil
 	}
-	bot, err := tgbotapi.NewBotAPI(cfg.BotToken)
+	err := b.Connect()
 	if err != nil {
-		log.Errorf("Error while connect to telegram %s %s", err.Error(), " retrying in 15 sec")
-		time.Sleep(15 * time.Second)
-		Start(ctx)
+		return fmt.Errorf("connect telegram %w", err)
 	}
-	mrBot = bot
-	err = tgbotapi.SetLogger(&telegramLogger{})
+	b.mutex.RLock()
+	bot := b.api
+	b.mutex.RUnlock()
+	err = tgbotapi.SetLogger(&telegramLogger{log: log})
 	if err != nil {
 		log.Errorf("Error while set looger %s", err.Error())
 	}
@@ -57,24 +98,36 @@ func Start(ctx context.Context) {
 
 	updates, err := bot.GetUpdatesChan(u)
 	if err != nil {
-		log.Errorf("Error while get telegram updates %s", err.Error())
+		return fmt.Errorf("get telegram updates %w", err)
 	}
 
-	for update := range updates {
+	for {
 		select {
 		case <-ctx.Done():
+			bot.StopReceivingUpdates()
 			log.Infof("Telegram background job stopped")
-			return
-		default:
+			return nil
+		case update := <-updates:
+			tick()
 			if update.Message == nil {
 				continue
 			}
 
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, update.Message.Text)
 			msg.ReplyToMessageID = update.Message.MessageID
-			route(&msg)
+			var reply tgbotapi.Chattable = msg
+			if !b.allowed(update.Message) {
+				log.Warnf("Telegram user %d is not allowed to run %s", userId(update.Message), commandOf(msg.Text))
+				msg.Text = AdminOnlyReply
+				reply = msg
+			} else if doc, isFile := b.routeFile(&msg); isFile {
+				reply = doc
+			} else {
+				b.route(&msg, update.Message.From)
+				reply = msg
+			}
 
-			_, err := bot.Send(msg)
+			_, err := bot.Send(reply)
 			if err != nil {
 				log.Errorf("Error while send telegram message %s", err.Error())
 			}
@@ -83,7 +136,32 @@ func Start(ctx context.Context) {
 	}
 }
 
-func route(msg *tgbotapi.MessageConfig) {
+// Connect авторизует бота без чтения обновлений. Этого достаточно для отправки сообщений
+func (b *Bot) Connect() error {
+	// без таймаута общего клиента: long polling держит запрос дольше. Соединения идут через прокси, если Telegram в их маршруте
+	bot, err := tgbotapi.NewBotAPIWithClient(b.cfg.BotToken, &http.Client{Transport: b.transport})
+	if err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	b.api = bot
+	b.mutex.Unlock()
+	return nil
+}
+
+// Ping запрашивает getMe, чтобы проверить токен и доступность Telegram API
+func (b *Bot) Ping() error {
+	b.mutex.RLock()
+	api := b.api
+	b.mutex.RUnlock()
+	if api == nil {
+		return ErrNotConnected
+	}
+	_, err := api.GetMe()
+	return err
+}
+
+func (b *Bot) route(msg *tgbotapi.MessageConfig, from *tgbotapi.User) {
 	txt := strings.TrimSpace(msg.Text)
 	wordSplit := strings.Split(txt, " ")
 	if len(wordSplit) < 1 {
@@ -91,31 +169,122 @@ func route(msg *tgbotapi.MessageConfig) {
 		return
 	}
 	cmdWithSlash := strings.TrimSpace(wordSplit[0])
-	fnc, e := router[cmdWithSlash]
+	b.mutex.RLock()
+	fnc, e := b.router[cmdWithSlash]
+	b.mutex.RUnlock()
 	if !e {
 		return
 	}
 
 	txt = strings.ReplaceAll(msg.Text, cmdWithSlash, "")
 	txt = strings.TrimSpace(txt)
-	msg.Text = fnc(txt)
+	msg.Text = fnc(txt, from)
+}
+
+// routeFile ищет команду, отвечающую файлом. При ошибке ответом будет текст ошибки
+func (b *Bot) routeFile(msg *tgbotapi.MessageConfig) (tgbotapi.Chattable, bool) {
+	txt := strings.TrimSpace(msg.Text)
+	cmdWithSlash, args, _ := strings.Cut(txt, " ")
+	b.mutex.RLock()
+	fnc, e := b.fileRouter[cmdWithSlash]
+	b.mutex.RUnlock()
+	if !e {
+		return nil, false
+	}
+
+	file, err := fnc(strings.TrimSpace(args))
+	if err != nil {
+		msg.Text = err.Error()
+		return *msg, true
+	}
+	doc := tgbotapi.NewDocumentUpload(msg.ChatID, file)
+	doc.ReplyToMessageID = msg.ReplyToMessageID
+	return doc, true
+}
+
+func (b *Bot) AddRouterFunc(cmd string, fnc func(txt string) string) error {
+	return b.AddUserRouterFunc(cmd, func(txt string, _ *tgbotapi.User) string {
+		return fnc(txt)
+	})
 }
 
-func AddRouterFunc(cmd string, fnc func(txt string) string) error {
-	_, e := router[cmd]
+// AddUserRouterFunc регистрирует команду, которой нужен отправитель, например чтобы проверить права на подкоманды
+func (b *Bot) AddUserRouterFunc(cmd string, fnc func(txt string, from *tgbotapi.User) string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	_, e := b.router[cmd]
 	if e {
 		return fmt.Errorf("router cmd already exist")
 	}
 
-	router[cmd] = fnc
+	b.router[cmd] = fnc
+
+	return nil
+}
+
+// AddAdminRouterFunc регистрирует команду, которую могут выполнять только пользователи из cfg.AdminIds
+func (b *Bot) AddAdminRouterFunc(cmd string, fnc func(txt string) string) error {
+	err := b.AddRouterFunc(cmd, fnc)
+	if err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	b.adminCommands[cmd] = nil
+	b.mutex.Unlock()
+	return nil
+}
+
+// allowed проверяет права на административные команды, остальные команды доступны всем
+func (b *Bot) allowed(message *tgbotapi.Message) bool {
+	b.mutex.RLock()
+	_, admin := b.adminCommands[commandOf(message.Text)]
+	b.mutex.RUnlock()
+	if !admin {
+		return true
+	}
+	return b.IsAdmin(message.From)
+}
+
+// IsAdmin проверяет, что пользователь указан в cfg.AdminIds
+func (b *Bot) IsAdmin(from *tgbotapi.User) bool {
+	return from != nil && slices.Contains(b.cfg.AdminIds, from.ID)
+}
+
+func commandOf(txt string) string {
+	cmd, _, _ := strings.Cut(strings.TrimSpace(txt), " ")
+	return cmd
+}
+
+func userId(message *tgbotapi.Message) int {
+	if message.From == nil {
+		return 0
+	}
+	return message.From.ID
+}
+
+// AddFileRouterFunc регистрирует команду, которая отвечает документом
+func (b *Bot) AddFileRouterFunc(cmd string, fnc func(txt string) (tgbotapi.FileBytes, error)) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	_, e := b.fileRouter[cmd]
+	if e {
+		return fmt.Errorf("file router cmd already exist")
+	}
+
+	b.fileRouter[cmd] = fnc
 
 	return nil
 }
 
-func SendMessage(c tgbotapi.Chattable) (tgbotapi.Message, error) {
-	cfg := config.GetConfig()
-	if !cfg.Telegram.Enable {
+func (b *Bot) SendMessage(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if !b.cfg.Enable {
 		return tgbotapi.Message{}, nil
 	}
-	return mrBot.Send(c)
+	b.mutex.RLock()
+	api := b.api
+	b.mutex.RUnlock()
+	if api == nil {
+		return tgbotapi.Message{}, ErrNotConnected
+	}
+	return api.Send(c)
 }
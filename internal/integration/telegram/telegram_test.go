@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/config"
+)
+
+func TestBot_allowed(t *testing.T) {
+	b := NewBot(config.TelegramConfig{AdminIds: []int{1}}, nil, log.New())
+	if err := b.AddAdminRouterFunc("/cookie", func(string) string { return "Ok" }); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		message *tgbotapi.Message
+		want    bool
+	}{
+		{"admin", &tgbotapi.Message{Text: "/cookie set kinozal a", From: &tgbotapi.User{ID: 1}}, true},
+		{"not admin", &tgbotapi.Message{Text: "/cookie set kinozal a", From: &tgbotapi.User{ID: 2}}, false},
+		{"no sender", &tgbotapi.Message{Text: "/cookie"}, false},
+		{"public command", &tgbotapi.Message{Text: "/dd 2020-01-01", From: &tgbotapi.User{ID: 2}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.allowed(tt.message); got != tt.want {
+				t.Errorf("allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBot_routeSubcommandAdmin(t *testing.T) {
+	b := NewBot(config.TelegramConfig{AdminIds: []int{1}}, nil, log.New())
+	err := b.AddUserRouterFunc("/twitch", func(txt string, from *tgbotapi.User) string {
+		if txt != "list" && !b.IsAdmin(from) {
+			return AdminOnlyReply
+		}
+		return "Ok"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		text string
+		from *tgbotapi.User
+		want string
+	}{
+		{"public subcommand", "/twitch list", &tgbotapi.User{ID: 2}, "Ok"},
+		{"admin subcommand", "/twitch join foo", &tgbotapi.User{ID: 1}, "Ok"},
+		{"admin subcommand not admin", "/twitch join foo", &tgbotapi.User{ID: 2}, AdminOnlyReply},
+		{"admin subcommand no sender", "/twitch part foo", nil, AdminOnlyReply},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := &tgbotapi.Message{Text: tt.text, From: tt.from}
+			if !b.allowed(message) {
+				t.Fatal("allowed() = false, subcommands are checked by the handler")
+			}
+			msg := tgbotapi.NewMessage(1, tt.text)
+			b.route(&msg, tt.from)
+			if msg.Text != tt.want {
+				t.Errorf("route() = %q, want %q", msg.Text, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/notify"
+	"makarov.dev/bot/internal/storage"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlertRule правило пересылки сообщений чата в чат оповещений. Пустые поля совпадают с любым значением
+type AlertRule struct {
+	Id      primitive.ObjectID `json:"id"`
+	Channel string             `json:"channel"`
+	User    string             `json:"user"`
+	Pattern string             `json:"pattern"`
+	// RateLimit минимальный интервал между оповещениями по правилу
+	RateLimit time.Duration `json:"rateLimit"`
+	Created   time.Time     `json:"created"`
+
+	re       *regexp.Regexp
+	lastSent time.Time
+}
+
+var ErrAlertRuleNotFound = errors.New("alert rule not found")
+
+// ParseAlertRule разбирает правило вида channel=<channel> user=<user> rate=<duration> pattern=<regex>.
+// pattern должен быть последним, так как может содержать пробелы
+func ParseAlertRule(txt string) (*AlertRule, error) {
+	rule := &AlertRule{}
+	rest := strings.TrimSpace(txt)
+	for rest != "" {
+		var field string
+		if strings.HasPrefix(rest, "pattern=") {
+			field, rest = rest, ""
+		} else {
+			field, rest, _ = strings.Cut(rest, " ")
+			rest = strings.TrimSpace(rest)
+		}
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return nil, fmt.Errorf("wrong alert rule field %s", field)
+		}
+		switch key {
+		case "channel":
+			rule.Channel = normalizeChannel(value)
+		case "user":
+			rule.User = strings.ToLower(value)
+		case "pattern":
+			rule.Pattern = value
+		case "rate":
+			rate, err := time.ParseDuration(value)
+			if err != nil {
+				seconds, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("wrong alert rule rate %s", value)
+				}
+				rate = time.Duration(seconds) * time.Second
+			}
+			rule.RateLimit = rate
+		default:
+			return nil, fmt.Errorf("unknown alert rule field %s", key)
+		}
+	}
+	if rule.User == "" && rule.Pattern == "" {
+		return nil, errors.New("alert rule needs user or pattern")
+	}
+	if err := rule.compile(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *AlertRule) String() string {
+	parts := []string{r.Id.Hex()}
+	if r.Channel != "" {
+		parts = append(parts, "channel="+r.Channel)
+	}
+	if r.User != "" {
+		parts = append(parts, "user="+r.User)
+	}
+	if r.RateLimit > 0 {
+		parts = append(parts, "rate="+r.RateLimit.String())
+	}
+	if r.Pattern != "" {
+		parts = append(parts, "pattern="+r.Pattern)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r *AlertRule) compile() error {
+	if r.Pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile("(?i)" + r.Pattern)
+	if err != nil {
+		return err
+	}
+	r.re = re
+	return nil
+}
+
+func (r *AlertRule) matches(channel string, user string, message string) bool {
+	if r.Channel != "" && r.Channel != channel {
+		return false
+	}
+	if r.User != "" && r.User != strings.ToLower(user) {
+		return false
+	}
+	if r.re != nil && !r.re.MatchString(message) {
+		return false
+	}
+	return true
+}
+
+// allow учитывает ограничение частоты и запоминает время отправки
+func (r *AlertRule) allow(now time.Time) bool {
+	if r.RateLimit > 0 && now.Sub(r.lastSent) < r.RateLimit {
+		return false
+	}
+	r.lastSent = now
+	return true
+}
+
+func (s *Service) AddAlertRule(rule *AlertRule) error {
+	rule.Id = primitive.NewObjectID()
+	rule.Created = time.Now()
+	ctx, cancel := getContext()
+	defer cancel()
+	err := s.Storage.AlertRules.Insert(ctx, &storage.AlertRule{
+		Id:        rule.Id,
+		Channel:   rule.Channel,
+		User:      rule.User,
+		Pattern:   rule.Pattern,
+		RateLimit: rule.RateLimit,
+		Created:   rule.Created,
+	})
+	if err != nil {
+		return err
+	}
+	s.alertRulesMutex.Lock()
+	defer s.alertRulesMutex.Unlock()
+	s.alertRules = append(s.alertRules, rule)
+	return nil
+}
+
+func (s *Service) RemoveAlertRule(id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := getContext()
+	defer cancel()
+	err = s.Storage.AlertRules.Delete(ctx, objectId)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrAlertRuleNotFound
+	}
+	if err != nil {
+		return err
+	}
+	s.alertRulesMutex.Lock()
+	defer s.alertRulesMutex.Unlock()
+	for i, rule := range s.alertRules {
+		if rule.Id == objectId {
+			s.alertRules = append(s.alertRules[:i], s.alertRules[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Service) GetAlertRules() []AlertRule {
+	s.alertRulesMutex.Lock()
+	defer s.alertRulesMutex.Unlock()
+	result := make([]AlertRule, 0, len(s.alertRules))
+	for _, rule := range s.alertRules {
+		result = append(result, *rule)
+	}
+	return result
+}
+
+func (s *Service) loadAlertRules() error {
+	ctx, cancel := getContext()
+	defer cancel()
+	stored, err := s.Storage.AlertRules.List(ctx)
+	if err != nil {
+		return err
+	}
+	rules := make([]*AlertRule, 0, len(stored))
+	for _, r := range stored {
+		rule := &AlertRule{
+			Id:        r.Id,
+			Channel:   r.Channel,
+			User:      r.User,
+			Pattern:   r.Pattern,
+			RateLimit: r.RateLimit,
+			Created:   r.Created,
+		}
+		if err = rule.compile(); err != nil {
+			return fmt.Errorf("alert rule %s %w", rule.Id.Hex(), err)
+		}
+		rules = append(rules, rule)
+	}
+	s.alertRulesMutex.Lock()
+	defer s.alertRulesMutex.Unlock()
+	s.alertRules = rules
+	return nil
+}
+
+// checkAlerts пересылает сообщение в чат оповещений, если оно подходит хотя бы под одно правило
+func (s *Service) checkAlerts(channel string, user string, message string) {
+	if s.Notifier == nil {
+		return
+	}
+	s.alertRulesMutex.Lock()
+	matched := false
+	now := time.Now()
+	for _, rule := range s.alertRules {
+		if rule.matches(channel, user, message) && rule.allow(now) {
+			matched = true
+			break
+		}
+	}
+	s.alertRulesMutex.Unlock()
+	if !matched {
+		return
+	}
+	err := s.Notifier.Notify(context.Background(), notify.Notification{
+		Text: fmt.Sprintf("[%s] %s: %s", channel, user, message),
+	})
+	if err != nil {
+		s.Logger.Errorf("Error while send twitch alert to %s %s", s.Notifier.Name(), err.Error())
+	}
+}
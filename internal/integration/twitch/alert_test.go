@@ -0,0 +1,71 @@
+package twitch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		txt     string
+		want    AlertRule
+		wantErr bool
+	}{
+		{
+			name: "all fields",
+			txt:  "channel=#Tushqa user=Viewer rate=30s pattern=hello world",
+			want: AlertRule{Channel: "tushqa", User: "viewer", RateLimit: 30 * time.Second, Pattern: "hello world"},
+		},
+		{
+			name: "rate in seconds",
+			txt:  "user=viewer rate=60",
+			want: AlertRule{User: "viewer", RateLimit: time.Minute},
+		},
+		{name: "no user and pattern", txt: "channel=tushqa", wantErr: true},
+		{name: "unknown field", txt: "foo=bar pattern=x", wantErr: true},
+		{name: "wrong regex", txt: "pattern=(", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAlertRule(tt.txt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAlertRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Channel != tt.want.Channel || got.User != tt.want.User ||
+				got.Pattern != tt.want.Pattern || got.RateLimit != tt.want.RateLimit {
+				t.Errorf("ParseAlertRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertRuleMatches(t *testing.T) {
+	rule, err := ParseAlertRule("channel=tushqa rate=1m pattern=\\bbot\\b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rule.matches("tushqa", "viewer", "this BOT is alive") {
+		t.Error("rule should match case insensitive pattern")
+	}
+	if rule.matches("other", "viewer", "bot") {
+		t.Error("rule should not match other channel")
+	}
+	if rule.matches("tushqa", "viewer", "robot") {
+		t.Error("rule should not match partial word")
+	}
+
+	now := time.Now()
+	if !rule.allow(now) {
+		t.Error("first alert should be allowed")
+	}
+	if rule.allow(now.Add(30 * time.Second)) {
+		t.Error("alert inside rate limit should be suppressed")
+	}
+	if !rule.allow(now.Add(time.Minute)) {
+		t.Error("alert after rate limit should be allowed")
+	}
+}
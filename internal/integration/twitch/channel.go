@@ -0,0 +1,148 @@
+package twitch
+
+import (
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/storage"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmptyChannel          = errors.New("empty channel name")
+	ErrChannelAlreadyWatched = errors.New("channel already watched")
+	ErrChannelNotWatched     = errors.New("channel not watched")
+)
+
+// JoinChannel сохраняет канал и подключается к его чату, если клиент уже запущен
+func (s *Service) JoinChannel(name string) (*storage.TwitchChannel, error) {
+	name = normalizeChannel(name)
+	if name == "" {
+		return nil, ErrEmptyChannel
+	}
+	ctx, cancel := getContext()
+	defer cancel()
+	channel := &storage.TwitchChannel{
+		Id:      primitive.NewObjectID(),
+		Name:    name,
+		Created: time.Now(),
+	}
+	// одна вставка без предварительной проверки: одновременные join не создадут дубликат
+	err := s.Storage.TwitchChannels.Insert(ctx, channel)
+	if errors.Is(err, storage.ErrDuplicate) {
+		return nil, ErrChannelAlreadyWatched
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c := s.getClient(); c != nil {
+		c.Join(name)
+	}
+	return channel, nil
+}
+
+// PartChannel удаляет канал и отключается от его чата
+func (s *Service) PartChannel(name string) error {
+	name = normalizeChannel(name)
+	if name == "" {
+		return ErrEmptyChannel
+	}
+	ctx, cancel := getContext()
+	defer cancel()
+	err := s.Storage.TwitchChannels.Delete(ctx, name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrChannelNotWatched
+	}
+	if err != nil {
+		return err
+	}
+	if c := s.getClient(); c != nil {
+		c.Depart(name)
+	}
+	return nil
+}
+
+func (s *Service) GetChannels() ([]storage.TwitchChannel, error) {
+	ctx, cancel := getContext()
+	defer cancel()
+	return s.Storage.TwitchChannels.List(ctx)
+}
+
+// seedChannels заполняет коллекцию каналами из конфига, только если она пуста
+func (s *Service) seedChannels(names []string) ([]string, error) {
+	channels, err := s.GetChannels()
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) == 0 {
+		ctx, cancel := getContext()
+		defer cancel()
+		for _, name := range names {
+			name = normalizeChannel(name)
+			if name == "" {
+				continue
+			}
+			channel := storage.TwitchChannel{
+				Id:      primitive.NewObjectID(),
+				Name:    name,
+				Created: time.Now(),
+			}
+			err = s.Storage.TwitchChannels.Insert(ctx, &channel)
+			// "Foo" и "foo" в конфиге один канал
+			if errors.Is(err, storage.ErrDuplicate) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			channels = append(channels, channel)
+		}
+	}
+	result := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		result = append(result, channel.Name)
+	}
+	return result, nil
+}
+
+// SyncConfigChannels подключает добавленные в конфигурацию каналы и отключает удаленные из нее.
+// Каналы, добавленные командой /join, остаются как есть
+func (s *Service) SyncConfigChannels(previous []string, current []string) error {
+	previousSet := make(map[string]any)
+	for _, name := range previous {
+		previousSet[normalizeChannel(name)] = nil
+	}
+	currentSet := make(map[string]any)
+	for _, name := range current {
+		currentSet[normalizeChannel(name)] = nil
+	}
+	errs := make([]error, 0)
+	for name := range currentSet {
+		if _, found := previousSet[name]; found {
+			continue
+		}
+		_, err := s.JoinChannel(name)
+		if err != nil && !errors.Is(err, ErrChannelAlreadyWatched) && !errors.Is(err, ErrEmptyChannel) {
+			errs = append(errs, fmt.Errorf("join %s %w", name, err))
+			continue
+		}
+		s.Logger.Infof("Joined twitch channel %s from config", name)
+	}
+	for name := range previousSet {
+		if _, found := currentSet[name]; found {
+			continue
+		}
+		err := s.PartChannel(name)
+		if err != nil && !errors.Is(err, ErrChannelNotWatched) && !errors.Is(err, ErrEmptyChannel) {
+			errs = append(errs, fmt.Errorf("part %s %w", name, err))
+			continue
+		}
+		s.Logger.Infof("Parted twitch channel %s removed from config", name)
+	}
+	return errors.Join(errs...)
+}
+
+func normalizeChannel(name string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+}
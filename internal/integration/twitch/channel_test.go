@@ -0,0 +1,45 @@
+package twitch
+
+import (
+	"slices"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/config"
+	"makarov.dev/bot/internal/storage/memory"
+)
+
+func TestSyncConfigChannels(t *testing.T) {
+	s := NewService(config.TwitchConfig{}, memory.New(), nil, nil, log.New())
+	for _, name := range []string{"manual", "old", "kept"} {
+		if _, err := s.JoinChannel(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SyncConfigChannels([]string{"old", "kept"}, []string{"#Kept", "new", "manual"}); err != nil {
+		t.Fatalf("SyncConfigChannels() error = %v", err)
+	}
+	channels, err := s.GetChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(channels))
+	for _, c := range channels {
+		names = append(names, c.Name)
+	}
+	slices.Sort(names)
+	if want := []string{"kept", "manual", "new"}; !slices.Equal(names, want) {
+		t.Errorf("channels = %v, want %v", names, want)
+	}
+}
+
+func TestSeedChannelsDuplicateNames(t *testing.T) {
+	s := NewService(config.TwitchConfig{}, memory.New(), nil, nil, log.New())
+	names, err := s.seedChannels([]string{"Foo", "foo", "#bar"})
+	if err != nil {
+		t.Fatalf("seedChannels() error = %v", err)
+	}
+	if want := []string{"foo", "bar"}; !slices.Equal(names, want) {
+		t.Errorf("seedChannels() = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,261 @@
+package twitch
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"makarov.dev/bot/internal/storage"
+	"strings"
+	"time"
+)
+
+type ExportFormat string
+
+const (
+	ExportJsonl ExportFormat = "jsonl"
+	ExportCsv   ExportFormat = "csv"
+	ExportVtt   ExportFormat = "vtt"
+	ExportAss   ExportFormat = "ass"
+
+	// replayCueDuration время показа одного сообщения в формате повтора чата
+	replayCueDuration = 5 * time.Second
+)
+
+var ErrEmptyExportChannel = errors.New("export channel is required")
+
+type ExportOptions struct {
+	Channel string
+	From    time.Time
+	To      time.Time
+	// StreamStart начало трансляции, относительно которого выравниваются субтитры (vtt, ass)
+	StreamStart time.Time
+	Format      ExportFormat
+}
+
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch f := ExportFormat(strings.ToLower(s)); f {
+	case ExportJsonl, ExportCsv, ExportVtt, ExportAss:
+		return f, nil
+	case "":
+		return ExportJsonl, nil
+	default:
+		return "", fmt.Errorf("unknown export format %s", s)
+	}
+}
+
+// ParseExportTime разбирает время в формате RFC3339 или дату 2006-01-02 в UTC
+func ParseExportTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.UTC)
+}
+
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportCsv:
+		return "text/csv; charset=utf-8"
+	case ExportVtt:
+		return "text/vtt; charset=utf-8"
+	case ExportAss:
+		return "text/x-ssa; charset=utf-8"
+	default:
+		return "application/x-ndjson; charset=utf-8"
+	}
+}
+
+func (f ExportFormat) FileName(channel string) string {
+	return fmt.Sprintf("%s.%s", channel, f)
+}
+
+// Export потоково пишет сообщения канала за период в w в заданном формате
+func (s *Service) Export(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	if opts.Channel == "" {
+		return ErrEmptyExportChannel
+	}
+	if opts.To.IsZero() {
+		opts.To = time.Now()
+	}
+	if opts.StreamStart.IsZero() {
+		opts.StreamStart = opts.From
+	}
+	ew := newExportWriter(opts.Format, w, opts.StreamStart)
+	if err := ew.WriteHeader(); err != nil {
+		return err
+	}
+	filter := storage.ChatMessageFilter{
+		Channel: normalizeChannel(opts.Channel),
+		From:    opts.From,
+		To:      opts.To,
+	}
+	err := s.Storage.ChatMessages.Each(ctx, filter, ew.Write)
+	if err != nil {
+		return err
+	}
+	return ew.Flush()
+}
+
+type exportWriter interface {
+	WriteHeader() error
+	Write(m *storage.ChatMessage) error
+	Flush() error
+}
+
+func newExportWriter(format ExportFormat, w io.Writer, streamStart time.Time) exportWriter {
+	switch format {
+	case ExportCsv:
+		return &csvExportWriter{w: csv.NewWriter(w)}
+	case ExportVtt:
+		return &vttExportWriter{w: w, streamStart: streamStart}
+	case ExportAss:
+		return &assExportWriter{w: w, streamStart: streamStart}
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return &jsonlExportWriter{enc: enc}
+	}
+}
+
+type jsonlExportWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlExportWriter) WriteHeader() error {
+	return nil
+}
+
+func (j *jsonlExportWriter) Write(m *storage.ChatMessage) error {
+	return j.enc.Encode(m)
+}
+
+func (j *jsonlExportWriter) Flush() error {
+	return nil
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvExportWriter) WriteHeader() error {
+	return c.w.Write([]string{"id", "channel", "user_id", "user_name", "message", "original_time"})
+}
+
+func (c *csvExportWriter) Write(m *storage.ChatMessage) error {
+	return c.w.Write([]string{
+		m.Id.Hex(),
+		m.Channel,
+		m.User.Id,
+		m.User.Name,
+		m.Message,
+		m.OriginalTime.Format(time.RFC3339Nano),
+	})
+}
+
+func (c *csvExportWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\n", " ")
+
+type vttExportWriter struct {
+	w           io.Writer
+	streamStart time.Time
+	cue         int
+}
+
+func (v *vttExportWriter) WriteHeader() error {
+	_, err := io.WriteString(v.w, "WEBVTT\n\n")
+	return err
+}
+
+func (v *vttExportWriter) Write(m *storage.ChatMessage) error {
+	offset := m.OriginalTime.Sub(v.streamStart)
+	if offset < 0 {
+		return nil
+	}
+	v.cue++
+	_, err := fmt.Fprintf(v.w, "%d\n%s --> %s\n<v %s>%s\n\n",
+		v.cue,
+		vttTimestamp(offset),
+		vttTimestamp(offset+replayCueDuration),
+		vttEscaper.Replace(m.User.Name),
+		vttEscaper.Replace(m.Message),
+	)
+	return err
+}
+
+func (v *vttExportWriter) Flush() error {
+	return nil
+}
+
+var assEscaper = strings.NewReplacer("{", "(", "}", ")", "\n", "\\N")
+
+const assHeader = `[Script Info]
+ScriptType: v4.00+
+PlayResX: 1920
+PlayResY: 1080
+WrapStyle: 0
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,36,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,0,7,20,20,20,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+type assExportWriter struct {
+	w           io.Writer
+	streamStart time.Time
+}
+
+func (a *assExportWriter) WriteHeader() error {
+	_, err := io.WriteString(a.w, assHeader)
+	return err
+}
+
+func (a *assExportWriter) Write(m *storage.ChatMessage) error {
+	offset := m.OriginalTime.Sub(a.streamStart)
+	if offset < 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(a.w, "Dialogue: 0,%s,%s,Default,%s,0,0,0,,%s: %s\n",
+		assTimestamp(offset),
+		assTimestamp(offset+replayCueDuration),
+		strings.ReplaceAll(m.User.Name, ",", " "),
+		assEscaper.Replace(m.User.Name),
+		assEscaper.Replace(m.Message),
+	)
+	return err
+}
+
+func (a *assExportWriter) Flush() error {
+	return nil
+}
+
+// vttTimestamp форматирует смещение как hh:mm:ss.mmm
+func vttTimestamp(d time.Duration) string {
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, d/time.Millisecond)
+}
+
+// assTimestamp форматирует смещение как h:mm:ss.cc
+func assTimestamp(d time.Duration) string {
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+	return fmt.Sprintf("%d:%02d:%02d.%02d", h, m, s, d/(10*time.Millisecond))
+}
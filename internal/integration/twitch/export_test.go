@@ -0,0 +1,90 @@
+package twitch
+
+import (
+	"bytes"
+	"makarov.dev/bot/internal/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportWriters(t *testing.T) {
+	streamStart := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	messages := []storage.ChatMessage{
+		{
+			Channel:      "tushqa",
+			User:         storage.ChatUser{Id: "1", Name: "before"},
+			Message:      "too early",
+			OriginalTime: streamStart.Add(-time.Minute),
+		},
+		{
+			Channel:      "tushqa",
+			User:         storage.ChatUser{Id: "2", Name: "viewer"},
+			Message:      "hello <b>{world}</b>, chat",
+			OriginalTime: streamStart.Add(time.Hour + 2*time.Minute + 3*time.Second + 450*time.Millisecond),
+		},
+	}
+	tests := []struct {
+		name   string
+		format ExportFormat
+		want   []string
+	}{
+		{
+			name:   "jsonl",
+			format: ExportJsonl,
+			want:   []string{`"name":"before"`, `"message":"hello <b>{world}</b>, chat"`},
+		},
+		{
+			name:   "csv",
+			format: ExportCsv,
+			want:   []string{"id,channel,user_id,user_name,message,original_time\n", `,tushqa,2,viewer,"hello <b>{world}</b>, chat",2024-01-01T11:02:03.45Z`},
+		},
+		{
+			name:   "vtt",
+			format: ExportVtt,
+			want:   []string{"WEBVTT\n\n1\n01:02:03.450 --> 01:02:08.450\n<v viewer>hello &lt;b&gt;{world}&lt;/b&gt;, chat\n\n"},
+		},
+		{
+			name:   "ass",
+			format: ExportAss,
+			want:   []string{"[Events]", "Dialogue: 0,1:02:03.45,1:02:08.45,Default,viewer,0,0,0,,viewer: hello <b>(world)</b>, chat\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w := newExportWriter(tt.format, buf, streamStart)
+			if err := w.WriteHeader(); err != nil {
+				t.Fatal(err)
+			}
+			for i := range messages {
+				if err := w.Write(&messages[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("export %s = %q, want to contain %q", tt.format, buf.String(), want)
+				}
+			}
+			if tt.format == ExportVtt || tt.format == ExportAss {
+				if strings.Contains(buf.String(), "too early") {
+					t.Errorf("export %s contains message before stream start", tt.format)
+				}
+			}
+		})
+	}
+}
+
+func TestParseExportTime(t *testing.T) {
+	got, err := ParseExportTime("2024-01-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) || got.Location() != time.UTC {
+		t.Errorf("ParseExportTime() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,185 @@
+package twitch
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// RetentionFor возвращает срок хранения сообщений канала, 0 - хранить всегда
+func (s *Service) RetentionFor(channel string) (time.Duration, error) {
+	cfg := s.Config
+	return retentionFor(channel, cfg.ChannelRetentionDays, cfg.RetentionDays)
+}
+
+func retentionFor(channel string, rules []string, defaultDays int) (time.Duration, error) {
+	for _, rule := range rules {
+		name, rawDays, found := strings.Cut(rule, ":")
+		if !found {
+			return 0, fmt.Errorf("wrong channel retention %s, expected channel:days", rule)
+		}
+		if normalizeChannel(name) != channel {
+			continue
+		}
+		days, err := strconv.Atoi(strings.TrimSpace(rawDays))
+		if err != nil {
+			return 0, fmt.Errorf("wrong channel retention %s %w", rule, err)
+		}
+		return time.Duration(days) * day, nil
+	}
+	return time.Duration(defaultDays) * day, nil
+}
+
+// ApplyRetention считает дневную статистику по всем каналам, затем архивирует и удаляет устаревшие сообщения
+func (s *Service) ApplyRetention(ctx context.Context) error {
+	log := s.Logger
+	channels, err := s.Storage.ChatMessages.Channels(ctx)
+	if err != nil {
+		return err
+	}
+	today := time.Now().UTC().Truncate(day)
+	for _, channel := range channels {
+		err = s.rollupChannel(ctx, channel, today)
+		if err != nil {
+			log.Errorf("Error while rollup twitch channel %s %s", channel, err.Error())
+			continue
+		}
+		retention, err := s.RetentionFor(channel)
+		if err != nil {
+			return err
+		}
+		if retention <= 0 {
+			continue
+		}
+		// статистика посчитана до сегодняшнего дня, поэтому удаляются только посчитанные сообщения
+		err = s.pruneChannel(ctx, channel, today.Add(-retention))
+		if err != nil {
+			log.Errorf("Error while prune twitch channel %s %s", channel, err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *Service) GetRollups(ctx context.Context, channel string, from time.Time, to time.Time) ([]storage.ChatRollup, error) {
+	if channel != "" {
+		channel = normalizeChannel(channel)
+	}
+	return s.Storage.ChatRollups.List(ctx, channel, from.UTC().Truncate(day), to)
+}
+
+func (s *Service) rollupChannel(ctx context.Context, channel string, today time.Time) error {
+	next, err := s.nextRollupDay(ctx, channel)
+	if err != nil || next.IsZero() {
+		return err
+	}
+	for d := next; d.Before(today); d = d.Add(day) {
+		rollup, err := s.buildRollup(ctx, channel, d)
+		if err != nil {
+			return err
+		}
+		err = s.Storage.ChatRollups.Upsert(ctx, rollup)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextRollupDay первый непосчитанный день канала или нулевое время, если сообщений нет
+func (s *Service) nextRollupDay(ctx context.Context, channel string) (time.Time, error) {
+	last, err := s.Storage.ChatRollups.Last(ctx, channel)
+	if err == nil {
+		return last.Day.UTC().Add(day), nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return time.Time{}, err
+	}
+	first, err := s.Storage.ChatMessages.First(ctx, channel)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return first.OriginalTime.UTC().Truncate(day), nil
+}
+
+func (s *Service) buildRollup(ctx context.Context, channel string, d time.Time) (*storage.ChatRollup, error) {
+	users, err := s.Storage.ChatMessages.CountByUserHour(ctx, storage.ChatMessageFilter{
+		Channel: channel,
+		From:    d,
+		To:      d.Add(day),
+	})
+	if err != nil {
+		return nil, err
+	}
+	total := int64(0)
+	for _, u := range users {
+		total += u.Count
+	}
+	return &storage.ChatRollup{
+		Id:      primitive.NewObjectID(),
+		Channel: channel,
+		Day:     d,
+		Total:   total,
+		Users:   users,
+		Created: time.Now(),
+	}, nil
+}
+
+func (s *Service) pruneChannel(ctx context.Context, channel string, cutoff time.Time) error {
+	log := s.Logger
+	filter := storage.ChatMessageFilter{Channel: channel, To: cutoff}
+	count, err := s.Storage.ChatMessages.Count(ctx, filter)
+	if err != nil || count == 0 {
+		return err
+	}
+	if s.Config.Archive {
+		err = s.archiveChannel(ctx, channel, cutoff)
+		if err != nil {
+			return err
+		}
+	}
+	deleted, err := s.Storage.ChatMessages.Delete(ctx, filter)
+	if err != nil {
+		return err
+	}
+	log.Infof("Pruned %d twitch messages of %s older than %s", deleted, channel, cutoff.Format(time.RFC3339))
+	return nil
+}
+
+func (s *Service) archiveChannel(ctx context.Context, channel string, cutoff time.Time) error {
+	name := fmt.Sprintf("twitch-%s-%s.jsonl.gz", channel, cutoff.Format("2006-01-02"))
+	stream, err := s.Files.OpenUploadStream(name)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(stream)
+	err = s.Export(ctx, gz, ExportOptions{Channel: channel, To: cutoff, Format: ExportJsonl})
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		_ = stream.Abort()
+		return err
+	}
+	err = stream.Close()
+	if err != nil {
+		return err
+	}
+	return s.Storage.ChatArchives.Insert(ctx, &storage.ChatArchive{
+		Id:       primitive.NewObjectID(),
+		Channel:  channel,
+		To:       cutoff,
+		GridFsId: stream.Id(),
+		Created:  time.Now(),
+	})
+}
@@ -0,0 +1,33 @@
+package twitch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionFor(t *testing.T) {
+	rules := []string{"tushqa:7", "#Other: 30"}
+	tests := []struct {
+		name    string
+		channel string
+		rules   []string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "channel rule", channel: "tushqa", rules: rules, want: 7 * day},
+		{name: "normalized rule", channel: "other", rules: rules, want: 30 * day},
+		{name: "default", channel: "unknown", rules: rules, want: 90 * day},
+		{name: "wrong rule", channel: "tushqa", rules: []string{"tushqa"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := retentionFor(tt.channel, tt.rules, 90)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("retentionFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("retentionFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
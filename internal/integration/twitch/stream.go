@@ -0,0 +1,178 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/notify"
+	"makarov.dev/bot/internal/storage"
+	"makarov.dev/bot/pkg/helix"
+	"time"
+)
+
+// streamEndMisses сколько опросов подряд трансляции не должно быть в ответе Helix, чтобы сессия закончилась.
+// Один пропуск бывает из-за задержки API или короткого обрыва трансляции
+const streamEndMisses = 3
+
+// streamMiss опросы подряд, в которых не было трансляции открытой сессии
+type streamMiss struct {
+	count int
+	// since время первого пропуска, им закрывается сессия
+	since time.Time
+}
+
+// PollStreams сверяет идущие трансляции с открытыми сессиями и отправляет оповещения о начале и конце
+func (s *Service) PollStreams(ctx context.Context, client *helix.Client) error {
+	channels, err := s.GetChannels()
+	if err != nil {
+		return err
+	}
+	logins := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		logins = append(logins, channel.Name)
+	}
+	streams, err := client.GetStreams(ctx, logins)
+	if err != nil {
+		return err
+	}
+	live := make(map[string]helix.Stream, len(streams))
+	for _, stream := range streams {
+		live[normalizeChannel(stream.UserLogin)] = stream
+	}
+
+	open, err := s.getOpenSessions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, session := range open {
+		stream, isLive := live[session.Channel]
+		if isLive && stream.Id == session.StreamId {
+			s.setLiveSession(session.Channel, session.Id)
+			delete(live, session.Channel)
+			continue
+		}
+		endedAt := time.Now()
+		// новая трансляция канала закрывает предыдущую сразу, пропавшая закрывается после streamEndMisses опросов
+		if !isLive {
+			miss := s.addStreamMiss(session.Id)
+			if miss.count < streamEndMisses {
+				continue
+			}
+			endedAt = miss.since
+		}
+		err = s.endSession(ctx, &session, endedAt)
+		if err != nil {
+			return err
+		}
+		s.sendStreamEnded(&session)
+	}
+	for channel, stream := range live {
+		session := storage.StreamSession{
+			Id:        primitive.NewObjectID(),
+			Channel:   channel,
+			StreamId:  stream.Id,
+			UserName:  stream.UserName,
+			Title:     stream.Title,
+			GameName:  stream.GameName,
+			StartedAt: stream.StartedAt,
+		}
+		err = s.Storage.StreamSessions.Insert(ctx, &session)
+		if err != nil {
+			return err
+		}
+		s.setLiveSession(channel, session.Id)
+		s.sendStreamStarted(&session, stream)
+	}
+	return nil
+}
+
+func (s *Service) GetStreamSessions(ctx context.Context, channel string, limit int64) ([]storage.StreamSession, error) {
+	if channel != "" {
+		channel = normalizeChannel(channel)
+	}
+	return s.Storage.StreamSessions.List(ctx, channel, limit)
+}
+
+func (s *Service) getOpenSessions(ctx context.Context) ([]storage.StreamSession, error) {
+	return s.Storage.StreamSessions.Open(ctx)
+}
+
+func (s *Service) endSession(ctx context.Context, session *storage.StreamSession, at time.Time) error {
+	session.EndedAt = &at
+	err := s.Storage.StreamSessions.End(ctx, session.Id, at)
+	if err != nil {
+		return err
+	}
+	s.liveSessionsMutex.Lock()
+	defer s.liveSessionsMutex.Unlock()
+	delete(s.streamMisses, session.Id)
+	if s.liveSessions[session.Channel] == session.Id {
+		delete(s.liveSessions, session.Channel)
+	}
+	return nil
+}
+
+func (s *Service) setLiveSession(channel string, id primitive.ObjectID) {
+	s.liveSessionsMutex.Lock()
+	defer s.liveSessionsMutex.Unlock()
+	delete(s.streamMisses, id)
+	s.liveSessions[channel] = id
+}
+
+// addStreamMiss отмечает опрос без трансляции сессии и возвращает пропуски подряд
+func (s *Service) addStreamMiss(id primitive.ObjectID) streamMiss {
+	s.liveSessionsMutex.Lock()
+	defer s.liveSessionsMutex.Unlock()
+	miss, found := s.streamMisses[id]
+	if !found {
+		miss.since = time.Now()
+	}
+	miss.count++
+	s.streamMisses[id] = miss
+	return miss
+}
+
+// getLiveSession возвращает идентификатор идущей трансляции канала или nil
+func (s *Service) getLiveSession(channel string) *primitive.ObjectID {
+	s.liveSessionsMutex.RLock()
+	defer s.liveSessionsMutex.RUnlock()
+	id, found := s.liveSessions[channel]
+	if !found {
+		return nil
+	}
+	return &id
+}
+
+func (s *Service) sendStreamStarted(session *storage.StreamSession, stream helix.Stream) {
+	if s.Notifier == nil {
+		return
+	}
+	n := notify.Notification{
+		Text:  fmt.Sprintf("%s начал трансляцию\n%s", session.UserName, session.Title),
+		Links: []notify.Link{{Title: "Twitch", Url: "https://twitch.tv/" + session.Channel}},
+	}
+	if session.GameName != "" {
+		n.Text += fmt.Sprintf("\nИгра: %s", session.GameName)
+	}
+	if stream.ThumbnailUrl != "" {
+		// параметр нужен, чтобы Telegram не взял превью из своего кеша
+		n.ImageUrl = fmt.Sprintf("%s?t=%d", stream.Thumbnail(1280, 720), time.Now().Unix())
+	}
+	err := s.Notifier.Notify(context.Background(), n)
+	if err != nil {
+		s.Logger.Errorf("Error while send twitch stream start to %s %s", s.Notifier.Name(), err.Error())
+	}
+}
+
+func (s *Service) sendStreamEnded(session *storage.StreamSession) {
+	if s.Notifier == nil {
+		return
+	}
+	duration := session.EndedAt.Sub(session.StartedAt).Round(time.Minute)
+	err := s.Notifier.Notify(context.Background(), notify.Notification{
+		Text: fmt.Sprintf("%s закончил трансляцию (%s)", session.UserName, duration),
+	})
+	if err != nil {
+		s.Logger.Errorf("Error while send twitch stream end to %s %s", s.Notifier.Name(), err.Error())
+	}
+}
@@ -0,0 +1,88 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/config"
+	"makarov.dev/bot/internal/storage/memory"
+	"makarov.dev/bot/pkg/helix"
+)
+
+// HelixMock отдает трансляцию канала tushqa с StreamId, пустой StreamId - канал не в эфире
+type HelixMock struct {
+	StreamId string
+}
+
+func (c *HelixMock) Do(req *http.Request) (*http.Response, error) {
+	body := `{"access_token":"token","expires_in":5000000}`
+	if req.URL.Path == "/helix/streams" {
+		body = `{"data":[]}`
+		if c.StreamId != "" {
+			body = fmt.Sprintf(`{"data":[{"id":%q,"user_login":"tushqa","user_name":"Tushqa"}]}`, c.StreamId)
+		}
+	}
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestPollStreamsGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(config.TwitchConfig{}, memory.New(), nil, nil, log.New())
+	if _, err := s.JoinChannel("tushqa"); err != nil {
+		t.Fatal(err)
+	}
+	mock := &HelixMock{StreamId: "1"}
+	client := &helix.Client{Config: helix.ClientConfig{HttpClient: mock}}
+	poll := func() {
+		t.Helper()
+		if err := s.PollStreams(ctx, client); err != nil {
+			t.Fatalf("PollStreams() error = %v", err)
+		}
+	}
+	open := func() []string {
+		t.Helper()
+		sessions, err := s.getOpenSessions(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0, len(sessions))
+		for _, session := range sessions {
+			ids = append(ids, session.StreamId)
+		}
+		return ids
+	}
+
+	poll()
+	// короткий пропуск не заканчивает сессию
+	mock.StreamId = ""
+	for i := 1; i < streamEndMisses; i++ {
+		poll()
+	}
+	mock.StreamId = "1"
+	poll()
+	mock.StreamId = ""
+	for i := 1; i < streamEndMisses; i++ {
+		poll()
+	}
+	if ids := open(); len(ids) != 1 || ids[0] != "1" || s.getLiveSession("tushqa") == nil {
+		t.Fatalf("open sessions = %v after %d misses, want [1]", ids, streamEndMisses-1)
+	}
+	poll()
+	if ids := open(); len(ids) != 0 || s.getLiveSession("tushqa") != nil {
+		t.Fatalf("open sessions = %v after %d misses, want none", ids, streamEndMisses)
+	}
+
+	// новая трансляция закрывает предыдущую сразу
+	mock.StreamId = "2"
+	poll()
+	mock.StreamId = "3"
+	poll()
+	if ids := open(); len(ids) != 1 || ids[0] != "3" {
+		t.Errorf("open sessions = %v, want [3]", ids)
+	}
+}
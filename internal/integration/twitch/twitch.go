@@ -2,101 +2,143 @@ package twitch
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gempir/go-twitch-irc/v2"
+	log "github.com/sirupsen/logrus"
 	"makarov.dev/bot/internal/config"
+	"makarov.dev/bot/internal/integration/file"
+	"makarov.dev/bot/internal/jobs"
+	"makarov.dev/bot/internal/metrics"
+	"makarov.dev/bot/internal/notify"
+	"makarov.dev/bot/internal/storage"
 )
 
-type ChatMessage struct {
-	Id           primitive.ObjectID `bson:"_id" json:"id"`
-	Channel      string             `bson:"channel" json:"channel"`
-	User         ChatUser           `bson:"user" json:"user"`
-	Message      string             `bson:"message" json:"message"`
-	Raw          string             `bson:"raw" json:"raw"`
-	Created      time.Time          `bson:"created" json:"created"`
-	OriginalTime time.Time          `bson:"original_time" json:"originalTime"`
+// Service сохраняет сообщения чатов Twitch, следит за трансляциями и правилами оповещений
+type Service struct {
+	Config  config.TwitchConfig
+	Storage *storage.Storage
+	Files   *file.Service
+	// Notifier чат для оповещений о трансляциях и сообщениях по правилам, nil если не настроен
+	Notifier notify.Notifier
+	Logger   *log.Logger
+
+	tushqaUserIds map[string]any
+
+	ircClient      *twitch.Client
+	ircClientMutex sync.RWMutex
+	// ircConnected соединение с IRC чатом установлено
+	ircConnected atomic.Bool
+
+	// liveSessions идущие трансляции по каналам, используются для привязки сообщений чата
+	liveSessions map[string]primitive.ObjectID
+	// streamMisses пропуски трансляций открытых сессий, защищены liveSessionsMutex
+	streamMisses      map[primitive.ObjectID]streamMiss
+	liveSessionsMutex sync.RWMutex
+
+	alertRules      []*AlertRule
+	alertRulesMutex sync.Mutex
 }
 
-type ChatUser struct {
-	Id   string `bson:"id" json:"id"`
-	Name string `bson:"name" json:"name"`
-}
-
-type TushqaQuote struct {
-	Id      primitive.ObjectID `bson:"_id" json:"id"`
-	Channel string             `bson:"channel" json:"channel"`
-	Message string             `bson:"message" json:"message"`
-	Created time.Time          `bson:"created" json:"created"`
+func NewService(cfg config.TwitchConfig, store *storage.Storage, files *file.Service, notifier notify.Notifier, logger *log.Logger) *Service {
+	s := &Service{
+		Config:        cfg,
+		Storage:       store,
+		Files:         files,
+		Notifier:      notifier,
+		Logger:        logger,
+		tushqaUserIds: make(map[string]any),
+		liveSessions:  make(map[string]primitive.ObjectID),
+		streamMisses:  make(map[primitive.ObjectID]streamMiss),
+		alertRules:    make([]*AlertRule, 0),
+	}
+	for _, tushqaUserId := range cfg.TushqaUserIds {
+		s.tushqaUserIds[tushqaUserId] = nil
+	}
+	return s
 }
 
-var tushqaUserIds = make(map[string]any)
-
-func Start(ctx context.Context) {
-	log := config.GetLogger()
-	cfg := config.GetConfig().Twitch
-	for _, tushqaUserId := range cfg.TushqaUserIds {
-		tushqaUserIds[tushqaUserId] = nil
+// Start читает чаты до отмены run.Context, run.Tick вызывается на подключение и каждое сообщение. Разрыв соединения
+// возвращается ошибкой, повторный запуск остается за вызывающим
+func (s *Service) Start(run *jobs.Run) error {
+	ctx := run.Context()
+	log := s.Logger
+	cfg := s.Config
+	err := s.loadAlertRules()
+	if err != nil {
+		log.Errorf("Error while load twitch alert rules %s", err.Error())
+	}
+	channels, err := s.seedChannels(cfg.Channels)
+	if err != nil {
+		log.Errorf("Error while load twitch channels %s", err.Error())
+		channels = cfg.Channels
 	}
 	client := twitch.NewAnonymousClient()
-	log.Debug(fmt.Sprintf("Going to connect twitch channels %s", strings.Join(cfg.Channels, ", ")))
-	client.Join(cfg.Channels...)
+	log.Debug(fmt.Sprintf("Going to connect twitch channels %s", strings.Join(channels, ", ")))
+	client.Join(channels...)
 	client.OnConnect(func() {
+		s.ircConnected.Store(true)
+		run.Tick()
 		log.Debug("Twitch connected")
 	})
 
-	client.OnPrivateMessage(onMessageReceived)
-
-	err := client.Connect()
-	if err != nil {
-		log.Error(err.Error())
-		time.Sleep(10 * time.Second)
-		Start(ctx)
-	}
+	client.OnPrivateMessage(func(message twitch.PrivateMessage) {
+		run.Tick()
+		s.onMessageReceived(run, message)
+	})
 
-	defer func(client *twitch.Client) {
+	s.setClient(client)
+	// Connect блокирует до разрыва соединения, отмена ctx разрывает его
+	stop := context.AfterFunc(ctx, func() {
 		err := client.Disconnect()
-		if err != nil {
+		if err != nil && !errors.Is(err, twitch.ErrConnectionIsNotOpen) {
 			log.Error(err.Error())
 		}
-	}(client)
-
-	select {
-	case <-ctx.Done():
+	})
+	defer stop()
+	err = client.Connect()
+	s.ircConnected.Store(false)
+	if ctx.Err() != nil {
 		log.Infof("Twitch background job stopped")
-		return
-	default:
+		return nil
 	}
+	if err == nil {
+		err = errors.New("disconnected")
+	}
+	return fmt.Errorf("twitch irc %w", err)
 }
 
-func onMessageReceived(message twitch.PrivateMessage) {
-	log := config.GetLogger()
+// onMessageReceived сохраняет сообщение в горутинах run, паника в них перезапускает чтение чатов
+func (s *Service) onMessageReceived(run *jobs.Run, message twitch.PrivateMessage) {
+	log := s.Logger
 	log.Trace(fmt.Sprintf(
 		"Received twitch message [%s] %s: %s",
 		message.Channel,
 		message.User.Name,
 		message.Message,
 	))
+	metrics.TwitchMessages.WithLabelValues(message.Channel).Inc()
 	msgLink := &message
-	go func() {
-		err := Insert(msgLink)
+	run.Go(func() { s.checkAlerts(message.Channel, message.User.Name, message.Message) })
+	run.Go(func() {
+		err := s.Insert(msgLink)
 		if err != nil {
 			log.Error("Error while insert twitch message", err)
 		}
-	}()
-	go func() {
-		_, isTushqa := tushqaUserIds[message.User.ID]
+	})
+	run.Go(func() {
+		_, isTushqa := s.tushqaUserIds[message.User.ID]
 		if !isTushqa {
 			return
 		}
-		exists, err := TushqaQuoteExists(msgLink)
+		exists, err := s.TushqaQuoteExists(msgLink)
 		if err != nil {
 			log.Error("Error while check existed Tushqa quote", err)
 			return
@@ -105,124 +147,85 @@ func onMessageReceived(message twitch.PrivateMessage) {
 			log.Trace(fmt.Sprintf("Tushqa quote %s already exists", message.Message))
 			return
 		}
-		err = InsertTushqaQuote(msgLink)
+		err = s.InsertTushqaQuote(msgLink)
 		if err != nil {
 			log.Error("Error while save Tushqa quote", err)
 			return
 		}
-	}()
+	})
 }
 
-func Insert(m *twitch.PrivateMessage) error {
+func (s *Service) Insert(m *twitch.PrivateMessage) error {
 	ctx, cancel := getContext()
 	defer cancel()
 
-	_, err := getMessageCollection().InsertOne(ctx, ChatMessage{
+	return s.Storage.ChatMessages.Insert(ctx, &storage.ChatMessage{
 		Id:      primitive.NewObjectID(),
 		Channel: m.Channel,
-		User: ChatUser{
+		User: storage.ChatUser{
 			Id:   m.User.ID,
 			Name: m.User.Name,
 		},
-		Message:      strings.TrimSpace(m.Message),
-		Raw:          m.Raw,
-		Created:      time.Now(),
-		OriginalTime: m.Time,
+		Message:         strings.TrimSpace(m.Message),
+		Raw:             m.Raw,
+		Created:         time.Now(),
+		OriginalTime:    m.Time,
+		StreamSessionId: s.getLiveSession(m.Channel),
 	})
-	if err != nil {
-		return err
-	}
-
-	return nil
 }
 
-func TushqaQuoteExists(m *twitch.PrivateMessage) (bool, error) {
+func (s *Service) TushqaQuoteExists(m *twitch.PrivateMessage) (bool, error) {
 	ctx, cancel := getContext()
 	defer cancel()
-	tushqaQuoteCollection := getTushqaQuoteCollection()
-	limit := int64(1)
-	count, err := tushqaQuoteCollection.CountDocuments(
-		ctx,
-		bson.M{"message": fmt.Sprintf("/^%s$/i", strings.TrimSpace(m.Message))},
-		&options.CountOptions{Limit: &limit},
-	)
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
+	return s.Storage.TushqaQuotes.Exists(ctx, strings.TrimSpace(m.Message))
 }
 
-func InsertTushqaQuote(m *twitch.PrivateMessage) error {
+func (s *Service) InsertTushqaQuote(m *twitch.PrivateMessage) error {
 	ctx, cancel := getContext()
 	defer cancel()
-	tushqaQuoteCollection := getTushqaQuoteCollection()
-	_, err := tushqaQuoteCollection.InsertOne(ctx, TushqaQuote{
+	return s.Storage.TushqaQuotes.Insert(ctx, &storage.TushqaQuote{
 		Id:      primitive.NewObjectID(),
 		Channel: m.Channel,
 		Message: strings.TrimSpace(m.Message),
 		Created: time.Now(),
 	})
-	if err != nil {
-		return err
-	}
-	return nil
 }
 
-func GetLastMessages(channel string, limit string) ([]ChatMessage, error) {
-	collection := getMessageCollection()
+func (s *Service) GetLastMessages(channel string, limit string) ([]storage.ChatMessage, error) {
 	ctx, cancel := getContext()
 	defer cancel()
 	limitIn, err := strconv.ParseInt(limit, 10, 64)
 	if err != nil {
 		limitIn = 100
 	}
-	filter := bson.D{}
-	if len(channel) > 0 {
-		filter = bson.D{{Key: "channel", Value: channel}}
-	}
-	cursor, err := collection.Find(ctx, filter, &options.FindOptions{Sort: bson.D{{Key: "_id", Value: -1}}, Limit: &limitIn})
-	if err != nil {
-		return nil, err
-	}
-	result := make([]ChatMessage, 0)
-	err = cursor.All(ctx, &result)
-	if err != nil {
-		return nil, err
-	}
-	return result, nil
+	return s.Storage.ChatMessages.Last(ctx, channel, limitIn)
 }
 
-func GetTushqaQuotes(limit string) ([]TushqaQuote, error) {
-	collection := getTushqaQuoteCollection()
+func (s *Service) GetTushqaQuotes(limit string) ([]storage.TushqaQuote, error) {
 	ctx, cancel := getContext()
 	defer cancel()
 	limitIn, err := strconv.ParseInt(limit, 10, 64)
 	if err != nil {
 		limitIn = 100
 	}
-	filter := bson.D{}
-	cursor, err := collection.Find(
-		ctx,
-		filter,
-		&options.FindOptions{Sort: bson.D{{Key: "_id", Value: -1}}, Limit: &limitIn},
-	)
-	if err != nil {
-		return nil, err
-	}
-	result := make([]TushqaQuote, 0)
-	err = cursor.All(ctx, &result)
-	if err != nil {
-		return nil, err
-	}
-	return result, nil
+	return s.Storage.TushqaQuotes.Last(ctx, limitIn)
+}
+
+func (s *Service) getClient() *twitch.Client {
+	s.ircClientMutex.RLock()
+	defer s.ircClientMutex.RUnlock()
+	return s.ircClient
 }
 
-func getMessageCollection() *mongo.Collection {
-	return config.GetDatabase().Collection("twitch_chat_messages")
+// Connected подключен ли IRC клиент к чату Twitch
+func (s *Service) Connected() bool {
+	return s.ircConnected.Load()
 }
 
-func getTushqaQuoteCollection() *mongo.Collection {
-	return config.GetDatabase().Collection("twitch_tushqa_quotes")
+func (s *Service) setClient(client *twitch.Client) {
+	s.ircClientMutex.Lock()
+	defer s.ircClientMutex.Unlock()
+	s.ircClient = client
 }
 
 func getContext() (context.Context, context.CancelFunc) {
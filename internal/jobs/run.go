@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Run один запуск задачи. Горутины, которые задача запускает через Go, наблюдаются так же, как сама задача:
+// паника в них отменяет Context запуска и возвращается Supervisor ошибкой, после которой задача перезапускается
+type Run struct {
+	name   string
+	logger *log.Logger
+	tick   func()
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex sync.Mutex
+	err   error
+	wg    sync.WaitGroup
+}
+
+func newRun(ctx context.Context, name string, logger *log.Logger, tick func()) *Run {
+	r := &Run{name: name, logger: logger, tick: tick}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	return r
+}
+
+// Context отменяется при завершении приложения и при панике в горутине запуска.
+// Задача должна остановиться после его отмены
+func (r *Run) Context() context.Context {
+	return r.ctx
+}
+
+// Tick отмечает очередную итерацию
+func (r *Run) Tick() {
+	r.tick()
+}
+
+// Go запускает fn в отдельной горутине под наблюдением Supervisor
+func (r *Run) Go(fn func()) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer r.recover()
+		fn()
+	}()
+}
+
+// recover вызывается отложенно в горутине запуска и превращает панику в ошибку запуска
+func (r *Run) recover() {
+	if p := recover(); p != nil {
+		r.logger.Errorf("Panic in background job %s %v\n%s", r.name, p, debug.Stack())
+		r.fail(fmt.Errorf("panic: %v", p))
+	}
+}
+
+// fail запоминает первую ошибку и останавливает запуск
+func (r *Run) fail(err error) {
+	r.mutex.Lock()
+	if r.err == nil {
+		r.err = err
+	}
+	r.mutex.Unlock()
+	r.cancel()
+}
+
+// wait останавливает запуск, дожидается его горутин и возвращает ошибку запуска
+func (r *Run) wait(err error) error {
+	if err != nil {
+		r.fail(err)
+	}
+	r.cancel()
+	r.wg.Wait()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
@@ -0,0 +1,185 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"makarov.dev/bot/internal/metrics"
+)
+
+const (
+	// StatusRunning задача работает
+	StatusRunning = "running"
+	// StatusBackoff задача упала и ждет перезапуска
+	StatusBackoff = "backoff"
+	// StatusFinished задача завершилась без ошибки, например интеграция выключена
+	StatusFinished = "finished"
+	// StatusStopped задача остановлена при завершении приложения
+	StatusStopped = "stopped"
+)
+
+const (
+	defaultMinBackoff = 5 * time.Second
+	defaultMaxBackoff = 5 * time.Minute
+)
+
+// Job фоновая задача под наблюдением Supervisor
+type Job interface {
+	// Name имя задачи в статусе
+	Name() string
+	// Start блокирует текущую горутину до отмены ctx задачи или run.Context. Ошибка или паника,
+	// в том числе в горутинах run.Go, приводят к перезапуску
+	Start(run *Run) error
+}
+
+// State состояние задачи
+type State struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Restarts сколько раз задача перезапущена после ошибки или паники
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	LastTick  time.Time `json:"last_tick,omitzero"`
+	Started   time.Time `json:"started"`
+}
+
+// Supervisor запускает задачи, восстанавливает их после паники, перезапускает с экспоненциальной паузой
+// и хранит их состояние
+type Supervisor struct {
+	Logger *log.Logger
+	// MinBackoff пауза перед первым перезапуском, удваивается до MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	mutex  sync.Mutex
+	states map[string]*State
+	// order порядок запуска задач для States
+	order []string
+	wg    sync.WaitGroup
+}
+
+// Go запускает задачу в отдельной горутине. Не блокирует текущую горутину
+func (s *Supervisor) Go(ctx context.Context, job Job) {
+	name := job.Name()
+	s.update(name, func(state *State) {
+		state.Status = StatusRunning
+		state.Started = time.Now()
+	})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.supervise(ctx, job)
+	}()
+}
+
+func (s *Supervisor) supervise(ctx context.Context, job Job) {
+	name := job.Name()
+	tick := func() {
+		s.update(name, func(state *State) { state.LastTick = time.Now() })
+	}
+	backoff := s.minBackoff()
+	for {
+		started := time.Now()
+		err := s.run(ctx, job, tick)
+		if ctx.Err() != nil {
+			s.update(name, func(state *State) { state.Status = StatusStopped })
+			return
+		}
+		if err == nil {
+			s.Logger.Infof("Background job %s finished", name)
+			s.update(name, func(state *State) { state.Status = StatusFinished })
+			return
+		}
+		// задача успела поработать дольше максимальной паузы, значит это новый сбой, а не цепочка падений
+		if time.Since(started) > s.maxBackoff() {
+			backoff = s.minBackoff()
+		}
+		s.Logger.Errorf("Error while run background job %s %s, restart in %s", name, err.Error(), backoff)
+		metrics.JobRestarts.WithLabelValues(name).Inc()
+		s.update(name, func(state *State) {
+			state.Status = StatusBackoff
+			state.LastError = err.Error()
+			state.Restarts++
+		})
+		select {
+		case <-ctx.Done():
+			s.update(name, func(state *State) { state.Status = StatusStopped })
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, s.maxBackoff())
+		s.update(name, func(state *State) {
+			state.Status = StatusRunning
+			state.Started = time.Now()
+		})
+	}
+}
+
+// run выполняет задачу и ждет ее горутин. Паника в задаче или ее горутинах возвращается как ошибка
+func (s *Supervisor) run(ctx context.Context, job Job, tick func()) error {
+	r := newRun(ctx, job.Name(), s.Logger, tick)
+	var err error
+	func() {
+		defer r.recover()
+		err = job.Start(r)
+	}()
+	return r.wait(err)
+}
+
+// States копия состояний задач в порядке запуска
+func (s *Supervisor) States() []State {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	states := make([]State, 0, len(s.order))
+	for _, name := range s.order {
+		states = append(states, *s.states[name])
+	}
+	return states
+}
+
+// Wait после отмены ctx ждет, пока задачи остановятся и допишут начатое, но не дольше timeout.
+// Возвращает false, если задачи не успели остановиться
+func (s *Supervisor) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (s *Supervisor) update(name string, fn func(state *State)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.states == nil {
+		s.states = make(map[string]*State)
+	}
+	state, found := s.states[name]
+	if !found {
+		state = &State{Name: name}
+		s.states[name] = state
+		s.order = append(s.order, name)
+	}
+	fn(state)
+}
+
+func (s *Supervisor) minBackoff() time.Duration {
+	if s.MinBackoff > 0 {
+		return s.MinBackoff
+	}
+	return defaultMinBackoff
+}
+
+func (s *Supervisor) maxBackoff() time.Duration {
+	if s.MaxBackoff > 0 {
+		return s.MaxBackoff
+	}
+	return defaultMaxBackoff
+}
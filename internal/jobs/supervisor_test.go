@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// jobMock падает первые failures запусков: нечетные паникой, четные ошибкой, потом работает до отмены ctx
+type jobMock struct {
+	ctx      context.Context
+	failures int
+	starts   int
+}
+
+func (j *jobMock) Name() string {
+	return "mock"
+}
+
+func (j *jobMock) Start(run *Run) error {
+	j.starts++
+	if j.starts <= j.failures {
+		if j.starts%2 == 1 {
+			panic("boom")
+		}
+		return errors.New("broken")
+	}
+	run.Tick()
+	<-j.ctx.Done()
+	return nil
+}
+
+type finishedJob struct{}
+
+func (finishedJob) Name() string {
+	return "disabled"
+}
+
+func (finishedJob) Start(*Run) error {
+	return nil
+}
+
+func TestSupervisor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Supervisor{Logger: log.New(), MinBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+	job := &jobMock{ctx: ctx, failures: 3}
+	s.Go(ctx, job)
+	s.Go(ctx, finishedJob{})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		states := s.States()
+		if states[0].Status == StatusRunning && !states[0].LastTick.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job not restarted: %+v", states)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	states := s.States()
+	if len(states) != 2 || states[0].Name != "mock" || states[1].Name != "disabled" {
+		t.Fatalf("States() = %+v", states)
+	}
+	if states[0].Restarts != 3 || states[0].LastError != "panic: boom" {
+		t.Errorf("mock state = %+v", states[0])
+	}
+	if states[1].Status != StatusFinished || states[1].Restarts != 0 {
+		t.Errorf("disabled state = %+v", states[1])
+	}
+
+	cancel()
+	if !s.Wait(5 * time.Second) {
+		t.Fatal("Wait() = false")
+	}
+	if state := s.States()[0]; state.Status != StatusStopped {
+		t.Errorf("mock status after cancel = %s", state.Status)
+	}
+}
+
+// spawningJob при первом запуске паникует в своей горутине, а сама ждет отмены запуска
+type spawningJob struct {
+	starts atomic.Int32
+}
+
+func (j *spawningJob) Name() string {
+	return "spawning"
+}
+
+func (j *spawningJob) Start(run *Run) error {
+	if j.starts.Add(1) == 1 {
+		run.Go(func() { panic("spawned boom") })
+	} else {
+		run.Tick()
+	}
+	<-run.Context().Done()
+	return nil
+}
+
+func TestSupervisor_spawnedPanic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Supervisor{Logger: log.New(), MinBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+	job := &spawningJob{}
+	s.Go(ctx, job)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state := s.States()[0]
+		if state.Status == StatusRunning && !state.LastTick.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job not restarted after spawned panic: %+v", state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if state := s.States()[0]; state.Restarts != 1 || state.LastError != "panic: spawned boom" {
+		t.Errorf("spawning state = %+v", state)
+	}
+
+	cancel()
+	if !s.Wait(5 * time.Second) {
+		t.Fatal("Wait() = false")
+	}
+	if state := s.States()[0]; state.Status != StatusStopped {
+		t.Errorf("spawning status after cancel = %s", state.Status)
+	}
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "bot"
+
+var (
+	// ScrapeRequests загруженные страницы трекеров, status - код ответа или error, если ответа нет
+	ScrapeRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scrape_requests_total",
+		Help:      "Tracker page scrapes by provider, page and HTTP status.",
+	}, []string{"provider", "page", "status"})
+	ScrapeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scrape_duration_seconds",
+		Help:      "Tracker page scrape latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider", "page"})
+	ItemsIngested = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_ingested_total",
+		Help:      "New tracker items stored.",
+	}, []string{"provider"})
+	TorrentBytesStored = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "torrent_bytes_stored_total",
+		Help:      "Size of torrent files stored.",
+	}, []string{"provider"})
+	// NotificationsSent target - имя канала доставки, например telegram:<chat>
+	NotificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_sent_total",
+		Help:      "Notifications delivered per target.",
+	}, []string{"target"})
+	NotificationsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_failed_total",
+		Help:      "Notifications failed per target.",
+	}, []string{"target"})
+	TwitchMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "twitch_messages_received_total",
+		Help:      "Twitch chat messages received per channel.",
+	}, []string{"channel"})
+	// CacheRequests result - hit или miss
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "HTTP response cache lookups.",
+	}, []string{"result"})
+	JobRestarts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_restarts_total",
+		Help:      "Background job restarts after an error or panic.",
+	}, []string{"job"})
+	HttpRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_client_retries_total",
+		Help:      "Outgoing HTTP request retries per host.",
+	}, []string{"host"})
+	ProxyUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "proxy_up",
+		Help:      "Whether the outbound proxy passed the last check or connection.",
+	}, []string{"proxy"})
+	HttpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency per gin route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Scrape учитывает загрузку страницы трекера. Код 0 означает, что ответа не было
+func Scrape(provider string, page string, status int, latency time.Duration) {
+	code := "error"
+	if status > 0 {
+		code = strconv.Itoa(status)
+	}
+	ScrapeRequests.WithLabelValues(provider, page, code).Inc()
+	ScrapeDuration.WithLabelValues(provider, page).Observe(latency.Seconds())
+}
+
+// Middleware замеряет время ответа. Маршрут берется из шаблона gin, чтобы параметры пути не плодили метки
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HttpDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler отдает метрики в формате Prometheus
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
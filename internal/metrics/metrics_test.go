@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/dl/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/metrics", Handler())
+
+	for _, path := range []string{"/dl/1", "/dl/2", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	Scrape("kinozal", "root", 0, time.Second)
+
+	if n := testutil.ToFloat64(ScrapeRequests.WithLabelValues("kinozal", "root", "error")); n != 1 {
+		t.Errorf("scrape requests = %v, want 1", n)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`bot_http_request_duration_seconds_count{method="GET",route="/dl/:id",status="204"} 2`,
+		`bot_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output has no %s", want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"github.com/mattn/go-mastodon"
+)
+
+// MastodonNotifier публикует оповещения публичными статусами
+type MastodonNotifier struct {
+	Client   *mastodon.Client
+	Language string
+}
+
+func (m *MastodonNotifier) Name() string {
+	return "mastodon"
+}
+
+func (m *MastodonNotifier) Notify(ctx context.Context, n Notification) error {
+	toot := &mastodon.Toot{
+		Status:     n.Text + "\n",
+		Visibility: mastodon.VisibilityPublic,
+		Language:   m.Language,
+	}
+	for _, link := range n.Links {
+		toot.Status += fmt.Sprintf("\n%s %s", link.Title, link.Url)
+	}
+	if len(n.Image) > 0 {
+		attachment, err := m.Client.UploadMediaFromBytes(ctx, n.Image)
+		if err != nil {
+			return fmt.Errorf("upload media %w", err)
+		}
+		toot.MediaIDs = []mastodon.ID{attachment.ID}
+	}
+	_, err := m.Client.PostStatus(ctx, toot)
+	return err
+}
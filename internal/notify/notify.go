@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"makarov.dev/bot/internal/metrics"
+	"makarov.dev/bot/internal/tracing"
+)
+
+// Link кнопка или ссылка под оповещением
+type Link struct {
+	Title string
+	Url   string
+}
+
+// Notification оповещение, которое каждый Notifier отображает по-своему
+type Notification struct {
+	Text string
+	// Image картинка, загружаемая вместе с оповещением
+	Image []byte
+	// ImageUrl картинка по ссылке, используется если Image пустой
+	ImageUrl string
+	Links    []Link
+}
+
+// Notifier канал доставки оповещений
+type Notifier interface {
+	// Name имя канала доставки для логов
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NotifyAll отправляет оповещение во все каналы доставки. Ошибки логируются через onError
+func NotifyAll(ctx context.Context, notifiers []Notifier, n Notification, onError func(notifier Notifier, err error)) {
+	for _, notifier := range notifiers {
+		_, err := tracing.Step(ctx, "notify.Notify", func(ctx context.Context) (any, error) {
+			return nil, notifier.Notify(ctx, n)
+		}, attribute.String("notify.target", notifier.Name()))
+		if err != nil {
+			metrics.NotificationsFailed.WithLabelValues(notifier.Name()).Inc()
+			if onError != nil {
+				onError(notifier, err)
+			}
+			continue
+		}
+		metrics.NotificationsSent.WithLabelValues(notifier.Name()).Inc()
+	}
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// TelegramSender отправитель сообщений Telegram, реализуется telegram.Bot
+type TelegramSender interface {
+	SendMessage(c tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+// TelegramNotifier отправляет оповещения в чат Telegram
+type TelegramNotifier struct {
+	Sender TelegramSender
+	ChatId int64
+}
+
+func (t *TelegramNotifier) Name() string {
+	return fmt.Sprintf("telegram:%d", t.ChatId)
+}
+
+func (t *TelegramNotifier) Notify(_ context.Context, n Notification) error {
+	var markup any
+	if len(n.Links) > 0 {
+		buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(n.Links))
+		for _, link := range n.Links {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonURL(link.Title, link.Url))
+		}
+		markup = tgbotapi.NewInlineKeyboardMarkup(buttons)
+	}
+
+	var msg tgbotapi.Chattable
+	switch {
+	case len(n.Image) > 0:
+		photo := tgbotapi.NewPhotoUpload(t.ChatId, tgbotapi.FileBytes{Name: "img", Bytes: n.Image})
+		photo.Caption = n.Text
+		photo.ReplyMarkup = markup
+		msg = photo
+	case n.ImageUrl != "":
+		photo := tgbotapi.NewPhotoShare(t.ChatId, n.ImageUrl)
+		photo.Caption = n.Text
+		photo.ReplyMarkup = markup
+		msg = photo
+	default:
+		text := tgbotapi.NewMessage(t.ChatId, n.Text)
+		text.ReplyMarkup = markup
+		msg = text
+	}
+	_, err := t.Sender.SendMessage(msg)
+	return err
+}
@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"testing"
+)
+
+type senderMock struct {
+	sent []tgbotapi.Chattable
+}
+
+func (s *senderMock) SendMessage(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	s.sent = append(s.sent, c)
+	return tgbotapi.Message{}, nil
+}
+
+func TestTelegramNotifier_Notify(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     Notification
+		check func(t *testing.T, c tgbotapi.Chattable)
+	}{
+		{
+			name: "text",
+			n:    Notification{Text: "hello"},
+			check: func(t *testing.T, c tgbotapi.Chattable) {
+				msg, ok := c.(tgbotapi.MessageConfig)
+				if !ok || msg.Text != "hello" || msg.ChatID != 42 {
+					t.Errorf("unexpected message %#v", c)
+				}
+				if msg.ReplyMarkup != nil {
+					t.Errorf("unexpected markup %#v", msg.ReplyMarkup)
+				}
+			},
+		},
+		{
+			name: "image url with links",
+			n:    Notification{Text: "caption", ImageUrl: "https://example.com/img.jpg", Links: []Link{{Title: "Twitch", Url: "https://twitch.tv/"}}},
+			check: func(t *testing.T, c tgbotapi.Chattable) {
+				photo, ok := c.(tgbotapi.PhotoConfig)
+				if !ok || photo.FileID != "https://example.com/img.jpg" || photo.Caption != "caption" {
+					t.Errorf("unexpected photo %#v", c)
+				}
+				markup, ok := photo.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+				if !ok || len(markup.InlineKeyboard) != 1 || len(markup.InlineKeyboard[0]) != 1 {
+					t.Errorf("unexpected markup %#v", photo.ReplyMarkup)
+				}
+			},
+		},
+		{
+			name: "image upload",
+			n:    Notification{Text: "caption", Image: []byte{1, 2, 3}, ImageUrl: "https://example.com/img.jpg"},
+			check: func(t *testing.T, c tgbotapi.Chattable) {
+				photo, ok := c.(tgbotapi.PhotoConfig)
+				if !ok || photo.UseExisting || photo.File == nil {
+					t.Errorf("unexpected photo %#v", c)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &senderMock{}
+			notifier := &TelegramNotifier{Sender: sender, ChatId: 42}
+			if err := notifier.Notify(context.Background(), tt.n); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			if len(sender.sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(sender.sent))
+			}
+			tt.check(t, sender.sent[0])
+		})
+	}
+}
@@ -0,0 +1,361 @@
+// Package archive переносимый архив коллекций хранилища: tar.gz с JSONL файлом на каждую коллекцию.
+// Архив не зависит от драйвера, поэтому через него можно перенести данные из MongoDB в SQL и обратно.
+// Полная резервная копия дополнительно содержит files.jsonl со списком файлов и их содержимое в files/<id>
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"makarov.dev/bot/internal/storage"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	extension    = ".jsonl"
+	filesList    = "files" + extension
+	filesPrefix  = "files/"
+	filesCounter = "files"
+)
+
+// ErrNotEmpty архив восстанавливается только в пустое хранилище
+var ErrNotEmpty = errors.New("storage is not empty")
+
+// Options что кроме коллекций попадает в архив
+type Options struct {
+	// Files торрент-файлы и архивы чатов из FileStore, идентификаторы сохраняются
+	Files bool
+}
+
+// collection выгрузка и загрузка одной коллекции
+type collection struct {
+	name string
+	dump func(ctx context.Context, enc *json.Encoder) error
+	load func(ctx context.Context, dec *json.Decoder) (int, error)
+}
+
+func collections(s *storage.Storage) []collection {
+	return []collection{
+		{
+			name: "lostfilm_items",
+			dump: dumpAll(func(ctx context.Context) ([]storage.LostFilmItem, error) { return s.LostFilmItems.Latest(ctx, 0) }),
+			load: loadAll(s.LostFilmItems.Upsert),
+		},
+		{
+			name: "kinozal_items",
+			dump: dumpAll(func(ctx context.Context) ([]storage.KinozalItem, error) { return s.KinozalItems.Latest(ctx, 0) }),
+			load: loadAll(func(ctx context.Context, item *storage.KinozalItem) error {
+				_, err := s.KinozalItems.Upsert(ctx, item)
+				return err
+			}),
+		},
+		{
+			name: "kinozal_favorites",
+			dump: dumpAll(s.KinozalFavorites.List),
+			load: loadAll(s.KinozalFavorites.Upsert),
+		},
+		{
+			name: "twitch_chat_messages",
+			dump: func(ctx context.Context, enc *json.Encoder) error {
+				return s.ChatMessages.Each(ctx, storage.ChatMessageFilter{}, func(m *storage.ChatMessage) error {
+					return enc.Encode(m)
+				})
+			},
+			load: loadAll(s.ChatMessages.Insert),
+		},
+		{
+			name: "twitch_tushqa_quotes",
+			dump: dumpAll(func(ctx context.Context) ([]storage.TushqaQuote, error) { return s.TushqaQuotes.Last(ctx, 0) }),
+			load: loadAll(s.TushqaQuotes.Insert),
+		},
+		{
+			name: "twitch_channels",
+			dump: dumpAll(s.TwitchChannels.List),
+			load: loadAll(s.TwitchChannels.Insert),
+		},
+		{
+			name: "twitch_stream_sessions",
+			dump: dumpAll(func(ctx context.Context) ([]storage.StreamSession, error) { return s.StreamSessions.List(ctx, "", 0) }),
+			load: loadAll(s.StreamSessions.Insert),
+		},
+		{
+			name: "twitch_alert_rules",
+			dump: dumpAll(s.AlertRules.List),
+			load: loadAll(s.AlertRules.Insert),
+		},
+		{
+			name: "twitch_chat_rollups",
+			dump: dumpAll(func(ctx context.Context) ([]storage.ChatRollup, error) {
+				return s.ChatRollups.List(ctx, "", time.Time{}, time.Time{})
+			}),
+			load: loadAll(s.ChatRollups.Upsert),
+		},
+		{
+			name: "twitch_chat_archives",
+			dump: dumpAll(s.ChatArchives.List),
+			load: loadAll(s.ChatArchives.Insert),
+		},
+		{
+			name: "file_downloads",
+			dump: dumpAll(s.Downloads.List),
+			load: loadAll(s.Downloads.Insert),
+		},
+		{
+			name: "tracker_credentials",
+			dump: dumpAll(s.Credentials.List),
+			load: loadAll(s.Credentials.Upsert),
+		},
+	}
+}
+
+func dumpAll[T any](list func(ctx context.Context) ([]T, error)) func(ctx context.Context, enc *json.Encoder) error {
+	return func(ctx context.Context, enc *json.Encoder) error {
+		items, err := list(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range items {
+			if err = enc.Encode(&items[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func loadAll[T any](insert func(ctx context.Context, v *T) error) func(ctx context.Context, dec *json.Decoder) (int, error) {
+	return func(ctx context.Context, dec *json.Decoder) (int, error) {
+		n := 0
+		for {
+			v := new(T)
+			err := dec.Decode(v)
+			if errors.Is(err, io.EOF) {
+				return n, nil
+			}
+			if err != nil {
+				return n, fmt.Errorf("record %d %w", n+1, err)
+			}
+			if err = insert(ctx, v); err != nil {
+				return n, fmt.Errorf("record %d %w", n+1, err)
+			}
+			n++
+		}
+	}
+}
+
+// Export пишет все коллекции в w. Коллекция сначала выгружается во временный файл, так как tar требует размер записи заранее
+func Export(ctx context.Context, s *storage.Storage, w io.Writer, opts Options) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, c := range collections(s) {
+		if err := exportCollection(ctx, tw, c); err != nil {
+			return fmt.Errorf("export %s %w", c.name, err)
+		}
+	}
+	if opts.Files {
+		if err := exportFiles(ctx, tw, s.Files); err != nil {
+			return fmt.Errorf("export files %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func exportCollection(ctx context.Context, tw *tar.Writer, c collection) error {
+	tmp, err := os.CreateTemp("", "bot-export-*"+extension)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	buf := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err = c.dump(ctx, enc); err != nil {
+		return err
+	}
+	if err = buf.Flush(); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    c.name + extension,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, tmp)
+	return err
+}
+
+// exportFiles пишет список файлов перед содержимым, чтобы при импорте имя было известно до загрузки
+func exportFiles(ctx context.Context, tw *tar.Writer, store storage.FileStore) error {
+	files, err := store.List()
+	if err != nil {
+		return err
+	}
+	list := collection{name: filesCounter, dump: dumpAll(func(context.Context) ([]storage.FileInfo, error) { return files, nil })}
+	if err = exportCollection(ctx, tw, list); err != nil {
+		return err
+	}
+	for _, info := range files {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = exportFile(tw, store, info.Id); err != nil {
+			return fmt.Errorf("%s %w", info.Id.Hex(), err)
+		}
+	}
+	return nil
+}
+
+func exportFile(tw *tar.Writer, store storage.FileStore, id primitive.ObjectID) error {
+	f, err := store.Open(id)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	err = tw.WriteHeader(&tar.Header{
+		Name:    filesPrefix + id.Hex(),
+		Mode:    0o644,
+		Size:    f.Length(),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Import загружает коллекции из архива Export и возвращает количество записей по коллекциям.
+// Хранилище с данными не меняется и возвращается ErrNotEmpty: вставка поверх них оборвалась бы
+// на совпадающем идентификаторе, оставив восстановление наполовину сделанным
+func Import(ctx context.Context, s *storage.Storage, r io.Reader) (map[string]int, error) {
+	if err := checkEmpty(ctx, s); err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	byName := make(map[string]collection)
+	for _, c := range collections(s) {
+		byName[c.name+extension] = c
+	}
+	// имена файлов из files.jsonl
+	names := make(map[primitive.ObjectID]string)
+	byName[filesList] = collection{
+		name: filesCounter,
+		load: loadAll(func(_ context.Context, info *storage.FileInfo) error {
+			names[info.Id] = info.Name
+			return nil
+		}),
+	}
+	result := make(map[string]int)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		if hex, found := strings.CutPrefix(header.Name, filesPrefix); found {
+			if err = importFile(s.Files, names, hex, tr); err != nil {
+				return result, fmt.Errorf("import file %s %w", hex, err)
+			}
+			result[filesCounter]++
+			continue
+		}
+		c, found := byName[header.Name]
+		if !found {
+			return result, fmt.Errorf("unknown archive entry %s", header.Name)
+		}
+		n, err := c.load(ctx, json.NewDecoder(tr))
+		if c.name != filesCounter {
+			result[c.name] = n
+		}
+		if err != nil {
+			return result, fmt.Errorf("import %s %w", c.name, err)
+		}
+	}
+}
+
+// errHasRecords останавливает выгрузку на первой записи при проверке checkEmpty
+var errHasRecords = errors.New("has records")
+
+type firstRecordWriter struct{}
+
+func (firstRecordWriter) Write([]byte) (int, error) {
+	return 0, errHasRecords
+}
+
+// checkEmpty возвращает ErrNotEmpty со списком коллекций, в которых уже есть записи
+func checkEmpty(ctx context.Context, s *storage.Storage) error {
+	names := make([]string, 0)
+	for _, c := range collections(s) {
+		err := c.dump(ctx, json.NewEncoder(firstRecordWriter{}))
+		if errors.Is(err, errHasRecords) {
+			names = append(names, c.name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("check %s %w", c.name, err)
+		}
+	}
+	files, err := s.Files.List()
+	if err != nil {
+		return fmt.Errorf("check %s %w", filesCounter, err)
+	}
+	if len(files) > 0 {
+		names = append(names, filesCounter)
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("%w, %s have records", ErrNotEmpty, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func importFile(store storage.FileStore, names map[primitive.ObjectID]string, hex string, r io.Reader) error {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return err
+	}
+	name, found := names[id]
+	if !found {
+		return errors.New("file is missing in " + filesList)
+	}
+	upload, err := store.OpenUploadWithId(id, name)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(upload, r); err != nil {
+		_ = upload.Abort()
+		return err
+	}
+	return upload.Close()
+}
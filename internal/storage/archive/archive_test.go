@@ -0,0 +1,119 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"makarov.dev/bot/internal/storage"
+	"makarov.dev/bot/internal/storage/memory"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	src := memory.New()
+	sessionId := primitive.NewObjectID()
+	item := &storage.LostFilmItem{
+		Id:        primitive.NewObjectID(),
+		Page:      "/series/a",
+		Date:      day,
+		ItemFiles: []storage.LostFilmItemFile{{Quality: "SD", GridFsId: primitive.NewObjectID()}},
+	}
+	if err := src.LostFilmItems.Upsert(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.KinozalFavorites.Upsert(ctx, &storage.KinozalFavorite{Id: primitive.NewObjectID(), DetailId: 7}); err != nil {
+		t.Fatal(err)
+	}
+	message := &storage.ChatMessage{
+		Id:              primitive.NewObjectID(),
+		Channel:         "a",
+		User:            storage.ChatUser{Id: "1", Name: "one"},
+		Message:         "hi",
+		OriginalTime:    day,
+		StreamSessionId: &sessionId,
+	}
+	if err := src.ChatMessages.Insert(ctx, message); err != nil {
+		t.Fatal(err)
+	}
+	rule := &storage.AlertRule{Id: primitive.NewObjectID(), Pattern: "x", RateLimit: time.Minute, Created: day}
+	if err := src.AlertRules.Insert(ctx, rule); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Export(ctx, src, buf, Options{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	dst := memory.New()
+	counts, err := Import(ctx, dst, buf)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if counts["lostfilm_items"] != 1 || counts["twitch_chat_messages"] != 1 || counts["twitch_channels"] != 0 || counts["files"] != 0 {
+		t.Errorf("Import() counts = %v", counts)
+	}
+	// повторное восстановление не трогает заполненное хранилище
+	if _, err := Import(ctx, dst, bytes.NewReader(nil)); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("second Import() error = %v, want ErrNotEmpty", err)
+	}
+
+	got, err := dst.LostFilmItems.GetByPage(ctx, item.Page)
+	if err != nil || got.Id != item.Id || !got.Date.Equal(day) || got.ItemFiles[0] != item.ItemFiles[0] {
+		t.Errorf("imported item = %+v, %v", got, err)
+	}
+	if exists, err := dst.KinozalFavorites.Exists(ctx, 7); err != nil || !exists {
+		t.Errorf("imported favorite Exists() = %v, %v", exists, err)
+	}
+	messages, err := dst.ChatMessages.Last(ctx, "a", 10)
+	if err != nil || len(messages) != 1 || messages[0].Id != message.Id || *messages[0].StreamSessionId != sessionId {
+		t.Errorf("imported messages = %+v, %v", messages, err)
+	}
+	rules, err := dst.AlertRules.List(ctx)
+	if err != nil || len(rules) != 1 || rules[0].RateLimit != time.Minute {
+		t.Errorf("imported rules = %+v, %v", rules, err)
+	}
+}
+
+func TestExportImportFiles(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	id, err := src.Files.Upload("1.torrent", []byte("torrent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := &storage.KinozalItem{Id: primitive.NewObjectID(), Name: "a", DetailId: 1, GridFsId: id}
+	if _, err = src.KinozalItems.Upsert(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err = Export(ctx, src, buf, Options{Files: true}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	dst := memory.New()
+	counts, err := Import(ctx, dst, buf)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if counts["files"] != 1 || counts["kinozal_items"] != 1 {
+		t.Errorf("Import() counts = %v", counts)
+	}
+	items, err := dst.KinozalItems.Latest(ctx, 0)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("imported items = %+v, %v", items, err)
+	}
+	f, err := dst.Files.Open(items[0].GridFsId)
+	if err != nil {
+		t.Fatalf("Open() imported file error = %v", err)
+	}
+	defer f.Close()
+	b, _ := io.ReadAll(f)
+	if string(b) != "torrent" || f.Name() != "1.torrent" {
+		t.Errorf("imported file = %s %s", f.Name(), b)
+	}
+}
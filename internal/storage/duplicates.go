@@ -0,0 +1,32 @@
+package storage
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Дубликаты по натуральному ключу сливаются в самую раннюю запись. Ссылки на файлы дубликатов переносятся в нее,
+// а сами файлы не удаляются: ссылки /dl/<id> на них уже разосланы в Telegram и Mastodon
+
+// MergeLostFilmFiles файлы первой записи и качества из остальных, которых у нее нет. items отсортированы по id
+func MergeLostFilmFiles(items []LostFilmItem) []LostFilmItemFile {
+	files := make([]LostFilmItemFile, 0)
+	qualities := make(map[string]bool)
+	for _, item := range items {
+		for _, f := range item.ItemFiles {
+			if qualities[f.Quality] {
+				continue
+			}
+			qualities[f.Quality] = true
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// MergeKinozalFile торрент первой записи или, если его нет, первый найденный у остальных. items отсортированы по id
+func MergeKinozalFile(items []KinozalItem) primitive.ObjectID {
+	for _, item := range items {
+		if !item.GridFsId.IsZero() {
+			return item.GridFsId
+		}
+	}
+	return primitive.NilObjectID
+}
@@ -0,0 +1,176 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"io/fs"
+	"makarov.dev/bot/internal/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Store хранит файлы в каталоге: содержимое в <id>, имя и время загрузки в <id>.json
+type Store struct {
+	Dir string
+}
+
+type meta struct {
+	Name    string    `json:"name"`
+	Length  int64     `json:"length"`
+	Created time.Time `json:"created"`
+}
+
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{Dir: dir}, nil
+}
+
+func (s *Store) Upload(name string, content []byte) (primitive.ObjectID, error) {
+	upload, err := s.OpenUpload(name)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if _, err = io.Copy(upload, bytes.NewReader(content)); err != nil {
+		_ = upload.Abort()
+		return primitive.NilObjectID, err
+	}
+	return upload.Id(), upload.Close()
+}
+
+func (s *Store) OpenUpload(name string) (storage.FileUpload, error) {
+	return s.OpenUploadWithId(primitive.NewObjectID(), name)
+}
+
+func (s *Store) OpenUploadWithId(id primitive.ObjectID, name string) (storage.FileUpload, error) {
+	tmp, err := os.CreateTemp(s.Dir, id.Hex()+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &upload{store: s, id: id, name: name, tmp: tmp}, nil
+}
+
+func (s *Store) Open(id primitive.ObjectID) (storage.File, error) {
+	b, err := os.ReadFile(s.metaPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := meta{}
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.contentPath(id))
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, meta: m}, nil
+}
+
+// Ping проверяет, что в каталог можно писать: смонтированный только на чтение том иначе заметен лишь при загрузке
+func (s *Store) Ping(context.Context) error {
+	f, err := os.CreateTemp(s.Dir, ".ping-*")
+	if err != nil {
+		return err
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
+
+// List читает метаданные, файлы без метаданных еще не дописаны и пропускаются
+func (s *Store) List() ([]storage.FileInfo, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]storage.FileInfo, 0)
+	for _, entry := range entries {
+		hex, found := strings.CutSuffix(entry.Name(), ".json")
+		if !found || entry.IsDir() {
+			continue
+		}
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(s.metaPath(id))
+		if err != nil {
+			return nil, err
+		}
+		m := meta{}
+		if err = json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("%s %w", entry.Name(), err)
+		}
+		result = append(result, storage.FileInfo{Id: id, Name: m.Name, Length: m.Length})
+	}
+	return result, nil
+}
+
+func (s *Store) contentPath(id primitive.ObjectID) string {
+	return filepath.Join(s.Dir, id.Hex())
+}
+
+func (s *Store) metaPath(id primitive.ObjectID) string {
+	return filepath.Join(s.Dir, id.Hex()+".json")
+}
+
+type upload struct {
+	store  *Store
+	id     primitive.ObjectID
+	name   string
+	tmp    *os.File
+	length int64
+}
+
+func (u *upload) Write(p []byte) (int, error) {
+	n, err := u.tmp.Write(p)
+	u.length += int64(n)
+	return n, err
+}
+
+// Close переносит временный файл на место и только затем пишет метаданные, чтобы Open не увидел недописанный файл
+func (u *upload) Close() error {
+	if err := u.tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(u.tmp.Name(), u.store.contentPath(u.id)); err != nil {
+		return err
+	}
+	b, err := json.Marshal(meta{Name: u.name, Length: u.length, Created: time.Now()})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(u.store.metaPath(u.id), b, 0o644)
+}
+
+func (u *upload) Abort() error {
+	_ = u.tmp.Close()
+	return os.Remove(u.tmp.Name())
+}
+
+func (u *upload) Id() primitive.ObjectID {
+	return u.id
+}
+
+type file struct {
+	*os.File
+	meta meta
+}
+
+func (f *file) Name() string {
+	return f.meta.Name
+}
+
+func (f *file) Length() int64 {
+	return f.meta.Length
+}
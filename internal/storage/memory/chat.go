@@ -0,0 +1,192 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"makarov.dev/bot/internal/storage"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type chatMessageRepository struct {
+	messages table[storage.ChatMessage]
+}
+
+func (r *chatMessageRepository) Insert(_ context.Context, m *storage.ChatMessage) error {
+	r.messages.insert(*m)
+	return nil
+}
+
+func (r *chatMessageRepository) Last(_ context.Context, channel string, n int64) ([]storage.ChatMessage, error) {
+	messages := r.messages.filter(func(v *storage.ChatMessage) bool { return channel == "" || v.Channel == channel })
+	slices.SortFunc(messages, func(a, b storage.ChatMessage) int {
+		return compareIds(b.Id, a.Id)
+	})
+	return limit(messages, n), nil
+}
+
+func (r *chatMessageRepository) Each(ctx context.Context, filter storage.ChatMessageFilter, fn func(m *storage.ChatMessage) error) error {
+	for _, m := range r.find(filter) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *chatMessageRepository) First(_ context.Context, channel string) (*storage.ChatMessage, error) {
+	messages := r.find(storage.ChatMessageFilter{Channel: channel})
+	if len(messages) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return &messages[0], nil
+}
+
+func (r *chatMessageRepository) Channels(_ context.Context) ([]string, error) {
+	result := make([]string, 0)
+	for _, m := range r.messages.filter(all) {
+		if !slices.Contains(result, m.Channel) {
+			result = append(result, m.Channel)
+		}
+	}
+	return result, nil
+}
+
+func (r *chatMessageRepository) CountByUserHour(_ context.Context, filter storage.ChatMessageFilter) ([]storage.ChatRollupUser, error) {
+	type key struct {
+		userId string
+		hour   int
+	}
+	counts := make(map[key]*storage.ChatRollupUser)
+	for _, m := range r.find(filter) {
+		k := key{userId: m.User.Id, hour: m.OriginalTime.UTC().Hour()}
+		u, found := counts[k]
+		if !found {
+			u = &storage.ChatRollupUser{UserId: k.userId, Hour: k.hour}
+			counts[k] = u
+		}
+		u.UserName = m.User.Name
+		u.Count++
+	}
+	result := make([]storage.ChatRollupUser, 0, len(counts))
+	for _, u := range counts {
+		result = append(result, *u)
+	}
+	slices.SortFunc(result, func(a, b storage.ChatRollupUser) int {
+		return cmp.Or(cmp.Compare(a.Hour, b.Hour), cmp.Compare(b.Count, a.Count), strings.Compare(a.UserId, b.UserId))
+	})
+	return result, nil
+}
+
+func (r *chatMessageRepository) Count(_ context.Context, filter storage.ChatMessageFilter) (int64, error) {
+	return int64(len(r.messages.filter(messageMatcher(filter)))), nil
+}
+
+func (r *chatMessageRepository) Delete(_ context.Context, filter storage.ChatMessageFilter) (int64, error) {
+	return int64(r.messages.delete(messageMatcher(filter))), nil
+}
+
+// find подходящие сообщения по возрастанию original_time
+func (r *chatMessageRepository) find(filter storage.ChatMessageFilter) []storage.ChatMessage {
+	messages := r.messages.filter(messageMatcher(filter))
+	slices.SortStableFunc(messages, func(a, b storage.ChatMessage) int {
+		return a.OriginalTime.Compare(b.OriginalTime)
+	})
+	return messages
+}
+
+func messageMatcher(filter storage.ChatMessageFilter) func(v *storage.ChatMessage) bool {
+	return func(v *storage.ChatMessage) bool {
+		if filter.Channel != "" && v.Channel != filter.Channel {
+			return false
+		}
+		return inRange(v.OriginalTime, filter.From, filter.To)
+	}
+}
+
+type tushqaQuoteRepository struct {
+	quotes table[storage.TushqaQuote]
+}
+
+func (r *tushqaQuoteRepository) Exists(_ context.Context, message string) (bool, error) {
+	return r.quotes.any(func(v *storage.TushqaQuote) bool { return strings.EqualFold(v.Message, message) }), nil
+}
+
+func (r *tushqaQuoteRepository) Insert(_ context.Context, quote *storage.TushqaQuote) error {
+	r.quotes.insert(*quote)
+	return nil
+}
+
+func (r *tushqaQuoteRepository) Last(_ context.Context, n int64) ([]storage.TushqaQuote, error) {
+	quotes := r.quotes.filter(all)
+	slices.SortFunc(quotes, func(a, b storage.TushqaQuote) int {
+		return compareIds(b.Id, a.Id)
+	})
+	return limit(quotes, n), nil
+}
+
+type chatRollupRepository struct {
+	mutex   sync.Mutex
+	rollups table[storage.ChatRollup]
+}
+
+func (r *chatRollupRepository) Last(_ context.Context, channel string) (*storage.ChatRollup, error) {
+	var last *storage.ChatRollup
+	for _, rollup := range r.rollups.filter(func(v *storage.ChatRollup) bool { return v.Channel == channel }) {
+		if last == nil || rollup.Day.After(last.Day) {
+			last = &rollup
+		}
+	}
+	if last == nil {
+		return nil, storage.ErrNotFound
+	}
+	return last, nil
+}
+
+func (r *chatRollupRepository) Upsert(_ context.Context, rollup *storage.ChatRollup) error {
+	// блокировка нужна, чтобы между поиском и вставкой не появилась такая же запись
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	match := func(v *storage.ChatRollup) bool { return v.Channel == rollup.Channel && v.Day.Equal(rollup.Day) }
+	updated := r.rollups.update(match, func(v *storage.ChatRollup) {
+		v.Total = rollup.Total
+		v.Users = slices.Clone(rollup.Users)
+		v.Created = rollup.Created
+	})
+	if updated == 0 {
+		r.rollups.insert(*rollup)
+	}
+	return nil
+}
+
+func (r *chatRollupRepository) List(_ context.Context, channel string, from time.Time, to time.Time) ([]storage.ChatRollup, error) {
+	rollups := r.rollups.filter(func(v *storage.ChatRollup) bool {
+		return (channel == "" || v.Channel == channel) && inRange(v.Day, from, to)
+	})
+	slices.SortFunc(rollups, func(a, b storage.ChatRollup) int {
+		return cmp.Or(a.Day.Compare(b.Day), strings.Compare(a.Channel, b.Channel))
+	})
+	return rollups, nil
+}
+
+type chatArchiveRepository struct {
+	archives table[storage.ChatArchive]
+}
+
+func (r *chatArchiveRepository) Insert(_ context.Context, archive *storage.ChatArchive) error {
+	r.archives.insert(*archive)
+	return nil
+}
+
+func (r *chatArchiveRepository) List(_ context.Context) ([]storage.ChatArchive, error) {
+	archives := r.archives.filter(all)
+	slices.SortStableFunc(archives, func(a, b storage.ChatArchive) int {
+		return a.Created.Compare(b.Created)
+	})
+	return archives, nil
+}
@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"context"
+	"makarov.dev/bot/internal/storage"
+	"slices"
+	"strings"
+)
+
+type credentialRepository struct {
+	credentials table[storage.TrackerCredential]
+}
+
+func (r *credentialRepository) Get(_ context.Context, tracker string) (*storage.TrackerCredential, error) {
+	found := r.credentials.filter(func(v *storage.TrackerCredential) bool { return v.Tracker == tracker })
+	if len(found) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (r *credentialRepository) Upsert(_ context.Context, credential *storage.TrackerCredential) error {
+	r.credentials.upsert(
+		func(v *storage.TrackerCredential) bool { return v.Tracker == credential.Tracker },
+		func(v *storage.TrackerCredential) {
+			v.Name = credential.Name
+			v.Value = credential.Value
+			v.Updated = credential.Updated
+		},
+		*credential,
+	)
+	return nil
+}
+
+func (r *credentialRepository) List(_ context.Context) ([]storage.TrackerCredential, error) {
+	result := r.credentials.filter(all)
+	slices.SortFunc(result, func(a, b storage.TrackerCredential) int {
+		return strings.Compare(a.Tracker, b.Tracker)
+	})
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/storage"
+	"slices"
+	"sync"
+)
+
+type downloadRepository struct {
+	entries table[storage.DownloadEntry]
+}
+
+func (r *downloadRepository) Insert(_ context.Context, entry *storage.DownloadEntry) error {
+	r.entries.insert(*entry)
+	return nil
+}
+
+func (r *downloadRepository) List(_ context.Context) ([]storage.DownloadEntry, error) {
+	entries := r.entries.filter(all)
+	slices.SortStableFunc(entries, func(a, b storage.DownloadEntry) int {
+		return a.Created.Compare(b.Created)
+	})
+	return entries, nil
+}
+
+type fileEntry struct {
+	name    string
+	content []byte
+}
+
+type fileStore struct {
+	mutex sync.RWMutex
+	files map[primitive.ObjectID]*fileEntry
+}
+
+func (f *fileStore) Upload(name string, content []byte) (primitive.ObjectID, error) {
+	id := primitive.NewObjectID()
+	f.store(id, name, bytes.Clone(content))
+	return id, nil
+}
+
+func (f *fileStore) OpenUpload(name string) (storage.FileUpload, error) {
+	return f.OpenUploadWithId(primitive.NewObjectID(), name)
+}
+
+func (f *fileStore) OpenUploadWithId(id primitive.ObjectID, name string) (storage.FileUpload, error) {
+	return &fileUpload{store: f, id: id, name: name}, nil
+}
+
+func (f *fileStore) Ping(context.Context) error {
+	return nil
+}
+
+func (f *fileStore) List() ([]storage.FileInfo, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	result := make([]storage.FileInfo, 0, len(f.files))
+	for id, entry := range f.files {
+		result = append(result, storage.FileInfo{Id: id, Name: entry.name, Length: int64(len(entry.content))})
+	}
+	slices.SortFunc(result, func(a, b storage.FileInfo) int {
+		return compareIds(a.Id, b.Id)
+	})
+	return result, nil
+}
+
+func (f *fileStore) Open(id primitive.ObjectID) (storage.File, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	entry, found := f.files[id]
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+	return &file{Reader: bytes.NewReader(entry.content), entry: entry}, nil
+}
+
+func (f *fileStore) store(id primitive.ObjectID, name string, content []byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.files[id] = &fileEntry{name: name, content: content}
+}
+
+type fileUpload struct {
+	bytes.Buffer
+	store *fileStore
+	id    primitive.ObjectID
+	name  string
+}
+
+func (u *fileUpload) Close() error {
+	u.store.store(u.id, u.name, u.Bytes())
+	return nil
+}
+
+func (u *fileUpload) Abort() error {
+	u.Reset()
+	return nil
+}
+
+func (u *fileUpload) Id() primitive.ObjectID {
+	return u.id
+}
+
+type file struct {
+	*bytes.Reader
+	entry *fileEntry
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+func (f *file) Name() string {
+	return f.entry.name
+}
+
+func (f *file) Length() int64 {
+	return int64(len(f.entry.content))
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"makarov.dev/bot/internal/storage"
+	"slices"
+)
+
+type kinozalItemRepository struct {
+	items table[storage.KinozalItem]
+}
+
+func (r *kinozalItemRepository) Exists(_ context.Context, detailId int64, name string) (bool, error) {
+	return r.items.any(func(v *storage.KinozalItem) bool { return v.DetailId == detailId && v.Name == name }), nil
+}
+
+func (r *kinozalItemRepository) Upsert(_ context.Context, item *storage.KinozalItem) (bool, error) {
+	inserted := r.items.upsert(
+		func(v *storage.KinozalItem) bool { return v.DetailId == item.DetailId && v.Name == item.Name },
+		func(*storage.KinozalItem) {},
+		*item,
+	)
+	return inserted, nil
+}
+
+func (r *kinozalItemRepository) Latest(_ context.Context, n int64) ([]storage.KinozalItem, error) {
+	items := r.items.filter(all)
+	slices.SortStableFunc(items, func(a, b storage.KinozalItem) int {
+		return b.Created.Compare(a.Created)
+	})
+	return limit(items, n), nil
+}
+
+type kinozalFavoriteRepository struct {
+	favorites table[storage.KinozalFavorite]
+}
+
+func (r *kinozalFavoriteRepository) Exists(_ context.Context, detailId int64) (bool, error) {
+	return r.favorites.any(func(v *storage.KinozalFavorite) bool { return v.DetailId == detailId }), nil
+}
+
+func (r *kinozalFavoriteRepository) Upsert(_ context.Context, favorite *storage.KinozalFavorite) error {
+	r.favorites.upsert(
+		func(v *storage.KinozalFavorite) bool { return v.DetailId == favorite.DetailId },
+		func(*storage.KinozalFavorite) {},
+		*favorite,
+	)
+	return nil
+}
+
+func (r *kinozalFavoriteRepository) Delete(_ context.Context, detailId int64) error {
+	r.favorites.delete(func(v *storage.KinozalFavorite) bool { return v.DetailId == detailId })
+	return nil
+}
+
+func (r *kinozalFavoriteRepository) List(_ context.Context) ([]storage.KinozalFavorite, error) {
+	favorites := r.favorites.filter(all)
+	slices.SortFunc(favorites, func(a, b storage.KinozalFavorite) int {
+		return cmp.Compare(a.DetailId, b.DetailId)
+	})
+	return favorites, nil
+}
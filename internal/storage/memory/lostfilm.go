@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"makarov.dev/bot/internal/storage"
+	"slices"
+)
+
+type lostFilmItemRepository struct {
+	items table[storage.LostFilmItem]
+}
+
+func (r *lostFilmItemRepository) GetByPage(_ context.Context, page string) (*storage.LostFilmItem, error) {
+	items := r.items.filter(func(v *storage.LostFilmItem) bool { return v.Page == page })
+	if len(items) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return &items[0], nil
+}
+
+func (r *lostFilmItemRepository) Upsert(_ context.Context, item *storage.LostFilmItem) error {
+	r.items.upsert(
+		func(v *storage.LostFilmItem) bool { return v.Page == item.Page },
+		func(v *storage.LostFilmItem) {
+			id, created := v.Id, v.Created
+			*v = cloneLostFilmItem(*item)
+			v.Id, v.Created = id, created
+		},
+		cloneLostFilmItem(*item),
+	)
+	return nil
+}
+
+func (r *lostFilmItemRepository) Latest(_ context.Context, n int64) ([]storage.LostFilmItem, error) {
+	items := r.items.filter(all)
+	slices.SortStableFunc(items, func(a, b storage.LostFilmItem) int {
+		if c := b.Date.Compare(a.Date); c != 0 {
+			return c
+		}
+		return b.Created.Compare(a.Created)
+	})
+	return limit(items, n), nil
+}
+
+// cloneLostFilmItem копирует список файлов, чтобы изменения вызывающего не попадали в хранилище
+func cloneLostFilmItem(item storage.LostFilmItem) storage.LostFilmItem {
+	item.ItemFiles = slices.Clone(item.ItemFiles)
+	return item
+}
@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/storage"
+	"sync"
+	"time"
+)
+
+// New собирает репозитории, которые хранят данные в памяти процесса. Используется в тестах и в режиме --storage=memory
+func New() *storage.Storage {
+	return &storage.Storage{
+		LostFilmItems:    &lostFilmItemRepository{},
+		KinozalItems:     &kinozalItemRepository{},
+		KinozalFavorites: &kinozalFavoriteRepository{},
+		ChatMessages:     &chatMessageRepository{},
+		TushqaQuotes:     &tushqaQuoteRepository{},
+		TwitchChannels:   &twitchChannelRepository{},
+		StreamSessions:   &streamSessionRepository{},
+		AlertRules:       &alertRuleRepository{},
+		ChatRollups:      &chatRollupRepository{},
+		ChatArchives:     &chatArchiveRepository{},
+		Downloads:        &downloadRepository{},
+		Credentials:      &credentialRepository{},
+		Files:            &fileStore{files: make(map[primitive.ObjectID]*fileEntry)},
+		Migrator:         nopMigrator{},
+		Pinger:           nopMigrator{},
+	}
+}
+
+// nopMigrator в памяти нечего мигрировать и не с чем соединяться
+type nopMigrator struct {
+}
+
+func (nopMigrator) Migrate(context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (nopMigrator) Ping(context.Context) error {
+	return nil
+}
+
+// table потокобезопасный список записей одной коллекции
+type table[T any] struct {
+	mutex sync.RWMutex
+	rows  []T
+}
+
+func (t *table[T]) insert(v T) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.rows = append(t.rows, v)
+}
+
+// upsert вызывает fn для подходящих записей или вставляет v, если таких нет. Возвращает true, если запись вставлена
+// insertUnique добавляет v, если нет строки, подходящей под match. Проверка и вставка идут под одной блокировкой
+func (t *table[T]) insertUnique(match func(v *T) bool, v T) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i := range t.rows {
+		if match(&t.rows[i]) {
+			return false
+		}
+	}
+	t.rows = append(t.rows, v)
+	return true
+}
+
+func (t *table[T]) upsert(match func(v *T) bool, fn func(v *T), v T) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	found := false
+	for i := range t.rows {
+		if match(&t.rows[i]) {
+			fn(&t.rows[i])
+			found = true
+		}
+	}
+	if !found {
+		t.rows = append(t.rows, v)
+	}
+	return !found
+}
+
+// filter возвращает копию записей, для которых match вернул true
+func (t *table[T]) filter(match func(v *T) bool) []T {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	result := make([]T, 0)
+	for i := range t.rows {
+		if match(&t.rows[i]) {
+			result = append(result, t.rows[i])
+		}
+	}
+	return result
+}
+
+func (t *table[T]) any(match func(v *T) bool) bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	for i := range t.rows {
+		if match(&t.rows[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// update вызывает fn для каждой подходящей записи и возвращает их количество
+func (t *table[T]) update(match func(v *T) bool, fn func(v *T)) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	n := 0
+	for i := range t.rows {
+		if match(&t.rows[i]) {
+			fn(&t.rows[i])
+			n++
+		}
+	}
+	return n
+}
+
+// delete удаляет подходящие записи и возвращает их количество
+func (t *table[T]) delete(match func(v *T) bool) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	kept := t.rows[:0]
+	for i := range t.rows {
+		if !match(&t.rows[i]) {
+			kept = append(kept, t.rows[i])
+		}
+	}
+	n := len(t.rows) - len(kept)
+	t.rows = kept
+	return n
+}
+
+func all[T any](*T) bool {
+	return true
+}
+
+func limit[T any](rows []T, n int64) []T {
+	if n > 0 && int64(len(rows)) > n {
+		return rows[:n]
+	}
+	return rows
+}
+
+func compareIds(a primitive.ObjectID, b primitive.ObjectID) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+func inRange(t time.Time, from time.Time, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"makarov.dev/bot/internal/storage"
+	"makarov.dev/bot/internal/storage/storagetest"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) *storage.Storage {
+		return New()
+	})
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/storage"
+	"slices"
+	"strings"
+	"time"
+)
+
+type twitchChannelRepository struct {
+	channels table[storage.TwitchChannel]
+}
+
+func (r *twitchChannelRepository) List(_ context.Context) ([]storage.TwitchChannel, error) {
+	channels := r.channels.filter(all)
+	slices.SortFunc(channels, func(a, b storage.TwitchChannel) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return channels, nil
+}
+
+func (r *twitchChannelRepository) Exists(_ context.Context, name string) (bool, error) {
+	return r.channels.any(func(v *storage.TwitchChannel) bool { return v.Name == name }), nil
+}
+
+func (r *twitchChannelRepository) Insert(_ context.Context, channel *storage.TwitchChannel) error {
+	if !r.channels.insertUnique(func(v *storage.TwitchChannel) bool { return v.Name == channel.Name }, *channel) {
+		return storage.ErrDuplicate
+	}
+	return nil
+}
+
+func (r *twitchChannelRepository) Delete(_ context.Context, name string) error {
+	if r.channels.delete(func(v *storage.TwitchChannel) bool { return v.Name == name }) == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+type streamSessionRepository struct {
+	sessions table[storage.StreamSession]
+}
+
+func (r *streamSessionRepository) Insert(_ context.Context, session *storage.StreamSession) error {
+	r.sessions.insert(*session)
+	return nil
+}
+
+func (r *streamSessionRepository) Open(_ context.Context) ([]storage.StreamSession, error) {
+	return r.sessions.filter(func(v *storage.StreamSession) bool { return v.EndedAt == nil }), nil
+}
+
+func (r *streamSessionRepository) End(_ context.Context, id primitive.ObjectID, at time.Time) error {
+	r.sessions.update(
+		func(v *storage.StreamSession) bool { return v.Id == id },
+		func(v *storage.StreamSession) { v.EndedAt = &at },
+	)
+	return nil
+}
+
+func (r *streamSessionRepository) List(_ context.Context, channel string, n int64) ([]storage.StreamSession, error) {
+	sessions := r.sessions.filter(func(v *storage.StreamSession) bool { return channel == "" || v.Channel == channel })
+	slices.SortStableFunc(sessions, func(a, b storage.StreamSession) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	return limit(sessions, n), nil
+}
+
+type alertRuleRepository struct {
+	rules table[storage.AlertRule]
+}
+
+func (r *alertRuleRepository) Insert(_ context.Context, rule *storage.AlertRule) error {
+	r.rules.insert(*rule)
+	return nil
+}
+
+func (r *alertRuleRepository) Delete(_ context.Context, id primitive.ObjectID) error {
+	if r.rules.delete(func(v *storage.AlertRule) bool { return v.Id == id }) == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *alertRuleRepository) List(_ context.Context) ([]storage.AlertRule, error) {
+	return r.rules.filter(all), nil
+}
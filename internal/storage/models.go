@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type LostFilmItem struct {
+	Id              primitive.ObjectID `bson:"_id"`
+	Page            string             `bson:"page"`
+	Name            string             `bson:"name"`
+	EpisodeName     string             `bson:"episode_name"`
+	EpisodeNameFull string             `bson:"episode_name_full"`
+	Date            time.Time          `bson:"date"`
+	Created         time.Time          `bson:"created"`
+	ItemFiles       []LostFilmItemFile `bson:"item_files"`
+	Poster          string             `bson:"poster"`
+	RetryCount      int                `bson:"retry_count"`
+}
+
+type LostFilmItemFile struct {
+	Quality     string             `bson:"quality"`
+	Description string             `bson:"description"`
+	GridFsId    primitive.ObjectID `bson:"grid_fs_id"`
+}
+
+type KinozalFavorite struct {
+	Id       primitive.ObjectID `bson:"_id"`
+	DetailId int64              `bson:"detail_id"`
+}
+
+type KinozalItem struct {
+	Id       primitive.ObjectID `bson:"_id"`
+	Name     string             `bson:"name"`
+	DetailId int64              `bson:"detail_id"`
+	GridFsId primitive.ObjectID `bson:"grid_fs_id"`
+	Created  time.Time          `bson:"created"`
+}
+
+type ChatMessage struct {
+	Id           primitive.ObjectID `bson:"_id" json:"id"`
+	Channel      string             `bson:"channel" json:"channel"`
+	User         ChatUser           `bson:"user" json:"user"`
+	Message      string             `bson:"message" json:"message"`
+	Raw          string             `bson:"raw" json:"raw"`
+	Created      time.Time          `bson:"created" json:"created"`
+	OriginalTime time.Time          `bson:"original_time" json:"originalTime"`
+	// StreamSessionId трансляция, во время которой было отправлено сообщение
+	StreamSessionId *primitive.ObjectID `bson:"stream_session_id,omitempty" json:"streamSessionId,omitempty"`
+}
+
+type ChatUser struct {
+	Id   string `bson:"id" json:"id"`
+	Name string `bson:"name" json:"name"`
+}
+
+type TushqaQuote struct {
+	Id      primitive.ObjectID `bson:"_id" json:"id"`
+	Channel string             `bson:"channel" json:"channel"`
+	Message string             `bson:"message" json:"message"`
+	Created time.Time          `bson:"created" json:"created"`
+}
+
+type TwitchChannel struct {
+	Id      primitive.ObjectID `bson:"_id" json:"id"`
+	Name    string             `bson:"name" json:"name"`
+	Created time.Time          `bson:"created" json:"created"`
+}
+
+// StreamSession одна трансляция канала. EndedAt пустой, пока трансляция идет
+type StreamSession struct {
+	Id        primitive.ObjectID `bson:"_id" json:"id"`
+	Channel   string             `bson:"channel" json:"channel"`
+	StreamId  string             `bson:"stream_id" json:"streamId"`
+	UserName  string             `bson:"user_name" json:"userName"`
+	Title     string             `bson:"title" json:"title"`
+	GameName  string             `bson:"game_name" json:"gameName"`
+	StartedAt time.Time          `bson:"started_at" json:"startedAt"`
+	EndedAt   *time.Time         `bson:"ended_at" json:"endedAt"`
+}
+
+// AlertRule сохраненное правило пересылки сообщений чата
+type AlertRule struct {
+	Id        primitive.ObjectID `bson:"_id"`
+	Channel   string             `bson:"channel"`
+	User      string             `bson:"user"`
+	Pattern   string             `bson:"pattern"`
+	RateLimit time.Duration      `bson:"rate_limit"`
+	Created   time.Time          `bson:"created"`
+}
+
+// ChatRollup дневная статистика сообщений канала. Не удаляется вместе с сообщениями
+type ChatRollup struct {
+	Id      primitive.ObjectID `bson:"_id" json:"id"`
+	Channel string             `bson:"channel" json:"channel"`
+	Day     time.Time          `bson:"day" json:"day"`
+	Total   int64              `bson:"total" json:"total"`
+	Users   []ChatRollupUser   `bson:"users" json:"users"`
+	Created time.Time          `bson:"created" json:"created"`
+}
+
+// ChatRollupUser количество сообщений пользователя за час (UTC)
+type ChatRollupUser struct {
+	UserId   string `bson:"user_id" json:"userId"`
+	UserName string `bson:"user_name" json:"userName"`
+	Hour     int    `bson:"hour" json:"hour"`
+	Count    int64  `bson:"count" json:"count"`
+}
+
+// ChatArchive gzip JSONL файл с удаленными сообщениями канала
+type ChatArchive struct {
+	Id       primitive.ObjectID `bson:"_id" json:"id"`
+	Channel  string             `bson:"channel" json:"channel"`
+	To       time.Time          `bson:"to" json:"to"`
+	GridFsId primitive.ObjectID `bson:"grid_fs_id" json:"gridFsId"`
+	Created  time.Time          `bson:"created" json:"created"`
+}
+
+// TrackerCredential сессия трекера, сохраненная командой /cookie или после входа по логину
+type TrackerCredential struct {
+	Id primitive.ObjectID `bson:"_id"`
+	// Tracker lostfilm или kinozal, уникален
+	Tracker string    `bson:"tracker"`
+	Name    string    `bson:"name"`
+	Value   string    `bson:"value"`
+	Updated time.Time `bson:"updated"`
+}
+
+type DownloadEntry struct {
+	Id         primitive.ObjectID `bson:"_id"`
+	FileId     primitive.ObjectID `bson:"file_id"`
+	RemoteAddr string             `bson:"remote_addr"`
+	UserAgent  string             `bson:"user_agent"`
+	Created    time.Time          `bson:"created"`
+}
@@ -0,0 +1,195 @@
+package mongodb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"makarov.dev/bot/internal/storage"
+	"regexp"
+	"time"
+)
+
+type chatMessageRepository struct {
+	c *mongo.Collection
+}
+
+func (r *chatMessageRepository) Insert(ctx context.Context, m *storage.ChatMessage) error {
+	return insert(ctx, r.c, m)
+}
+
+func (r *chatMessageRepository) Last(ctx context.Context, channel string, limit int64) ([]storage.ChatMessage, error) {
+	filter := bson.D{}
+	if len(channel) > 0 {
+		filter = bson.D{{Key: "channel", Value: channel}}
+	}
+	return find[storage.ChatMessage](ctx, r.c, filter, &options.FindOptions{
+		Sort:  bson.D{{Key: "_id", Value: -1}},
+		Limit: &limit,
+	})
+}
+
+func (r *chatMessageRepository) Each(ctx context.Context, filter storage.ChatMessageFilter, fn func(m *storage.ChatMessage) error) error {
+	cursor, err := r.c.Find(ctx, messageFilter(filter), &options.FindOptions{
+		Sort: bson.D{{Key: "original_time", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		m := storage.ChatMessage{}
+		if err = cursor.Decode(&m); err != nil {
+			return err
+		}
+		if err = fn(&m); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (r *chatMessageRepository) First(ctx context.Context, channel string) (*storage.ChatMessage, error) {
+	return findOne[storage.ChatMessage](ctx, r.c, bson.M{"channel": channel}, &options.FindOneOptions{
+		Sort: bson.D{{Key: "original_time", Value: 1}},
+	})
+}
+
+func (r *chatMessageRepository) Channels(ctx context.Context) ([]string, error) {
+	values, err := r.c.Distinct(ctx, "channel", bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if channel, ok := v.(string); ok {
+			result = append(result, channel)
+		}
+	}
+	return result, nil
+}
+
+func (r *chatMessageRepository) CountByUserHour(ctx context.Context, filter storage.ChatMessageFilter) ([]storage.ChatRollupUser, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: messageFilter(filter)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       bson.M{"user_id": "$user.id", "hour": bson.M{"$hour": "$original_time"}},
+			"user_name": bson.M{"$last": "$user.name"},
+			"count":     bson.M{"$sum": 1},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":       0,
+			"user_id":   "$_id.user_id",
+			"hour":      "$_id.hour",
+			"user_name": 1,
+			"count":     1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "hour", Value: 1}, {Key: "count", Value: -1}}}},
+	}
+	cursor, err := r.c.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]storage.ChatRollupUser, 0)
+	err = cursor.All(ctx, &users)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *chatMessageRepository) Count(ctx context.Context, filter storage.ChatMessageFilter) (int64, error) {
+	return r.c.CountDocuments(ctx, messageFilter(filter))
+}
+
+func (r *chatMessageRepository) Delete(ctx context.Context, filter storage.ChatMessageFilter) (int64, error) {
+	result, err := r.c.DeleteMany(ctx, messageFilter(filter))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func messageFilter(filter storage.ChatMessageFilter) bson.M {
+	f := bson.M{}
+	if filter.Channel != "" {
+		f["channel"] = filter.Channel
+	}
+	if r := timeRange(filter.From, filter.To); len(r) > 0 {
+		f["original_time"] = r
+	}
+	return f
+}
+
+type tushqaQuoteRepository struct {
+	c *mongo.Collection
+}
+
+func (r *tushqaQuoteRepository) Exists(ctx context.Context, message string) (bool, error) {
+	return exists(ctx, r.c, bson.M{"message": primitive.Regex{
+		Pattern: "^" + regexp.QuoteMeta(message) + "$",
+		Options: "i",
+	}})
+}
+
+func (r *tushqaQuoteRepository) Insert(ctx context.Context, quote *storage.TushqaQuote) error {
+	return insert(ctx, r.c, quote)
+}
+
+func (r *tushqaQuoteRepository) Last(ctx context.Context, limit int64) ([]storage.TushqaQuote, error) {
+	return find[storage.TushqaQuote](ctx, r.c, bson.D{}, &options.FindOptions{
+		Sort:  bson.D{{Key: "_id", Value: -1}},
+		Limit: &limit,
+	})
+}
+
+type chatRollupRepository struct {
+	c *mongo.Collection
+}
+
+func (r *chatRollupRepository) Last(ctx context.Context, channel string) (*storage.ChatRollup, error) {
+	return findOne[storage.ChatRollup](ctx, r.c, bson.M{"channel": channel}, &options.FindOneOptions{
+		Sort: bson.D{{Key: "day", Value: -1}},
+	})
+}
+
+func (r *chatRollupRepository) Upsert(ctx context.Context, rollup *storage.ChatRollup) error {
+	_, err := r.c.UpdateOne(
+		ctx,
+		bson.M{"channel": rollup.Channel, "day": rollup.Day},
+		bson.M{
+			"$set":         bson.M{"total": rollup.Total, "users": rollup.Users, "created": rollup.Created},
+			"$setOnInsert": bson.M{"_id": rollup.Id},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *chatRollupRepository) List(ctx context.Context, channel string, from time.Time, to time.Time) ([]storage.ChatRollup, error) {
+	filter := bson.M{}
+	if r := timeRange(from, to); len(r) > 0 {
+		filter["day"] = r
+	}
+	if channel != "" {
+		filter["channel"] = channel
+	}
+	return find[storage.ChatRollup](ctx, r.c, filter, &options.FindOptions{
+		Sort: bson.D{{Key: "day", Value: 1}, {Key: "channel", Value: 1}},
+	})
+}
+
+type chatArchiveRepository struct {
+	c *mongo.Collection
+}
+
+func (r *chatArchiveRepository) Insert(ctx context.Context, archive *storage.ChatArchive) error {
+	return insert(ctx, r.c, archive)
+}
+
+func (r *chatArchiveRepository) List(ctx context.Context) ([]storage.ChatArchive, error) {
+	return find[storage.ChatArchive](ctx, r.c, bson.D{}, &options.FindOptions{
+		Sort: bson.D{{Key: "created", Value: 1}},
+	})
+}
@@ -0,0 +1,40 @@
+package mongodb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"makarov.dev/bot/internal/storage"
+)
+
+type credentialRepository struct {
+	c *mongo.Collection
+}
+
+func (r *credentialRepository) Get(ctx context.Context, tracker string) (*storage.TrackerCredential, error) {
+	return findOne[storage.TrackerCredential](ctx, r.c, bson.M{"tracker": tracker})
+}
+
+func (r *credentialRepository) Upsert(ctx context.Context, credential *storage.TrackerCredential) error {
+	_, err := r.c.UpdateOne(
+		ctx,
+		bson.M{"tracker": credential.Tracker},
+		bson.M{
+			"$set": bson.M{
+				"name":    credential.Name,
+				"value":   credential.Value,
+				"updated": credential.Updated,
+			},
+			"$setOnInsert": bson.M{"_id": credential.Id},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *credentialRepository) List(ctx context.Context) ([]storage.TrackerCredential, error) {
+	return find[storage.TrackerCredential](ctx, r.c, bson.D{}, &options.FindOptions{
+		Sort: bson.D{{Key: "tracker", Value: 1}},
+	})
+}
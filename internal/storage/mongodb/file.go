@@ -0,0 +1,109 @@
+package mongodb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"makarov.dev/bot/internal/storage"
+)
+
+type downloadRepository struct {
+	c *mongo.Collection
+}
+
+func (r *downloadRepository) Insert(ctx context.Context, entry *storage.DownloadEntry) error {
+	return insert(ctx, r.c, entry)
+}
+
+func (r *downloadRepository) List(ctx context.Context) ([]storage.DownloadEntry, error) {
+	return find[storage.DownloadEntry](ctx, r.c, bson.D{}, &options.FindOptions{
+		Sort: bson.D{{Key: "created", Value: 1}},
+	})
+}
+
+type fileStore struct {
+	bucket *gridfs.Bucket
+}
+
+func (f *fileStore) Upload(name string, content []byte) (primitive.ObjectID, error) {
+	return f.bucket.UploadFromStream(name, bytes.NewReader(content))
+}
+
+func (f *fileStore) OpenUpload(name string) (storage.FileUpload, error) {
+	stream, err := f.bucket.OpenUploadStream(name)
+	if err != nil {
+		return nil, err
+	}
+	return &fileUpload{UploadStream: stream}, nil
+}
+
+func (f *fileStore) OpenUploadWithId(id primitive.ObjectID, name string) (storage.FileUpload, error) {
+	stream, err := f.bucket.OpenUploadStreamWithID(id, name)
+	if err != nil {
+		return nil, err
+	}
+	return &fileUpload{UploadStream: stream}, nil
+}
+
+// Ping читает одну запись коллекции файлов GridFS
+func (f *fileStore) Ping(ctx context.Context) error {
+	cursor, err := f.bucket.FindContext(ctx, bson.D{}, options.GridFSFind().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+func (f *fileStore) List() ([]storage.FileInfo, error) {
+	cursor, err := f.bucket.Find(bson.D{}, options.GridFSFind().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]gridfs.File, 0)
+	if err = cursor.All(context.Background(), &files); err != nil {
+		return nil, err
+	}
+	result := make([]storage.FileInfo, 0, len(files))
+	for _, gf := range files {
+		id, _ := gf.ID.(primitive.ObjectID)
+		result = append(result, storage.FileInfo{Id: id, Name: gf.Name, Length: gf.Length})
+	}
+	return result, nil
+}
+
+func (f *fileStore) Open(id primitive.ObjectID) (storage.File, error) {
+	stream, err := f.bucket.OpenDownloadStream(id)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &file{DownloadStream: stream}, nil
+}
+
+type fileUpload struct {
+	*gridfs.UploadStream
+}
+
+func (u *fileUpload) Id() primitive.ObjectID {
+	id, _ := u.FileID.(primitive.ObjectID)
+	return id
+}
+
+type file struct {
+	*gridfs.DownloadStream
+}
+
+func (f *file) Name() string {
+	return f.GetFile().Name
+}
+
+func (f *file) Length() int64 {
+	return f.GetFile().Length
+}
@@ -0,0 +1,66 @@
+package mongodb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"makarov.dev/bot/internal/storage"
+)
+
+type kinozalItemRepository struct {
+	c *mongo.Collection
+}
+
+func (r *kinozalItemRepository) Exists(ctx context.Context, detailId int64, name string) (bool, error) {
+	return exists(ctx, r.c, bson.M{"detail_id": detailId, "name": name})
+}
+
+func (r *kinozalItemRepository) Upsert(ctx context.Context, item *storage.KinozalItem) (bool, error) {
+	res, err := r.c.UpdateOne(
+		ctx,
+		bson.M{"detail_id": item.DetailId, "name": item.Name},
+		bson.M{"$setOnInsert": bson.M{"_id": item.Id, "grid_fs_id": item.GridFsId, "created": item.Created}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+func (r *kinozalItemRepository) Latest(ctx context.Context, limit int64) ([]storage.KinozalItem, error) {
+	return find[storage.KinozalItem](ctx, r.c, bson.D{}, &options.FindOptions{
+		Sort:  bson.D{{Key: "created", Value: -1}},
+		Limit: &limit,
+	})
+}
+
+type kinozalFavoriteRepository struct {
+	c *mongo.Collection
+}
+
+func (r *kinozalFavoriteRepository) Exists(ctx context.Context, detailId int64) (bool, error) {
+	return exists(ctx, r.c, bson.D{{Key: "detail_id", Value: detailId}})
+}
+
+func (r *kinozalFavoriteRepository) Upsert(ctx context.Context, favorite *storage.KinozalFavorite) error {
+	_, err := r.c.UpdateOne(
+		ctx,
+		bson.M{"detail_id": favorite.DetailId},
+		bson.M{"$setOnInsert": bson.M{"_id": favorite.Id}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *kinozalFavoriteRepository) Delete(ctx context.Context, detailId int64) error {
+	_, err := r.c.DeleteOne(ctx, bson.M{"detail_id": detailId})
+	return err
+}
+
+func (r *kinozalFavoriteRepository) List(ctx context.Context) ([]storage.KinozalFavorite, error) {
+	return find[storage.KinozalFavorite](ctx, r.c, bson.D{}, &options.FindOptions{
+		Sort: bson.D{{Key: "detail_id", Value: 1}},
+	})
+}
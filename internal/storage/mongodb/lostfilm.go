@@ -0,0 +1,46 @@
+package mongodb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"makarov.dev/bot/internal/storage"
+)
+
+type lostFilmItemRepository struct {
+	c *mongo.Collection
+}
+
+func (r *lostFilmItemRepository) GetByPage(ctx context.Context, page string) (*storage.LostFilmItem, error) {
+	return findOne[storage.LostFilmItem](ctx, r.c, bson.D{{Key: "page", Value: page}})
+}
+
+func (r *lostFilmItemRepository) Upsert(ctx context.Context, item *storage.LostFilmItem) error {
+	_, err := r.c.UpdateOne(
+		ctx,
+		bson.M{"page": item.Page},
+		bson.M{
+			"$set": bson.M{
+				"name":              item.Name,
+				"episode_name":      item.EpisodeName,
+				"episode_name_full": item.EpisodeNameFull,
+				"date":              item.Date,
+				"item_files":        item.ItemFiles,
+				"poster":            item.Poster,
+				"retry_count":       item.RetryCount,
+			},
+			// время создания остается от первого сохранения
+			"$setOnInsert": bson.M{"_id": item.Id, "created": item.Created},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *lostFilmItemRepository) Latest(ctx context.Context, limit int64) ([]storage.LostFilmItem, error) {
+	return find[storage.LostFilmItem](ctx, r.c, bson.D{}, &options.FindOptions{
+		Sort:  bson.D{{Key: "date", Value: -1}, {Key: "created", Value: -1}},
+		Limit: &limit,
+	})
+}
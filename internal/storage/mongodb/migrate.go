@@ -0,0 +1,244 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"makarov.dev/bot/internal/storage"
+	"time"
+)
+
+// migration изменение схемы базы. Up должен быть идемпотентным: запись о применении делается после него
+type migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+}
+
+// appliedMigration запись о примененной миграции в коллекции schema_migrations
+type appliedMigration struct {
+	Version int       `bson:"_id"`
+	Name    string    `bson:"name"`
+	Applied time.Time `bson:"applied"`
+}
+
+var migrations = []migration{
+	{Version: 1, Name: "create indexes", Up: createIndexes},
+	{Version: 2, Name: "link chat messages to stream sessions", Up: linkMessagesToSessions},
+	{Version: 3, Name: "unique natural keys", Up: uniqueNaturalKeys},
+	{Version: 4, Name: "tracker credentials", Up: createCredentialsIndex},
+}
+
+type migrator struct {
+	db *mongo.Database
+}
+
+func (m *migrator) Migrate(ctx context.Context) ([]string, error) {
+	c := m.db.Collection("schema_migrations")
+	applied, err := find[appliedMigration](ctx, c, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		done[a.Version] = true
+	}
+	result := make([]string, 0)
+	for _, mig := range migrations {
+		if done[mig.Version] {
+			continue
+		}
+		if err = mig.Up(ctx, m.db); err != nil {
+			return result, fmt.Errorf("migration %d %s %w", mig.Version, mig.Name, err)
+		}
+		_, err = c.InsertOne(ctx, appliedMigration{Version: mig.Version, Name: mig.Name, Applied: time.Now()})
+		// миграцию мог параллельно применить другой экземпляр
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return result, err
+		}
+		result = append(result, fmt.Sprintf("%d %s", mig.Version, mig.Name))
+	}
+	return result, nil
+}
+
+func createIndexes(ctx context.Context, db *mongo.Database) error {
+	indexes := map[string][]mongo.IndexModel{
+		"lostfilm_items": {
+			{Keys: bson.D{{Key: "page", Value: 1}}},
+			{Keys: bson.D{{Key: "date", Value: -1}, {Key: "created", Value: -1}}},
+		},
+		"kinozal_items": {
+			{Keys: bson.D{{Key: "detail_id", Value: 1}, {Key: "name", Value: 1}}},
+			{Keys: bson.D{{Key: "created", Value: -1}}},
+		},
+		"kinozal_favorites": {
+			{Keys: bson.D{{Key: "detail_id", Value: 1}}},
+		},
+		"twitch_chat_messages": {
+			{Keys: bson.D{{Key: "channel", Value: 1}, {Key: "original_time", Value: 1}}},
+			{Keys: bson.D{{Key: "original_time", Value: 1}}},
+		},
+		"twitch_channels": {
+			{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"twitch_stream_sessions": {
+			{Keys: bson.D{{Key: "channel", Value: 1}, {Key: "started_at", Value: -1}}},
+			{Keys: bson.D{{Key: "ended_at", Value: 1}}},
+		},
+		"twitch_chat_rollups": {
+			{Keys: bson.D{{Key: "channel", Value: 1}, {Key: "day", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"file_downloads": {
+			{Keys: bson.D{{Key: "file_id", Value: 1}}},
+		},
+	}
+	for collection, models := range indexes {
+		c := db.Collection(collection)
+		// в базе до миграций могут быть дубликаты, с ними уникальный индекс не создастся
+		for _, model := range models {
+			if model.Options == nil || model.Options.Unique == nil || !*model.Options.Unique {
+				continue
+			}
+			if err := deleteDuplicates(ctx, c, model.Keys.(bson.D), nil); err != nil {
+				return fmt.Errorf("%s %w", collection, err)
+			}
+		}
+		_, err := c.Indexes().CreateMany(ctx, models)
+		if err != nil {
+			return fmt.Errorf("%s %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// linkMessagesToSessions проставляет stream_session_id сообщениям, сохраненным до появления трансляций
+func linkMessagesToSessions(ctx context.Context, db *mongo.Database) error {
+	sessions, err := find[storage.StreamSession](ctx, db.Collection("twitch_stream_sessions"), bson.D{})
+	if err != nil {
+		return err
+	}
+	messages := db.Collection("twitch_chat_messages")
+	for _, session := range sessions {
+		period := bson.M{"$gte": session.StartedAt}
+		if session.EndedAt != nil {
+			period["$lt"] = *session.EndedAt
+		}
+		_, err = messages.UpdateMany(ctx, bson.M{
+			"channel":           session.Channel,
+			"original_time":     period,
+			"stream_session_id": bson.M{"$exists": false},
+		}, bson.M{"$set": bson.M{"stream_session_id": session.Id}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueNaturalKeys сливает дубликаты серий и избранного и заменяет индексы натуральных ключей уникальными
+func uniqueNaturalKeys(ctx context.Context, db *mongo.Database) error {
+	keys := []struct {
+		collection string
+		index      string
+		fields     bson.D
+		merge      mergeDuplicates
+	}{
+		{"lostfilm_items", "page_1", bson.D{{Key: "page", Value: 1}}, mergeLostFilmFiles},
+		{"kinozal_items", "detail_id_1_name_1", bson.D{{Key: "detail_id", Value: 1}, {Key: "name", Value: 1}}, mergeKinozalFile},
+		{"kinozal_favorites", "detail_id_1", bson.D{{Key: "detail_id", Value: 1}}, nil},
+	}
+	for _, key := range keys {
+		c := db.Collection(key.collection)
+		if err := deleteDuplicates(ctx, c, key.fields, key.merge); err != nil {
+			return fmt.Errorf("%s %w", key.collection, err)
+		}
+		// индекс с теми же полями без unique нельзя изменить, только пересоздать
+		_, err := c.Indexes().DropOne(ctx, key.index)
+		if err != nil && !isIndexNotFound(err) {
+			return fmt.Errorf("%s %w", key.collection, err)
+		}
+		_, err = c.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: key.fields, Options: options.Index().SetUnique(true)})
+		if err != nil {
+			return fmt.Errorf("%s %w", key.collection, err)
+		}
+	}
+	return nil
+}
+
+// mergeDuplicates переносит в первый из ids, самый ранний, ссылки на файлы остальных
+type mergeDuplicates func(ctx context.Context, c *mongo.Collection, ids []primitive.ObjectID) error
+
+func mergeLostFilmFiles(ctx context.Context, c *mongo.Collection, ids []primitive.ObjectID) error {
+	items, err := find[storage.LostFilmItem](ctx, c, bson.M{"_id": bson.M{"$in": ids}}, &options.FindOptions{
+		Sort: bson.D{{Key: "_id", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.UpdateOne(ctx, bson.M{"_id": ids[0]}, bson.M{"$set": bson.M{"item_files": storage.MergeLostFilmFiles(items)}})
+	return err
+}
+
+func mergeKinozalFile(ctx context.Context, c *mongo.Collection, ids []primitive.ObjectID) error {
+	items, err := find[storage.KinozalItem](ctx, c, bson.M{"_id": bson.M{"$in": ids}}, &options.FindOptions{
+		Sort: bson.D{{Key: "_id", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.UpdateOne(ctx, bson.M{"_id": ids[0]}, bson.M{"$set": bson.M{"grid_fs_id": storage.MergeKinozalFile(items)}})
+	return err
+}
+
+func createCredentialsIndex(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("tracker_credentials").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tracker", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// deleteDuplicates оставляет по одному документу с самым ранним _id на каждое значение полей.
+// merge, если задан, до удаления переносит в оставшийся документ ссылки на файлы остальных
+func deleteDuplicates(ctx context.Context, c *mongo.Collection, fields bson.D, merge mergeDuplicates) error {
+	group := bson.M{}
+	for _, f := range fields {
+		group[f.Key] = "$" + f.Key
+	}
+	cursor, err := c.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{"_id": group, "ids": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	duplicates := make([]struct {
+		Ids []primitive.ObjectID `bson:"ids"`
+	}, 0)
+	if err = cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	for _, d := range duplicates {
+		if merge != nil {
+			if err = merge(ctx, c, d.Ids); err != nil {
+				return err
+			}
+		}
+		_, err = c.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": d.Ids[1:]}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	// 27 IndexNotFound, 26 NamespaceNotFound для еще не созданной коллекции
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 27 || cmdErr.Code == 26)
+}
@@ -0,0 +1,93 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"makarov.dev/bot/internal/storage"
+	"time"
+)
+
+// New собирает репозитории поверх базы MongoDB и GridFS
+func New(db *mongo.Database, bucket *gridfs.Bucket) *storage.Storage {
+	return &storage.Storage{
+		LostFilmItems:    &lostFilmItemRepository{c: db.Collection("lostfilm_items")},
+		KinozalItems:     &kinozalItemRepository{c: db.Collection("kinozal_items")},
+		KinozalFavorites: &kinozalFavoriteRepository{c: db.Collection("kinozal_favorites")},
+		ChatMessages:     &chatMessageRepository{c: db.Collection("twitch_chat_messages")},
+		TushqaQuotes:     &tushqaQuoteRepository{c: db.Collection("twitch_tushqa_quotes")},
+		TwitchChannels:   &twitchChannelRepository{c: db.Collection("twitch_channels")},
+		StreamSessions:   &streamSessionRepository{c: db.Collection("twitch_stream_sessions")},
+		AlertRules:       &alertRuleRepository{c: db.Collection("twitch_alert_rules")},
+		ChatRollups:      &chatRollupRepository{c: db.Collection("twitch_chat_rollups")},
+		ChatArchives:     &chatArchiveRepository{c: db.Collection("twitch_chat_archives")},
+		Downloads:        &downloadRepository{c: db.Collection("file_downloads")},
+		Credentials:      &credentialRepository{c: db.Collection("tracker_credentials")},
+		Files:            &fileStore{bucket: bucket},
+		Migrator:         &migrator{db: db},
+		Pinger:           &pinger{db: db},
+	}
+}
+
+type pinger struct {
+	db *mongo.Database
+}
+
+func (p *pinger) Ping(ctx context.Context) error {
+	return p.db.Client().Ping(ctx, readpref.Primary())
+}
+
+// findOne декодирует первый найденный документ, ErrNoDocuments превращается в storage.ErrNotFound
+func findOne[T any](ctx context.Context, c *mongo.Collection, filter any, opts ...*options.FindOneOptions) (*T, error) {
+	v := new(T)
+	err := c.FindOne(ctx, filter, opts...).Decode(v)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func find[T any](ctx context.Context, c *mongo.Collection, filter any, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := c.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, 0)
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func exists(ctx context.Context, c *mongo.Collection, filter any) (bool, error) {
+	limit := int64(1)
+	count, err := c.CountDocuments(ctx, filter, &options.CountOptions{Limit: &limit})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func insert(ctx context.Context, c *mongo.Collection, v any) error {
+	_, err := c.InsertOne(ctx, v)
+	return err
+}
+
+func timeRange(from time.Time, to time.Time) bson.M {
+	r := bson.M{}
+	if !from.IsZero() {
+		r["$gte"] = from
+	}
+	if !to.IsZero() {
+		r["$lt"] = to
+	}
+	return r
+}
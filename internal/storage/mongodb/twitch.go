@@ -0,0 +1,96 @@
+package mongodb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"makarov.dev/bot/internal/storage"
+	"time"
+)
+
+type twitchChannelRepository struct {
+	c *mongo.Collection
+}
+
+func (r *twitchChannelRepository) List(ctx context.Context) ([]storage.TwitchChannel, error) {
+	return find[storage.TwitchChannel](ctx, r.c, bson.D{}, &options.FindOptions{
+		Sort: bson.D{{Key: "name", Value: 1}},
+	})
+}
+
+func (r *twitchChannelRepository) Exists(ctx context.Context, name string) (bool, error) {
+	return exists(ctx, r.c, bson.M{"name": name})
+}
+
+func (r *twitchChannelRepository) Insert(ctx context.Context, channel *storage.TwitchChannel) error {
+	// уникальность имени обеспечивает индекс twitch_channels.name
+	err := insert(ctx, r.c, channel)
+	if mongo.IsDuplicateKeyError(err) {
+		return storage.ErrDuplicate
+	}
+	return err
+}
+
+func (r *twitchChannelRepository) Delete(ctx context.Context, name string) error {
+	result, err := r.c.DeleteOne(ctx, bson.M{"name": name})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+type streamSessionRepository struct {
+	c *mongo.Collection
+}
+
+func (r *streamSessionRepository) Insert(ctx context.Context, session *storage.StreamSession) error {
+	return insert(ctx, r.c, session)
+}
+
+func (r *streamSessionRepository) Open(ctx context.Context) ([]storage.StreamSession, error) {
+	return find[storage.StreamSession](ctx, r.c, bson.M{"ended_at": nil})
+}
+
+func (r *streamSessionRepository) End(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.c.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"ended_at": at}})
+	return err
+}
+
+func (r *streamSessionRepository) List(ctx context.Context, channel string, limit int64) ([]storage.StreamSession, error) {
+	filter := bson.M{}
+	if channel != "" {
+		filter["channel"] = channel
+	}
+	return find[storage.StreamSession](ctx, r.c, filter, &options.FindOptions{
+		Sort:  bson.D{{Key: "started_at", Value: -1}},
+		Limit: &limit,
+	})
+}
+
+type alertRuleRepository struct {
+	c *mongo.Collection
+}
+
+func (r *alertRuleRepository) Insert(ctx context.Context, rule *storage.AlertRule) error {
+	return insert(ctx, r.c, rule)
+}
+
+func (r *alertRuleRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.c.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *alertRuleRepository) List(ctx context.Context) ([]storage.AlertRule, error) {
+	return find[storage.AlertRule](ctx, r.c, bson.D{})
+}
@@ -0,0 +1,250 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"makarov.dev/bot/internal/storage"
+	"strings"
+	"time"
+)
+
+type chatMessageRepository struct {
+	db *DB
+}
+
+const chatMessageColumns = "id, channel, user_id, user_name, message, raw, created, original_time, stream_session_id"
+
+func (r *chatMessageRepository) Insert(ctx context.Context, m *storage.ChatMessage) error {
+	var sessionId sql.NullString
+	if m.StreamSessionId != nil {
+		sessionId = sql.NullString{String: m.StreamSessionId.Hex(), Valid: true}
+	}
+	_, err := r.db.exec(ctx, "INSERT INTO twitch_chat_messages ("+chatMessageColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		m.Id.Hex(), m.Channel, m.User.Id, m.User.Name, m.Message, m.Raw, unixNano(m.Created), unixNano(m.OriginalTime), sessionId)
+	return err
+}
+
+func (r *chatMessageRepository) Last(ctx context.Context, channel string, limit int64) ([]storage.ChatMessage, error) {
+	where, args := messageWhere(storage.ChatMessageFilter{Channel: channel})
+	return queryAll(ctx, r.db, scanChatMessage,
+		"SELECT "+chatMessageColumns+" FROM twitch_chat_messages"+where+" ORDER BY id DESC"+limitClause(limit), args...)
+}
+
+func (r *chatMessageRepository) Each(ctx context.Context, filter storage.ChatMessageFilter, fn func(m *storage.ChatMessage) error) error {
+	where, args := messageWhere(filter)
+	rows, err := r.db.query(ctx, "SELECT "+chatMessageColumns+" FROM twitch_chat_messages"+where+" ORDER BY original_time", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		m, err := scanChatMessage(rows)
+		if err != nil {
+			return err
+		}
+		if err = fn(&m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *chatMessageRepository) First(ctx context.Context, channel string) (*storage.ChatMessage, error) {
+	return queryOne(ctx, r.db, scanChatMessage,
+		"SELECT "+chatMessageColumns+" FROM twitch_chat_messages WHERE channel = ? ORDER BY original_time LIMIT 1", channel)
+}
+
+func (r *chatMessageRepository) Channels(ctx context.Context) ([]string, error) {
+	return queryAll(ctx, r.db, func(s scanner) (string, error) {
+		var channel string
+		err := s.Scan(&channel)
+		return channel, err
+	}, "SELECT DISTINCT channel FROM twitch_chat_messages")
+}
+
+func (r *chatMessageRepository) CountByUserHour(ctx context.Context, filter storage.ChatMessageFilter) ([]storage.ChatRollupUser, error) {
+	where, args := messageWhere(filter)
+	// время хранится в наносекундах от эпохи, поэтому час UTC считается целочисленно
+	return queryAll(ctx, r.db, func(s scanner) (storage.ChatRollupUser, error) {
+		u := storage.ChatRollupUser{}
+		err := s.Scan(&u.UserId, &u.UserName, &u.Hour, &u.Count)
+		return u, err
+	}, `SELECT user_id, MAX(user_name), (original_time / 3600000000000) % 24 AS hour, COUNT(*) AS cnt
+		FROM twitch_chat_messages`+where+`
+		GROUP BY user_id, hour
+		ORDER BY hour, cnt DESC, user_id`, args...)
+}
+
+func (r *chatMessageRepository) Count(ctx context.Context, filter storage.ChatMessageFilter) (int64, error) {
+	where, args := messageWhere(filter)
+	var count int64
+	err := r.db.conn.QueryRowContext(ctx, r.db.rebind("SELECT COUNT(*) FROM twitch_chat_messages"+where), args...).Scan(&count)
+	return count, err
+}
+
+func (r *chatMessageRepository) Delete(ctx context.Context, filter storage.ChatMessageFilter) (int64, error) {
+	where, args := messageWhere(filter)
+	return r.db.exec(ctx, "DELETE FROM twitch_chat_messages"+where, args...)
+}
+
+func messageWhere(filter storage.ChatMessageFilter) (string, []any) {
+	conditions := make([]string, 0, 3)
+	args := make([]any, 0, 3)
+	if filter.Channel != "" {
+		conditions = append(conditions, "channel = ?")
+		args = append(args, filter.Channel)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "original_time >= ?")
+		args = append(args, unixNano(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "original_time < ?")
+		args = append(args, unixNano(filter.To))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func scanChatMessage(s scanner) (storage.ChatMessage, error) {
+	m := storage.ChatMessage{}
+	var id string
+	var created, originalTime int64
+	var sessionId sql.NullString
+	err := s.Scan(&id, &m.Channel, &m.User.Id, &m.User.Name, &m.Message, &m.Raw, &created, &originalTime, &sessionId)
+	if err != nil {
+		return m, err
+	}
+	if m.Id, err = parseId(id); err != nil {
+		return m, err
+	}
+	m.Created = fromUnixNano(created)
+	m.OriginalTime = fromUnixNano(originalTime)
+	if sessionId.Valid {
+		sid, err := parseId(sessionId.String)
+		if err != nil {
+			return m, err
+		}
+		m.StreamSessionId = &sid
+	}
+	return m, nil
+}
+
+type tushqaQuoteRepository struct {
+	db *DB
+}
+
+// quoteKey ключ для поиска без учета регистра. LOWER в SQLite не понимает кириллицу
+func quoteKey(message string) string {
+	return strings.ToLower(message)
+}
+
+func (r *tushqaQuoteRepository) Exists(ctx context.Context, message string) (bool, error) {
+	return r.db.exists(ctx, "SELECT 1 FROM twitch_tushqa_quotes WHERE message_key = ? LIMIT 1", quoteKey(message))
+}
+
+func (r *tushqaQuoteRepository) Insert(ctx context.Context, quote *storage.TushqaQuote) error {
+	_, err := r.db.exec(ctx, "INSERT INTO twitch_tushqa_quotes (id, channel, message, message_key, created) VALUES (?, ?, ?, ?, ?)",
+		quote.Id.Hex(), quote.Channel, quote.Message, quoteKey(quote.Message), unixNano(quote.Created))
+	return err
+}
+
+func (r *tushqaQuoteRepository) Last(ctx context.Context, limit int64) ([]storage.TushqaQuote, error) {
+	return queryAll(ctx, r.db, func(s scanner) (storage.TushqaQuote, error) {
+		q := storage.TushqaQuote{}
+		var id string
+		var created int64
+		err := s.Scan(&id, &q.Channel, &q.Message, &created)
+		if err != nil {
+			return q, err
+		}
+		q.Id, err = parseId(id)
+		q.Created = fromUnixNano(created)
+		return q, err
+	}, "SELECT id, channel, message, created FROM twitch_tushqa_quotes ORDER BY id DESC"+limitClause(limit))
+}
+
+type chatRollupRepository struct {
+	db *DB
+}
+
+const chatRollupColumns = "id, channel, day, total, users, created"
+
+func (r *chatRollupRepository) Last(ctx context.Context, channel string) (*storage.ChatRollup, error) {
+	return queryOne(ctx, r.db, scanChatRollup,
+		"SELECT "+chatRollupColumns+" FROM twitch_chat_rollups WHERE channel = ? ORDER BY day DESC LIMIT 1", channel)
+}
+
+func (r *chatRollupRepository) Upsert(ctx context.Context, rollup *storage.ChatRollup) error {
+	users, err := json.Marshal(rollup.Users)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.exec(ctx, "INSERT INTO twitch_chat_rollups ("+chatRollupColumns+`) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (channel, day) DO UPDATE SET total = excluded.total, users = excluded.users, created = excluded.created`,
+		rollup.Id.Hex(), rollup.Channel, unixNano(rollup.Day), rollup.Total, string(users), unixNano(rollup.Created))
+	return err
+}
+
+func (r *chatRollupRepository) List(ctx context.Context, channel string, from time.Time, to time.Time) ([]storage.ChatRollup, error) {
+	q := "SELECT " + chatRollupColumns + " FROM twitch_chat_rollups WHERE day >= ?"
+	args := []any{unixNano(from)}
+	if !to.IsZero() {
+		q += " AND day < ?"
+		args = append(args, unixNano(to))
+	}
+	if channel != "" {
+		q += " AND channel = ?"
+		args = append(args, channel)
+	}
+	return queryAll(ctx, r.db, scanChatRollup, q+" ORDER BY day, channel", args...)
+}
+
+func scanChatRollup(s scanner) (storage.ChatRollup, error) {
+	rollup := storage.ChatRollup{}
+	var id, users string
+	var d, created int64
+	err := s.Scan(&id, &rollup.Channel, &d, &rollup.Total, &users, &created)
+	if err != nil {
+		return rollup, err
+	}
+	if rollup.Id, err = parseId(id); err != nil {
+		return rollup, err
+	}
+	rollup.Day = fromUnixNano(d)
+	rollup.Created = fromUnixNano(created)
+	err = json.Unmarshal([]byte(users), &rollup.Users)
+	return rollup, err
+}
+
+type chatArchiveRepository struct {
+	db *DB
+}
+
+func (r *chatArchiveRepository) Insert(ctx context.Context, archive *storage.ChatArchive) error {
+	_, err := r.db.exec(ctx, "INSERT INTO twitch_chat_archives (id, channel, to_time, grid_fs_id, created) VALUES (?, ?, ?, ?, ?)",
+		archive.Id.Hex(), archive.Channel, unixNano(archive.To), archive.GridFsId.Hex(), unixNano(archive.Created))
+	return err
+}
+
+func (r *chatArchiveRepository) List(ctx context.Context) ([]storage.ChatArchive, error) {
+	return queryAll(ctx, r.db, func(s scanner) (storage.ChatArchive, error) {
+		archive := storage.ChatArchive{}
+		var id, fileId string
+		var to, created int64
+		err := s.Scan(&id, &archive.Channel, &to, &fileId, &created)
+		if err != nil {
+			return archive, err
+		}
+		if archive.Id, err = parseId(id); err != nil {
+			return archive, err
+		}
+		archive.GridFsId, err = parseId(fileId)
+		archive.To = fromUnixNano(to)
+		archive.Created = fromUnixNano(created)
+		return archive, err
+	}, "SELECT id, channel, to_time, grid_fs_id, created FROM twitch_chat_archives ORDER BY created")
+}
@@ -0,0 +1,40 @@
+package sqlstore
+
+import (
+	"context"
+	"makarov.dev/bot/internal/storage"
+)
+
+const credentialColumns = "id, tracker, name, value, updated"
+
+type credentialRepository struct {
+	db *DB
+}
+
+func (r *credentialRepository) Get(ctx context.Context, tracker string) (*storage.TrackerCredential, error) {
+	return queryOne(ctx, r.db, scanCredential, "SELECT "+credentialColumns+" FROM tracker_credentials WHERE tracker = ?", tracker)
+}
+
+func (r *credentialRepository) Upsert(ctx context.Context, credential *storage.TrackerCredential) error {
+	_, err := r.db.exec(ctx, "INSERT INTO tracker_credentials ("+credentialColumns+`) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (tracker) DO UPDATE SET name = excluded.name, value = excluded.value, updated = excluded.updated`,
+		credential.Id.Hex(), credential.Tracker, credential.Name, credential.Value, unixNano(credential.Updated))
+	return err
+}
+
+func (r *credentialRepository) List(ctx context.Context) ([]storage.TrackerCredential, error) {
+	return queryAll(ctx, r.db, scanCredential, "SELECT "+credentialColumns+" FROM tracker_credentials ORDER BY tracker")
+}
+
+func scanCredential(s scanner) (storage.TrackerCredential, error) {
+	credential := storage.TrackerCredential{}
+	var id string
+	var updated int64
+	err := s.Scan(&id, &credential.Tracker, &credential.Name, &credential.Value, &updated)
+	if err != nil {
+		return credential, err
+	}
+	credential.Updated = fromUnixNano(updated)
+	credential.Id, err = parseId(id)
+	return credential, err
+}
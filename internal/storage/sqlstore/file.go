@@ -0,0 +1,40 @@
+package sqlstore
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/storage"
+)
+
+type downloadRepository struct {
+	db *DB
+}
+
+func (r *downloadRepository) Insert(ctx context.Context, entry *storage.DownloadEntry) error {
+	_, err := r.db.exec(ctx, "INSERT INTO file_downloads (id, file_id, remote_addr, user_agent, created) VALUES (?, ?, ?, ?, ?)",
+		entry.Id.Hex(), entry.FileId.Hex(), entry.RemoteAddr, entry.UserAgent, unixNano(entry.Created))
+	return err
+}
+
+func (r *downloadRepository) List(ctx context.Context) ([]storage.DownloadEntry, error) {
+	return queryAll(ctx, r.db, func(s scanner) (storage.DownloadEntry, error) {
+		entry := storage.DownloadEntry{}
+		var id, fileId string
+		var created int64
+		err := s.Scan(&id, &fileId, &entry.RemoteAddr, &entry.UserAgent, &created)
+		if err != nil {
+			return entry, err
+		}
+		if entry.Id, err = parseId(id); err != nil {
+			return entry, err
+		}
+		entry.FileId, err = parseId(fileId)
+		entry.Created = fromUnixNano(created)
+		return entry, err
+	}, "SELECT id, file_id, remote_addr, user_agent, created FROM file_downloads ORDER BY created")
+}
+
+// parseId идентификаторы хранятся в hex, чтобы сохранить совместимость с ObjectID из MongoDB
+func parseId(hex string) (primitive.ObjectID, error) {
+	return primitive.ObjectIDFromHex(hex)
+}
@@ -0,0 +1,76 @@
+package sqlstore
+
+import (
+	"context"
+	"makarov.dev/bot/internal/storage"
+)
+
+const kinozalItemColumns = "id, name, detail_id, grid_fs_id, created"
+
+type kinozalItemRepository struct {
+	db *DB
+}
+
+func (r *kinozalItemRepository) Exists(ctx context.Context, detailId int64, name string) (bool, error) {
+	return r.db.exists(ctx, "SELECT 1 FROM kinozal_items WHERE detail_id = ? AND name = ? LIMIT 1", detailId, name)
+}
+
+func (r *kinozalItemRepository) Upsert(ctx context.Context, item *storage.KinozalItem) (bool, error) {
+	n, err := r.db.exec(ctx, `INSERT INTO kinozal_items (id, name, detail_id, grid_fs_id, created) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (detail_id, name) DO NOTHING`,
+		item.Id.Hex(), item.Name, item.DetailId, item.GridFsId.Hex(), unixNano(item.Created))
+	return n > 0, err
+}
+
+func (r *kinozalItemRepository) Latest(ctx context.Context, limit int64) ([]storage.KinozalItem, error) {
+	return queryAll(ctx, r.db, scanKinozalItem,
+		"SELECT "+kinozalItemColumns+" FROM kinozal_items ORDER BY created DESC"+limitClause(limit))
+}
+
+func scanKinozalItem(s scanner) (storage.KinozalItem, error) {
+	item := storage.KinozalItem{}
+	var id, fileId string
+	var created int64
+	err := s.Scan(&id, &item.Name, &item.DetailId, &fileId, &created)
+	if err != nil {
+		return item, err
+	}
+	if item.Id, err = parseId(id); err != nil {
+		return item, err
+	}
+	item.GridFsId, err = parseId(fileId)
+	item.Created = fromUnixNano(created)
+	return item, err
+}
+
+type kinozalFavoriteRepository struct {
+	db *DB
+}
+
+func (r *kinozalFavoriteRepository) Exists(ctx context.Context, detailId int64) (bool, error) {
+	return r.db.exists(ctx, "SELECT 1 FROM kinozal_favorites WHERE detail_id = ? LIMIT 1", detailId)
+}
+
+func (r *kinozalFavoriteRepository) Upsert(ctx context.Context, favorite *storage.KinozalFavorite) error {
+	_, err := r.db.exec(ctx, "INSERT INTO kinozal_favorites (id, detail_id) VALUES (?, ?) ON CONFLICT (detail_id) DO NOTHING",
+		favorite.Id.Hex(), favorite.DetailId)
+	return err
+}
+
+func (r *kinozalFavoriteRepository) Delete(ctx context.Context, detailId int64) error {
+	_, err := r.db.exec(ctx, "DELETE FROM kinozal_favorites WHERE detail_id = ?", detailId)
+	return err
+}
+
+func (r *kinozalFavoriteRepository) List(ctx context.Context) ([]storage.KinozalFavorite, error) {
+	return queryAll(ctx, r.db, func(s scanner) (storage.KinozalFavorite, error) {
+		favorite := storage.KinozalFavorite{}
+		var id string
+		err := s.Scan(&id, &favorite.DetailId)
+		if err != nil {
+			return favorite, err
+		}
+		favorite.Id, err = parseId(id)
+		return favorite, err
+	}, "SELECT id, detail_id FROM kinozal_favorites ORDER BY detail_id")
+}
@@ -0,0 +1,53 @@
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+	"makarov.dev/bot/internal/storage"
+)
+
+type lostFilmItemRepository struct {
+	db *DB
+}
+
+const lostFilmItemColumns = "id, page, name, episode_name, episode_name_full, date, created, item_files, poster, retry_count"
+
+func (r *lostFilmItemRepository) GetByPage(ctx context.Context, page string) (*storage.LostFilmItem, error) {
+	return queryOne(ctx, r.db, scanLostFilmItem, "SELECT "+lostFilmItemColumns+" FROM lostfilm_items WHERE page = ?", page)
+}
+
+func (r *lostFilmItemRepository) Upsert(ctx context.Context, item *storage.LostFilmItem) error {
+	files, err := json.Marshal(item.ItemFiles)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.exec(ctx, "INSERT INTO lostfilm_items ("+lostFilmItemColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (page) DO UPDATE SET name = excluded.name, episode_name = excluded.episode_name,
+		episode_name_full = excluded.episode_name_full, date = excluded.date,
+		item_files = excluded.item_files, poster = excluded.poster, retry_count = excluded.retry_count`,
+		item.Id.Hex(), item.Page, item.Name, item.EpisodeName, item.EpisodeNameFull,
+		unixNano(item.Date), unixNano(item.Created), string(files), item.Poster, item.RetryCount)
+	return err
+}
+
+func (r *lostFilmItemRepository) Latest(ctx context.Context, limit int64) ([]storage.LostFilmItem, error) {
+	return queryAll(ctx, r.db, scanLostFilmItem,
+		"SELECT "+lostFilmItemColumns+" FROM lostfilm_items ORDER BY date DESC, created DESC"+limitClause(limit))
+}
+
+func scanLostFilmItem(s scanner) (storage.LostFilmItem, error) {
+	item := storage.LostFilmItem{}
+	var id, files string
+	var date, created int64
+	err := s.Scan(&id, &item.Page, &item.Name, &item.EpisodeName, &item.EpisodeNameFull, &date, &created, &files, &item.Poster, &item.RetryCount)
+	if err != nil {
+		return item, err
+	}
+	if item.Id, err = parseId(id); err != nil {
+		return item, err
+	}
+	item.Date = fromUnixNano(date)
+	item.Created = fromUnixNano(created)
+	err = json.Unmarshal([]byte(files), &item.ItemFiles)
+	return item, err
+}
@@ -0,0 +1,289 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"makarov.dev/bot/internal/storage"
+	"time"
+)
+
+// migration изменение схемы. Up и запросы выполняются в одной транзакции вместе с записью о применении
+type migration struct {
+	Version int
+	Name    string
+	// Up выполняется перед Statements, если изменение не выразить запросами
+	Up         func(ctx context.Context, tx *migrationTx) error
+	Statements []string
+}
+
+var migrations = []migration{
+	{Version: 1, Name: "create tables", Statements: []string{
+		`CREATE TABLE IF NOT EXISTS lostfilm_items (
+			id TEXT PRIMARY KEY,
+			page TEXT NOT NULL,
+			name TEXT NOT NULL,
+			episode_name TEXT NOT NULL,
+			episode_name_full TEXT NOT NULL,
+			date BIGINT NOT NULL,
+			created BIGINT NOT NULL,
+			item_files TEXT NOT NULL,
+			poster TEXT NOT NULL,
+			retry_count INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS kinozal_items (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			detail_id BIGINT NOT NULL,
+			grid_fs_id TEXT NOT NULL,
+			created BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS kinozal_favorites (
+			id TEXT PRIMARY KEY,
+			detail_id BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS twitch_chat_messages (
+			id TEXT PRIMARY KEY,
+			channel TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			user_name TEXT NOT NULL,
+			message TEXT NOT NULL,
+			raw TEXT NOT NULL,
+			created BIGINT NOT NULL,
+			original_time BIGINT NOT NULL,
+			stream_session_id TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS twitch_tushqa_quotes (
+			id TEXT PRIMARY KEY,
+			channel TEXT NOT NULL,
+			message TEXT NOT NULL,
+			message_key TEXT NOT NULL,
+			created BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS twitch_channels (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			created BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS twitch_stream_sessions (
+			id TEXT PRIMARY KEY,
+			channel TEXT NOT NULL,
+			stream_id TEXT NOT NULL,
+			user_name TEXT NOT NULL,
+			title TEXT NOT NULL,
+			game_name TEXT NOT NULL,
+			started_at BIGINT NOT NULL,
+			ended_at BIGINT
+		)`,
+		`CREATE TABLE IF NOT EXISTS twitch_alert_rules (
+			id TEXT PRIMARY KEY,
+			channel TEXT NOT NULL,
+			user_name TEXT NOT NULL,
+			pattern TEXT NOT NULL,
+			rate_limit BIGINT NOT NULL,
+			created BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS twitch_chat_rollups (
+			id TEXT PRIMARY KEY,
+			channel TEXT NOT NULL,
+			day BIGINT NOT NULL,
+			total BIGINT NOT NULL,
+			users TEXT NOT NULL,
+			created BIGINT NOT NULL,
+			UNIQUE (channel, day)
+		)`,
+		`CREATE TABLE IF NOT EXISTS twitch_chat_archives (
+			id TEXT PRIMARY KEY,
+			channel TEXT NOT NULL,
+			to_time BIGINT NOT NULL,
+			grid_fs_id TEXT NOT NULL,
+			created BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS file_downloads (
+			id TEXT PRIMARY KEY,
+			file_id TEXT NOT NULL,
+			remote_addr TEXT NOT NULL,
+			user_agent TEXT NOT NULL,
+			created BIGINT NOT NULL
+		)`,
+	}},
+	{Version: 2, Name: "create indexes", Statements: []string{
+		`CREATE INDEX IF NOT EXISTS lostfilm_items_page ON lostfilm_items (page)`,
+		`CREATE INDEX IF NOT EXISTS lostfilm_items_date ON lostfilm_items (date, created)`,
+		`CREATE INDEX IF NOT EXISTS kinozal_items_detail_id ON kinozal_items (detail_id, name)`,
+		`CREATE INDEX IF NOT EXISTS kinozal_items_created ON kinozal_items (created)`,
+		`CREATE INDEX IF NOT EXISTS kinozal_favorites_detail_id ON kinozal_favorites (detail_id)`,
+		`CREATE INDEX IF NOT EXISTS twitch_chat_messages_channel_time ON twitch_chat_messages (channel, original_time)`,
+		`CREATE INDEX IF NOT EXISTS twitch_chat_messages_time ON twitch_chat_messages (original_time)`,
+		`CREATE INDEX IF NOT EXISTS twitch_tushqa_quotes_key ON twitch_tushqa_quotes (message_key)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS twitch_channels_name ON twitch_channels (name)`,
+		`CREATE INDEX IF NOT EXISTS twitch_stream_sessions_channel ON twitch_stream_sessions (channel, started_at)`,
+		`CREATE INDEX IF NOT EXISTS file_downloads_file_id ON file_downloads (file_id)`,
+	}},
+	{Version: 3, Name: "link chat messages to stream sessions", Statements: []string{
+		`UPDATE twitch_chat_messages SET stream_session_id = (
+			SELECT s.id FROM twitch_stream_sessions s
+			WHERE s.channel = twitch_chat_messages.channel
+				AND s.started_at <= twitch_chat_messages.original_time
+				AND (s.ended_at IS NULL OR twitch_chat_messages.original_time < s.ended_at)
+			LIMIT 1
+		) WHERE stream_session_id IS NULL`,
+	}},
+	// остаются самые ранние записи: id - ObjectID, который растет со временем создания.
+	// Ссылки на файлы удаляемых записей переносятся в оставшиеся, как и в MongoDB
+	{Version: 4, Name: "unique natural keys", Up: mergeDuplicates, Statements: []string{
+		`DELETE FROM lostfilm_items WHERE id NOT IN (SELECT MIN(id) FROM lostfilm_items GROUP BY page)`,
+		`DELETE FROM kinozal_items WHERE id NOT IN (SELECT MIN(id) FROM kinozal_items GROUP BY detail_id, name)`,
+		`DELETE FROM kinozal_favorites WHERE id NOT IN (SELECT MIN(id) FROM kinozal_favorites GROUP BY detail_id)`,
+		`DROP INDEX IF EXISTS lostfilm_items_page`,
+		`DROP INDEX IF EXISTS kinozal_items_detail_id`,
+		`DROP INDEX IF EXISTS kinozal_favorites_detail_id`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS lostfilm_items_page ON lostfilm_items (page)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS kinozal_items_detail_id ON kinozal_items (detail_id, name)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS kinozal_favorites_detail_id ON kinozal_favorites (detail_id)`,
+	}},
+	{Version: 5, Name: "tracker credentials", Statements: []string{
+		`CREATE TABLE IF NOT EXISTS tracker_credentials (
+			id TEXT PRIMARY KEY,
+			tracker TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL,
+			value TEXT NOT NULL,
+			updated BIGINT NOT NULL
+		)`,
+	}},
+}
+
+// Migrate создает таблицу schema_migrations и применяет недостающие миграции
+func (db *DB) Migrate(ctx context.Context) ([]string, error) {
+	_, err := db.conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := queryAll(ctx, db, func(s scanner) (int, error) {
+		var v int
+		err := s.Scan(&v)
+		return v, err
+	}, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		done[v] = true
+	}
+	result := make([]string, 0)
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+		if err = db.apply(ctx, m); err != nil {
+			return result, fmt.Errorf("migration %d %s %w", m.Version, m.Name, err)
+		}
+		result = append(result, fmt.Sprintf("%d %s", m.Version, m.Name))
+	}
+	return result, nil
+}
+
+func (db *DB) apply(ctx context.Context, m migration) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if m.Up != nil {
+		if err = m.Up(ctx, &migrationTx{tx: tx, db: db}); err != nil {
+			return err
+		}
+	}
+	for _, q := range m.Statements {
+		if _, err = tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, db.rebind("INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)"),
+		m.Version, m.Name, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrationTx транзакция миграции с плейсхолдерами ? как у DB
+type migrationTx struct {
+	tx *sql.Tx
+	db *DB
+}
+
+func (t *migrationTx) exec(ctx context.Context, q string, args ...any) error {
+	_, err := t.tx.ExecContext(ctx, t.db.rebind(q), args...)
+	return err
+}
+
+// txQueryAll собирает строки запроса в транзакции через scan
+func txQueryAll[T any](ctx context.Context, t *migrationTx, scan func(s scanner) (T, error), q string, args ...any) ([]T, error) {
+	rows, err := t.tx.QueryContext(ctx, t.db.rebind(q), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]T, 0)
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, rows.Err()
+}
+
+// mergeDuplicates переносит в самую раннюю запись серии или торрента ссылки на файлы ее дубликатов
+func mergeDuplicates(ctx context.Context, t *migrationTx) error {
+	items, err := txQueryAll(ctx, t, scanLostFilmItem, "SELECT "+lostFilmItemColumns+" FROM lostfilm_items ORDER BY page, id")
+	if err != nil {
+		return err
+	}
+	for _, group := range groupBy(items, func(item storage.LostFilmItem) string { return item.Page }) {
+		if len(group) < 2 {
+			continue
+		}
+		files, err := json.Marshal(storage.MergeLostFilmFiles(group))
+		if err != nil {
+			return err
+		}
+		if err = t.exec(ctx, "UPDATE lostfilm_items SET item_files = ? WHERE id = ?", string(files), group[0].Id.Hex()); err != nil {
+			return err
+		}
+	}
+	torrents, err := txQueryAll(ctx, t, scanKinozalItem, "SELECT "+kinozalItemColumns+" FROM kinozal_items ORDER BY detail_id, name, id")
+	if err != nil {
+		return err
+	}
+	for _, group := range groupBy(torrents, func(item storage.KinozalItem) string { return fmt.Sprintf("%d %s", item.DetailId, item.Name) }) {
+		if len(group) < 2 {
+			continue
+		}
+		err = t.exec(ctx, "UPDATE kinozal_items SET grid_fs_id = ? WHERE id = ?", storage.MergeKinozalFile(group).Hex(), group[0].Id.Hex())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupBy делит отсортированные по ключу записи на группы с одинаковым ключом
+func groupBy[T any](items []T, key func(v T) string) [][]T {
+	groups := make([][]T, 0)
+	for i, item := range items {
+		if i == 0 || key(items[i-1]) != key(item) {
+			groups = append(groups, make([]T, 0, 2))
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], item)
+	}
+	return groups
+}
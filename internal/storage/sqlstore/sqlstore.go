@@ -0,0 +1,186 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"makarov.dev/bot/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DriverSqlite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Open подключается к SQLite (dsn - путь к файлу) или PostgreSQL. Таблицы создаются миграциями, см. DB.Migrate
+func Open(ctx context.Context, driver string, dsn string) (*DB, error) {
+	var name string
+	switch driver {
+	case DriverSqlite:
+		name = "sqlite3"
+		if !strings.HasPrefix(dsn, "file:") {
+			dsn = "file:" + dsn
+		}
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		// ожидание блокировки нужно при параллельной записи из фоновых задач
+		dsn = dsn + sep + "_pragma=busy_timeout(10000)"
+	case DriverPostgres:
+		name = "pgx"
+	default:
+		return nil, fmt.Errorf("unknown sql driver %s", driver)
+	}
+	conn, err := sql.Open(name, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == DriverSqlite {
+		// SQLite допускает одного писателя, один коннект избавляет от SQLITE_BUSY
+		conn.SetMaxOpenConns(1)
+	}
+	if err = conn.PingContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &DB{conn: conn, postgres: driver == DriverPostgres}, nil
+}
+
+// DB соединение с базой и диалект запросов
+type DB struct {
+	conn     *sql.DB
+	postgres bool
+}
+
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	return db.conn.PingContext(ctx)
+}
+
+// New собирает репозитории поверх SQL базы. Файлы хранятся отдельно в files
+func New(db *DB, files storage.FileStore) *storage.Storage {
+	return &storage.Storage{
+		LostFilmItems:    &lostFilmItemRepository{db: db},
+		KinozalItems:     &kinozalItemRepository{db: db},
+		KinozalFavorites: &kinozalFavoriteRepository{db: db},
+		ChatMessages:     &chatMessageRepository{db: db},
+		TushqaQuotes:     &tushqaQuoteRepository{db: db},
+		TwitchChannels:   &twitchChannelRepository{db: db},
+		StreamSessions:   &streamSessionRepository{db: db},
+		AlertRules:       &alertRuleRepository{db: db},
+		ChatRollups:      &chatRollupRepository{db: db},
+		ChatArchives:     &chatArchiveRepository{db: db},
+		Downloads:        &downloadRepository{db: db},
+		Credentials:      &credentialRepository{db: db},
+		Files:            files,
+		Migrator:         db,
+		Pinger:           db,
+	}
+}
+
+// rebind заменяет плейсхолдеры ? на $1, $2... для PostgreSQL
+func (db *DB) rebind(q string) string {
+	if !db.postgres {
+		return q
+	}
+	b := strings.Builder{}
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (db *DB) exec(ctx context.Context, q string, args ...any) (int64, error) {
+	result, err := db.conn.ExecContext(ctx, db.rebind(q), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (db *DB) query(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	return db.conn.QueryContext(ctx, db.rebind(q), args...)
+}
+
+func (db *DB) exists(ctx context.Context, q string, args ...any) (bool, error) {
+	var one int
+	err := db.conn.QueryRowContext(ctx, db.rebind(q), args...).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// scanner общий интерфейс sql.Row и sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// queryAll выполняет запрос и собирает строки через scan
+func queryAll[T any](ctx context.Context, db *DB, scan func(s scanner) (T, error), q string, args ...any) ([]T, error) {
+	rows, err := db.query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]T, 0)
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, rows.Err()
+}
+
+// queryOne возвращает storage.ErrNotFound, если строк нет
+func queryOne[T any](ctx context.Context, db *DB, scan func(s scanner) (T, error), q string, args ...any) (*T, error) {
+	v, err := scan(db.conn.QueryRowContext(ctx, db.rebind(q), args...))
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// unixNano время хранится в наносекундах UTC, нулевое время как 0
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
+
+// limitClause пустой при limit <= 0
+func limitClause(limit int64) string {
+	if limit <= 0 {
+		return ""
+	}
+	return " LIMIT " + strconv.FormatInt(limit, 10)
+}
@@ -0,0 +1,120 @@
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/storage"
+	"makarov.dev/bot/internal/storage/filesystem"
+	"makarov.dev/bot/internal/storage/storagetest"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func openSqlite(t *testing.T, dir string) *DB {
+	db, err := Open(context.Background(), DriverSqlite, filepath.Join(dir, "bot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err = db.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSqliteStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *storage.Storage {
+		dir := t.TempDir()
+		db := openSqlite(t, dir)
+		files, err := filesystem.New(filepath.Join(dir, "files"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return New(db, files)
+	})
+}
+
+func TestMigrateTwice(t *testing.T) {
+	db := openSqlite(t, t.TempDir())
+	applied, err := db.Migrate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second Migrate() applied %v, want nothing", applied)
+	}
+}
+
+func TestMigrateMergesDuplicates(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, DriverSqlite, filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// схема до уникальных ключей, когда дубликаты еще могли появиться
+	all := migrations
+	migrations = all[:3]
+	_, err = db.Migrate(ctx)
+	migrations = all
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+	sd, hd, torrent := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	files := func(f ...storage.LostFilmItemFile) string {
+		b, _ := json.Marshal(f)
+		return string(b)
+	}
+	for _, row := range []struct {
+		id    primitive.ObjectID
+		files string
+	}{
+		{first, files(storage.LostFilmItemFile{Quality: "SD", GridFsId: sd})},
+		// только более поздний дубликат ссылается на 1080
+		{second, files(storage.LostFilmItemFile{Quality: "SD", GridFsId: primitive.NewObjectID()}, storage.LostFilmItemFile{Quality: "1080", GridFsId: hd})},
+	} {
+		_, err = db.exec(ctx, "INSERT INTO lostfilm_items ("+lostFilmItemColumns+") VALUES (?, '/a', 'a', '', '', 0, 0, ?, '', 0)",
+			row.id.Hex(), row.files)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, row := range []struct {
+		id   primitive.ObjectID
+		file primitive.ObjectID
+	}{{first, primitive.NilObjectID}, {second, torrent}} {
+		_, err = db.exec(ctx, "INSERT INTO kinozal_items ("+kinozalItemColumns+") VALUES (?, 'a', 1, ?, 0)", row.id.Hex(), row.file.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err = db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s := New(db, nil)
+	item, err := s.LostFilmItems.GetByPage(ctx, "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []storage.LostFilmItemFile{{Quality: "SD", GridFsId: sd}, {Quality: "1080", GridFsId: hd}}
+	if item.Id != first || !slices.Equal(item.ItemFiles, want) {
+		t.Errorf("merged lostfilm item = %+v, want id %s files %+v", item, first.Hex(), want)
+	}
+	torrents, err := s.KinozalItems.Latest(ctx, 0)
+	if err != nil || len(torrents) != 1 || torrents[0].Id != first || torrents[0].GridFsId != torrent {
+		t.Errorf("merged kinozal items = %+v, %v", torrents, err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	db := &DB{postgres: true}
+	got := db.rebind("SELECT 1 FROM t WHERE a = ? AND b = ?")
+	if want := "SELECT 1 FROM t WHERE a = $1 AND b = $2"; got != want {
+		t.Errorf("rebind() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"makarov.dev/bot/internal/storage"
+	"time"
+)
+
+type twitchChannelRepository struct {
+	db *DB
+}
+
+func (r *twitchChannelRepository) List(ctx context.Context) ([]storage.TwitchChannel, error) {
+	return queryAll(ctx, r.db, func(s scanner) (storage.TwitchChannel, error) {
+		c := storage.TwitchChannel{}
+		var id string
+		var created int64
+		err := s.Scan(&id, &c.Name, &created)
+		if err != nil {
+			return c, err
+		}
+		c.Id, err = parseId(id)
+		c.Created = fromUnixNano(created)
+		return c, err
+	}, "SELECT id, name, created FROM twitch_channels ORDER BY name")
+}
+
+func (r *twitchChannelRepository) Exists(ctx context.Context, name string) (bool, error) {
+	return r.db.exists(ctx, "SELECT 1 FROM twitch_channels WHERE name = ? LIMIT 1", name)
+}
+
+func (r *twitchChannelRepository) Insert(ctx context.Context, channel *storage.TwitchChannel) error {
+	n, err := r.db.exec(ctx, "INSERT INTO twitch_channels (id, name, created) VALUES (?, ?, ?) ON CONFLICT (name) DO NOTHING",
+		channel.Id.Hex(), channel.Name, unixNano(channel.Created))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrDuplicate
+	}
+	return nil
+}
+
+func (r *twitchChannelRepository) Delete(ctx context.Context, name string) error {
+	n, err := r.db.exec(ctx, "DELETE FROM twitch_channels WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+type streamSessionRepository struct {
+	db *DB
+}
+
+const streamSessionColumns = "id, channel, stream_id, user_name, title, game_name, started_at, ended_at"
+
+func (r *streamSessionRepository) Insert(ctx context.Context, session *storage.StreamSession) error {
+	var endedAt sql.NullInt64
+	if session.EndedAt != nil {
+		endedAt = sql.NullInt64{Int64: unixNano(*session.EndedAt), Valid: true}
+	}
+	_, err := r.db.exec(ctx, "INSERT INTO twitch_stream_sessions ("+streamSessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		session.Id.Hex(), session.Channel, session.StreamId, session.UserName, session.Title, session.GameName,
+		unixNano(session.StartedAt), endedAt)
+	return err
+}
+
+func (r *streamSessionRepository) Open(ctx context.Context) ([]storage.StreamSession, error) {
+	return queryAll(ctx, r.db, scanStreamSession,
+		"SELECT "+streamSessionColumns+" FROM twitch_stream_sessions WHERE ended_at IS NULL")
+}
+
+func (r *streamSessionRepository) End(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.db.exec(ctx, "UPDATE twitch_stream_sessions SET ended_at = ? WHERE id = ?", unixNano(at), id.Hex())
+	return err
+}
+
+func (r *streamSessionRepository) List(ctx context.Context, channel string, limit int64) ([]storage.StreamSession, error) {
+	q := "SELECT " + streamSessionColumns + " FROM twitch_stream_sessions"
+	args := make([]any, 0, 1)
+	if channel != "" {
+		q += " WHERE channel = ?"
+		args = append(args, channel)
+	}
+	return queryAll(ctx, r.db, scanStreamSession, q+" ORDER BY started_at DESC"+limitClause(limit), args...)
+}
+
+func scanStreamSession(s scanner) (storage.StreamSession, error) {
+	session := storage.StreamSession{}
+	var id string
+	var startedAt int64
+	var endedAt sql.NullInt64
+	err := s.Scan(&id, &session.Channel, &session.StreamId, &session.UserName, &session.Title, &session.GameName, &startedAt, &endedAt)
+	if err != nil {
+		return session, err
+	}
+	if session.Id, err = parseId(id); err != nil {
+		return session, err
+	}
+	session.StartedAt = fromUnixNano(startedAt)
+	if endedAt.Valid {
+		t := fromUnixNano(endedAt.Int64)
+		session.EndedAt = &t
+	}
+	return session, nil
+}
+
+type alertRuleRepository struct {
+	db *DB
+}
+
+func (r *alertRuleRepository) Insert(ctx context.Context, rule *storage.AlertRule) error {
+	_, err := r.db.exec(ctx, "INSERT INTO twitch_alert_rules (id, channel, user_name, pattern, rate_limit, created) VALUES (?, ?, ?, ?, ?, ?)",
+		rule.Id.Hex(), rule.Channel, rule.User, rule.Pattern, int64(rule.RateLimit), unixNano(rule.Created))
+	return err
+}
+
+func (r *alertRuleRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	n, err := r.db.exec(ctx, "DELETE FROM twitch_alert_rules WHERE id = ?", id.Hex())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (r *alertRuleRepository) List(ctx context.Context) ([]storage.AlertRule, error) {
+	return queryAll(ctx, r.db, func(s scanner) (storage.AlertRule, error) {
+		rule := storage.AlertRule{}
+		var id string
+		var rateLimit, created int64
+		err := s.Scan(&id, &rule.Channel, &rule.User, &rule.Pattern, &rateLimit, &created)
+		if err != nil {
+			return rule, err
+		}
+		rule.Id, err = parseId(id)
+		rule.RateLimit = time.Duration(rateLimit)
+		rule.Created = fromUnixNano(created)
+		return rule, err
+	}, "SELECT id, channel, user_name, pattern, rate_limit, created FROM twitch_alert_rules ORDER BY id")
+}
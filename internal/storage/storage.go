@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"time"
+)
+
+// ErrNotFound запись не найдена
+var ErrNotFound = errors.New("not found")
+
+// ErrDuplicate запись с таким уникальным ключом уже есть
+var ErrDuplicate = errors.New("already exists")
+
+// Storage набор репозиториев приложения. Реализации: MongoDB (mongodb.New) и память (memory.New)
+type Storage struct {
+	LostFilmItems    LostFilmItemRepository
+	KinozalItems     KinozalItemRepository
+	KinozalFavorites KinozalFavoriteRepository
+	ChatMessages     ChatMessageRepository
+	TushqaQuotes     TushqaQuoteRepository
+	TwitchChannels   TwitchChannelRepository
+	StreamSessions   StreamSessionRepository
+	AlertRules       AlertRuleRepository
+	ChatRollups      ChatRollupRepository
+	ChatArchives     ChatArchiveRepository
+	Downloads        DownloadRepository
+	Credentials      TrackerCredentialRepository
+	Files            FileStore
+	Migrator         Migrator
+	Pinger           Pinger
+}
+
+// Pinger проверяет соединение с базой для /readyz
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Migrator применяет версионные миграции схемы: индексы, новые поля и заполнение данных
+type Migrator interface {
+	// Migrate применяет еще не примененные миграции и возвращает их названия
+	Migrate(ctx context.Context) ([]string, error)
+}
+
+type LostFilmItemRepository interface {
+	// GetByPage возвращает ErrNotFound, если серии нет
+	GetByPage(ctx context.Context, page string) (*LostFilmItem, error)
+	// Upsert сохраняет серию по странице: обновляет существующую или вставляет новую с item.Id
+	Upsert(ctx context.Context, item *LostFilmItem) error
+	// Latest последние серии по дате выхода и дате добавления
+	Latest(ctx context.Context, limit int64) ([]LostFilmItem, error)
+}
+
+type KinozalItemRepository interface {
+	Exists(ctx context.Context, detailId int64, name string) (bool, error)
+	// Upsert вставляет серию, если серии с такими detail_id и name еще нет. Возвращает true, если серия вставлена
+	Upsert(ctx context.Context, item *KinozalItem) (bool, error)
+	Latest(ctx context.Context, limit int64) ([]KinozalItem, error)
+}
+
+type KinozalFavoriteRepository interface {
+	Exists(ctx context.Context, detailId int64) (bool, error)
+	// Upsert добавляет избранное, повторное добавление ничего не меняет
+	Upsert(ctx context.Context, favorite *KinozalFavorite) error
+	Delete(ctx context.Context, detailId int64) error
+	// List избранное по detail_id
+	List(ctx context.Context) ([]KinozalFavorite, error)
+}
+
+// ChatMessageFilter отбор сообщений чата. Пустые поля не ограничивают выборку, To не включается
+type ChatMessageFilter struct {
+	Channel string
+	From    time.Time
+	To      time.Time
+}
+
+type ChatMessageRepository interface {
+	Insert(ctx context.Context, m *ChatMessage) error
+	// Last последние сохраненные сообщения, новые первыми
+	Last(ctx context.Context, channel string, limit int64) ([]ChatMessage, error)
+	// Each вызывает fn для каждого сообщения по возрастанию original_time
+	Each(ctx context.Context, filter ChatMessageFilter, fn func(m *ChatMessage) error) error
+	// First самое раннее сообщение канала, ErrNotFound если сообщений нет
+	First(ctx context.Context, channel string) (*ChatMessage, error)
+	Channels(ctx context.Context) ([]string, error)
+	// CountByUserHour количество сообщений по пользователям и часам (UTC)
+	CountByUserHour(ctx context.Context, filter ChatMessageFilter) ([]ChatRollupUser, error)
+	Count(ctx context.Context, filter ChatMessageFilter) (int64, error)
+	Delete(ctx context.Context, filter ChatMessageFilter) (int64, error)
+}
+
+type TushqaQuoteRepository interface {
+	// Exists ищет цитату без учета регистра
+	Exists(ctx context.Context, message string) (bool, error)
+	Insert(ctx context.Context, quote *TushqaQuote) error
+	Last(ctx context.Context, limit int64) ([]TushqaQuote, error)
+}
+
+type TwitchChannelRepository interface {
+	// List каналы по имени
+	List(ctx context.Context) ([]TwitchChannel, error)
+	Exists(ctx context.Context, name string) (bool, error)
+	// Insert возвращает ErrDuplicate, если канал с таким именем уже есть
+	Insert(ctx context.Context, channel *TwitchChannel) error
+	// Delete возвращает ErrNotFound, если канала нет
+	Delete(ctx context.Context, name string) error
+}
+
+type StreamSessionRepository interface {
+	Insert(ctx context.Context, session *StreamSession) error
+	// Open трансляции без времени окончания
+	Open(ctx context.Context) ([]StreamSession, error)
+	End(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// List последние трансляции, новые первыми
+	List(ctx context.Context, channel string, limit int64) ([]StreamSession, error)
+}
+
+type AlertRuleRepository interface {
+	Insert(ctx context.Context, rule *AlertRule) error
+	// Delete возвращает ErrNotFound, если правила нет
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	List(ctx context.Context) ([]AlertRule, error)
+}
+
+type ChatRollupRepository interface {
+	// Last последний посчитанный день канала, ErrNotFound если статистики нет
+	Last(ctx context.Context, channel string) (*ChatRollup, error)
+	// Upsert заменяет статистику канала за день
+	Upsert(ctx context.Context, rollup *ChatRollup) error
+	List(ctx context.Context, channel string, from time.Time, to time.Time) ([]ChatRollup, error)
+}
+
+type ChatArchiveRepository interface {
+	Insert(ctx context.Context, archive *ChatArchive) error
+	// List архивы по времени создания
+	List(ctx context.Context) ([]ChatArchive, error)
+}
+
+type DownloadRepository interface {
+	Insert(ctx context.Context, entry *DownloadEntry) error
+	// List скачивания по времени
+	List(ctx context.Context) ([]DownloadEntry, error)
+}
+
+type TrackerCredentialRepository interface {
+	// Get возвращает ErrNotFound, если сессия трекера не сохранена
+	Get(ctx context.Context, tracker string) (*TrackerCredential, error)
+	// Upsert заменяет сессию трекера, Id сохраняется от первой вставки
+	Upsert(ctx context.Context, credential *TrackerCredential) error
+	List(ctx context.Context) ([]TrackerCredential, error)
+}
+
+// File открытый на чтение файл
+type File interface {
+	io.ReadCloser
+	Name() string
+	Length() int64
+}
+
+// FileUpload файл, записываемый потоком. Сохраняется после Close
+type FileUpload interface {
+	io.Writer
+	Close() error
+	Abort() error
+	Id() primitive.ObjectID
+}
+
+// FileInfo описание сохраненного файла
+type FileInfo struct {
+	Id     primitive.ObjectID
+	Name   string
+	Length int64
+}
+
+// FileStore хранилище торрент-файлов и архивов
+type FileStore interface {
+	Upload(name string, content []byte) (primitive.ObjectID, error)
+	OpenUpload(name string) (FileUpload, error)
+	// OpenUploadWithId загрузка с заданным идентификатором, чтобы восстановленные из резервной копии ссылки продолжили работать
+	OpenUploadWithId(id primitive.ObjectID, name string) (FileUpload, error)
+	// Open возвращает ErrNotFound, если файла нет
+	Open(id primitive.ObjectID) (File, error)
+	// List все файлы по идентификатору
+	List() ([]FileInfo, error)
+	// Ping проверяет, что хранилище файлов доступно
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,447 @@
+// Package storagetest общие проверки реализаций storage.Storage
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"makarov.dev/bot/internal/storage"
+	"testing"
+	"time"
+)
+
+// Run проверяет все репозитории. newStorage должен возвращать пустое хранилище на каждый вызов
+func Run(t *testing.T, newStorage func(t *testing.T) *storage.Storage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *storage.Storage)
+	}{
+		{"LostFilmItems", testLostFilmItems},
+		{"Kinozal", testKinozal},
+		{"ChatMessages", testChatMessages},
+		{"TushqaQuotes", testTushqaQuotes},
+		{"TwitchChannels", testTwitchChannels},
+		{"StreamSessions", testStreamSessions},
+		{"AlertRules", testAlertRules},
+		{"ChatRollups", testChatRollups},
+		{"ArchivesAndDownloads", testArchivesAndDownloads},
+		{"Credentials", testCredentials},
+		{"Files", testFiles},
+		{"Ping", testPing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+var day = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func testLostFilmItems(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	repo := s.LostFilmItems
+	if _, err := repo.GetByPage(ctx, "/series/a"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetByPage() error = %v, want ErrNotFound", err)
+	}
+	older := &storage.LostFilmItem{Id: primitive.NewObjectID(), Page: "/series/a", Name: "a", Date: day, Created: day}
+	newer := &storage.LostFilmItem{Id: primitive.NewObjectID(), Page: "/series/b", Name: "b", Date: day.Add(time.Hour), Created: day}
+	for _, item := range []*storage.LostFilmItem{older, newer} {
+		if err := repo.Upsert(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// повторное сохранение той же страницы с другим id обновляет серию, а не создает дубликат
+	update := *older
+	update.Id = primitive.NewObjectID()
+	update.RetryCount = 2
+	update.Created = day.Add(24 * time.Hour)
+	update.ItemFiles = []storage.LostFilmItemFile{{Quality: "SD", GridFsId: primitive.NewObjectID()}}
+	if err := repo.Upsert(ctx, &update); err != nil {
+		t.Fatal(err)
+	}
+	older.RetryCount = update.RetryCount
+	older.ItemFiles = update.ItemFiles
+	got, err := repo.GetByPage(ctx, "/series/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != older.Id || got.RetryCount != 2 || len(got.ItemFiles) != 1 || got.ItemFiles[0] != older.ItemFiles[0] || !got.Date.Equal(day) || !got.Created.Equal(day) {
+		t.Errorf("GetByPage() = %+v, want %+v", got, older)
+	}
+	latest, err := repo.Latest(ctx, 1)
+	if err != nil || len(latest) != 1 || latest[0].Id != newer.Id {
+		t.Errorf("Latest() = %v, %v", latest, err)
+	}
+	if all, err := repo.Latest(ctx, 0); err != nil || len(all) != 2 {
+		t.Errorf("Latest() after upsert = %d items, %v, want 2", len(all), err)
+	}
+}
+
+func testKinozal(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	item := &storage.KinozalItem{Id: primitive.NewObjectID(), Name: "a", DetailId: 1, GridFsId: primitive.NewObjectID(), Created: day}
+	if inserted, err := s.KinozalItems.Upsert(ctx, item); err != nil || !inserted {
+		t.Fatalf("Upsert() = %v, %v, want true", inserted, err)
+	}
+	duplicate := *item
+	duplicate.Id = primitive.NewObjectID()
+	if inserted, err := s.KinozalItems.Upsert(ctx, &duplicate); err != nil || inserted {
+		t.Errorf("duplicate Upsert() = %v, %v, want false", inserted, err)
+	}
+	if exists, err := s.KinozalItems.Exists(ctx, 1, "a"); err != nil || !exists {
+		t.Errorf("Exists() = %v, %v, want true", exists, err)
+	}
+	if exists, err := s.KinozalItems.Exists(ctx, 1, "b"); err != nil || exists {
+		t.Errorf("Exists() = %v, %v, want false", exists, err)
+	}
+	latest, err := s.KinozalItems.Latest(ctx, 50)
+	if err != nil || len(latest) != 1 || latest[0].GridFsId != item.GridFsId {
+		t.Errorf("Latest() = %v, %v", latest, err)
+	}
+
+	for range 2 {
+		if err = s.KinozalFavorites.Upsert(ctx, &storage.KinozalFavorite{Id: primitive.NewObjectID(), DetailId: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exists, err := s.KinozalFavorites.Exists(ctx, 1); err != nil || !exists {
+		t.Errorf("favorite Exists() = %v, %v, want true", exists, err)
+	}
+	if favorites, err := s.KinozalFavorites.List(ctx); err != nil || len(favorites) != 1 || favorites[0].DetailId != 1 {
+		t.Errorf("favorite List() = %v, %v", favorites, err)
+	}
+	if err = s.KinozalFavorites.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := s.KinozalFavorites.Exists(ctx, 1); err != nil || exists {
+		t.Errorf("favorite Exists() after Delete = %v, %v, want false", exists, err)
+	}
+}
+
+func testChatMessages(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	repo := s.ChatMessages
+	sessionId := primitive.NewObjectID()
+	messages := []storage.ChatMessage{
+		{Channel: "a", User: storage.ChatUser{Id: "1", Name: "one"}, OriginalTime: day.Add(2 * time.Hour)},
+		{Channel: "a", User: storage.ChatUser{Id: "1", Name: "one"}, OriginalTime: day.Add(time.Hour), StreamSessionId: &sessionId},
+		{Channel: "a", User: storage.ChatUser{Id: "2", Name: "two"}, OriginalTime: day.Add(time.Hour + time.Minute)},
+		{Channel: "a", User: storage.ChatUser{Id: "1", Name: "one"}, OriginalTime: day.Add(25 * time.Hour)},
+		{Channel: "b", User: storage.ChatUser{Id: "3", Name: "three"}, OriginalTime: day},
+	}
+	for _, m := range messages {
+		m.Id = primitive.NewObjectID()
+		if err := repo.Insert(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := storage.ChatMessageFilter{Channel: "a", From: day, To: day.Add(24 * time.Hour)}
+	got := make([]storage.ChatMessage, 0)
+	err := repo.Each(ctx, filter, func(m *storage.ChatMessage) error {
+		got = append(got, *m)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || !got[0].OriginalTime.Equal(day.Add(time.Hour)) || !got[2].OriginalTime.Equal(day.Add(2*time.Hour)) {
+		t.Errorf("Each() = %v", got)
+	}
+	if got[0].StreamSessionId == nil || *got[0].StreamSessionId != sessionId || got[1].StreamSessionId != nil {
+		t.Errorf("Each() stream session ids %v %v", got[0].StreamSessionId, got[1].StreamSessionId)
+	}
+
+	users, err := repo.CountByUserHour(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []storage.ChatRollupUser{
+		{UserId: "1", UserName: "one", Hour: 1, Count: 1},
+		{UserId: "2", UserName: "two", Hour: 1, Count: 1},
+		{UserId: "1", UserName: "one", Hour: 2, Count: 1},
+	}
+	if len(users) != len(want) {
+		t.Fatalf("CountByUserHour() = %v, want %v", users, want)
+	}
+	for i := range want {
+		if users[i] != want[i] {
+			t.Errorf("CountByUserHour()[%d] = %v, want %v", i, users[i], want[i])
+		}
+	}
+
+	first, err := repo.First(ctx, "a")
+	if err != nil || !first.OriginalTime.Equal(day.Add(time.Hour)) {
+		t.Errorf("First() = %v, %v", first, err)
+	}
+	if _, err = repo.First(ctx, "c"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("First() error = %v, want ErrNotFound", err)
+	}
+	channels, err := repo.Channels(ctx)
+	if err != nil || len(channels) != 2 {
+		t.Errorf("Channels() = %v, %v", channels, err)
+	}
+
+	last, err := repo.Last(ctx, "", 2)
+	if err != nil || len(last) != 2 || last[0].Channel != "b" {
+		t.Errorf("Last() = %v, %v", last, err)
+	}
+
+	deleted, err := repo.Delete(ctx, storage.ChatMessageFilter{Channel: "a", To: day.Add(24 * time.Hour)})
+	if err != nil || deleted != 3 {
+		t.Errorf("Delete() = %d, %v, want 3", deleted, err)
+	}
+	count, err := repo.Count(ctx, storage.ChatMessageFilter{})
+	if err != nil || count != 2 {
+		t.Errorf("Count() = %d, %v, want 2", count, err)
+	}
+}
+
+func testTushqaQuotes(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	for _, message := range []string{"Привет, чат", "second"} {
+		err := s.TushqaQuotes.Insert(ctx, &storage.TushqaQuote{Id: primitive.NewObjectID(), Channel: "a", Message: message, Created: day})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exists, err := s.TushqaQuotes.Exists(ctx, "привет, ЧАТ"); err != nil || !exists {
+		t.Errorf("Exists() = %v, %v, want true", exists, err)
+	}
+	if exists, err := s.TushqaQuotes.Exists(ctx, "привет"); err != nil || exists {
+		t.Errorf("Exists() = %v, %v, want false", exists, err)
+	}
+	last, err := s.TushqaQuotes.Last(ctx, 1)
+	if err != nil || len(last) != 1 || last[0].Message != "second" {
+		t.Errorf("Last() = %v, %v", last, err)
+	}
+}
+
+func testTwitchChannels(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	repo := s.TwitchChannels
+	for _, name := range []string{"b", "a"} {
+		if err := repo.Insert(ctx, &storage.TwitchChannel{Id: primitive.NewObjectID(), Name: name, Created: day}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Insert(ctx, &storage.TwitchChannel{Id: primitive.NewObjectID(), Name: "a", Created: day}); !errors.Is(err, storage.ErrDuplicate) {
+		t.Errorf("Insert() of existing channel error = %v, want ErrDuplicate", err)
+	}
+	channels, err := repo.List(ctx)
+	if err != nil || len(channels) != 2 || channels[0].Name != "a" {
+		t.Errorf("List() = %v, %v", channels, err)
+	}
+	if exists, err := repo.Exists(ctx, "a"); err != nil || !exists {
+		t.Errorf("Exists() = %v, %v, want true", exists, err)
+	}
+	if err = repo.Delete(ctx, "a"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if err = repo.Delete(ctx, "a"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func testStreamSessions(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	repo := s.StreamSessions
+	first := &storage.StreamSession{Id: primitive.NewObjectID(), Channel: "a", StreamId: "1", StartedAt: day}
+	second := &storage.StreamSession{Id: primitive.NewObjectID(), Channel: "a", StreamId: "2", StartedAt: day.Add(time.Hour)}
+	for _, session := range []*storage.StreamSession{first, second} {
+		if err := repo.Insert(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.End(ctx, first.Id, day.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	open, err := repo.Open(ctx)
+	if err != nil || len(open) != 1 || open[0].Id != second.Id {
+		t.Errorf("Open() = %v, %v", open, err)
+	}
+	sessions, err := repo.List(ctx, "a", 10)
+	if err != nil || len(sessions) != 2 || sessions[0].Id != second.Id {
+		t.Fatalf("List() = %v, %v", sessions, err)
+	}
+	if sessions[1].EndedAt == nil || !sessions[1].EndedAt.Equal(day.Add(30*time.Minute)) {
+		t.Errorf("List() ended at = %v", sessions[1].EndedAt)
+	}
+}
+
+func testAlertRules(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	repo := s.AlertRules
+	rule := &storage.AlertRule{Id: primitive.NewObjectID(), Channel: "a", User: "u", Pattern: "p", RateLimit: time.Minute, Created: day}
+	if err := repo.Insert(ctx, rule); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := repo.List(ctx)
+	if err != nil || len(rules) != 1 || rules[0].RateLimit != time.Minute || rules[0].User != "u" {
+		t.Errorf("List() = %v, %v", rules, err)
+	}
+	if err = repo.Delete(ctx, rule.Id); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if err = repo.Delete(ctx, rule.Id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func testChatRollups(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	repo := s.ChatRollups
+	if _, err := repo.Last(ctx, "a"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Last() error = %v, want ErrNotFound", err)
+	}
+	for _, total := range []int64{1, 2} {
+		err := repo.Upsert(ctx, &storage.ChatRollup{
+			Id:      primitive.NewObjectID(),
+			Channel: "a",
+			Day:     day,
+			Total:   total,
+			Users:   []storage.ChatRollupUser{{UserId: "1", Count: total}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rollups, err := repo.List(ctx, "a", day, day.Add(24*time.Hour))
+	if err != nil || len(rollups) != 1 || rollups[0].Total != 2 || len(rollups[0].Users) != 1 {
+		t.Errorf("List() = %v, %v", rollups, err)
+	}
+	last, err := repo.Last(ctx, "a")
+	if err != nil || !last.Day.Equal(day) {
+		t.Errorf("Last() = %v, %v", last, err)
+	}
+	if rollups, err = repo.List(ctx, "", time.Time{}, time.Time{}); err != nil || len(rollups) != 1 {
+		t.Errorf("List() without range = %v, %v", rollups, err)
+	}
+}
+
+func testArchivesAndDownloads(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	archive := &storage.ChatArchive{Id: primitive.NewObjectID(), Channel: "a", To: day, GridFsId: primitive.NewObjectID(), Created: day}
+	if err := s.ChatArchives.Insert(ctx, archive); err != nil {
+		t.Fatal(err)
+	}
+	archives, err := s.ChatArchives.List(ctx)
+	if err != nil || len(archives) != 1 || archives[0].GridFsId != archive.GridFsId || !archives[0].To.Equal(day) {
+		t.Errorf("ChatArchives.List() = %v, %v", archives, err)
+	}
+	entry := &storage.DownloadEntry{Id: primitive.NewObjectID(), FileId: primitive.NewObjectID(), RemoteAddr: "127.0.0.1", Created: day}
+	if err = s.Downloads.Insert(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := s.Downloads.List(ctx)
+	if err != nil || len(entries) != 1 || entries[0].FileId != entry.FileId || entries[0].RemoteAddr != "127.0.0.1" {
+		t.Errorf("Downloads.List() = %v, %v", entries, err)
+	}
+}
+
+func testCredentials(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	if _, err := s.Credentials.Get(ctx, "lostfilm"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+	first := &storage.TrackerCredential{Id: primitive.NewObjectID(), Tracker: "lostfilm", Name: "lf", Value: "old", Updated: day}
+	if err := s.Credentials.Upsert(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	next := &storage.TrackerCredential{Id: primitive.NewObjectID(), Tracker: "lostfilm", Name: "lf", Value: "new", Updated: day.Add(time.Hour)}
+	if err := s.Credentials.Upsert(ctx, next); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Credentials.Get(ctx, "lostfilm")
+	if err != nil || got.Id != first.Id || got.Value != "new" || !got.Updated.Equal(next.Updated) {
+		t.Errorf("Get() = %+v, %v", got, err)
+	}
+	if err = s.Credentials.Upsert(ctx, &storage.TrackerCredential{Id: primitive.NewObjectID(), Tracker: "kinozal", Value: "k", Updated: day}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.Credentials.List(ctx)
+	if err != nil || len(list) != 2 || list[0].Tracker != "kinozal" {
+		t.Errorf("List() = %+v, %v", list, err)
+	}
+}
+
+func testFiles(t *testing.T, s *storage.Storage) {
+	store := s.Files
+	if _, err := store.Open(primitive.NewObjectID()); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Open() error = %v, want ErrNotFound", err)
+	}
+	upload, err := store.OpenUpload("chat.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.WriteString(upload, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Open(upload.Id()); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Open() before Close error = %v, want ErrNotFound", err)
+	}
+	if err = upload.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, store, upload.Id(), "chat.jsonl", "hello")
+
+	id, err := store.Upload("a.torrent", []byte("torrent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, store, id, "a.torrent", "torrent")
+
+	restoredId := primitive.NewObjectID()
+	restored, err := store.OpenUploadWithId(restoredId, "b.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.WriteString(restored, "restored"); err != nil {
+		t.Fatal(err)
+	}
+	if err = restored.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Id() != restoredId {
+		t.Errorf("OpenUploadWithId() Id = %s, want %s", restored.Id().Hex(), restoredId.Hex())
+	}
+	checkFile(t, store, restoredId, "b.torrent", "restored")
+
+	files, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("List() = %+v, want 3 files", files)
+	}
+	for _, f := range files {
+		if f.Id == restoredId && (f.Name != "b.torrent" || f.Length != int64(len("restored"))) {
+			t.Errorf("List() restored file = %+v", f)
+		}
+	}
+}
+
+func checkFile(t *testing.T, store storage.FileStore, id primitive.ObjectID, name string, content string) {
+	t.Helper()
+	f, err := store.Open(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, _ := io.ReadAll(f)
+	if string(b) != content || f.Name() != name || f.Length() != int64(len(content)) {
+		t.Errorf("Open() = %s %s %d, want %s %s", f.Name(), b, f.Length(), name, content)
+	}
+}
+
+func testPing(t *testing.T, s *storage.Storage) {
+	ctx := context.Background()
+	if err := s.Pinger.Ping(ctx); err != nil {
+		t.Errorf("Pinger.Ping() error = %v", err)
+	}
+	if err := s.Files.Ping(ctx); err != nil {
+		t.Errorf("Files.Ping() error = %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "makarov.dev/bot"
+
+// Start открывает дочерний span. Провайдер задается config.InitTracing, по умолчанию это no-op
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Error отмечает span как неудачный и возвращает err без изменений
+func Error(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// Step выполняет fn в дочернем span с именем name и отмечает в нем ошибку
+func Step[T any](ctx context.Context, name string, fn func(ctx context.Context) (T, error), attrs ...attribute.KeyValue) (T, error) {
+	ctx, span := Start(ctx, name, attrs...)
+	defer span.End()
+	v, err := fn(ctx)
+	return v, Error(span, err)
+}
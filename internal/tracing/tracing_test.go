@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStep(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	ctx, root := Start(context.Background(), "lostfilm.StoreElement")
+	_, _ = Step(ctx, "lostfilm.GetEpisode", func(context.Context) (int, error) { return 1, nil })
+	_, err := Step(ctx, "lostfilm.GetTorrent", func(context.Context) ([]byte, error) { return nil, errors.New("wrong content type") })
+	_ = Error(root, err)
+	root.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("ended spans = %d, want 3", len(spans))
+	}
+	for _, span := range spans[:2] {
+		if span.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("span %s is not a child of the root span", span.Name())
+		}
+	}
+	if spans[0].Status().Code == codes.Error || spans[1].Status().Code != codes.Error || spans[2].Status().Code != codes.Error {
+		t.Errorf("statuses = %v, %v, %v", spans[0].Status(), spans[1].Status(), spans[2].Status())
+	}
+}
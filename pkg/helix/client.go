@@ -0,0 +1,187 @@
+package helix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAuthUrl = "https://id.twitch.tv/oauth2/token"
+	DefaultApiUrl  = "https://api.twitch.tv/helix"
+
+	// maxLogins максимальное количество каналов в одном запросе /streams
+	maxLogins = 100
+)
+
+type ClientConfig struct {
+	HttpClient   HttpClient
+	AuthUrl      string
+	ApiUrl       string
+	ClientId     string
+	ClientSecret string
+}
+
+// Client клиент Twitch Helix API с app access token
+type Client struct {
+	Config ClientConfig
+	Logger *logrus.Logger
+
+	mutex       sync.Mutex
+	token       string
+	tokenExpire time.Time
+}
+
+type HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Stream struct {
+	Id           string    `json:"id"`
+	UserId       string    `json:"user_id"`
+	UserLogin    string    `json:"user_login"`
+	UserName     string    `json:"user_name"`
+	GameId       string    `json:"game_id"`
+	GameName     string    `json:"game_name"`
+	Type         string    `json:"type"`
+	Title        string    `json:"title"`
+	ViewerCount  int       `json:"viewer_count"`
+	StartedAt    time.Time `json:"started_at"`
+	ThumbnailUrl string    `json:"thumbnail_url"`
+}
+
+// Thumbnail возвращает ссылку на превью трансляции заданного размера
+func (s Stream) Thumbnail(width int, height int) string {
+	r := strings.NewReplacer("{width}", fmt.Sprint(width), "{height}", fmt.Sprint(height))
+	return r.Replace(s.ThumbnailUrl)
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type streamsResponse struct {
+	Data []Stream `json:"data"`
+}
+
+var ErrUnauthorized = errors.New("helix unauthorized")
+
+// GetStreams возвращает идущие трансляции указанных каналов, запросы прерываются отменой ctx
+func (c *Client) GetStreams(ctx context.Context, logins []string) ([]Stream, error) {
+	r := make([]Stream, 0, len(logins))
+	for start := 0; start < len(logins); start += maxLogins {
+		end := min(start+maxLogins, len(logins))
+		query := url.Values{}
+		query.Set("first", fmt.Sprint(maxLogins))
+		for _, login := range logins[start:end] {
+			query.Add("user_login", login)
+		}
+		res := streamsResponse{}
+		err := c.get(ctx, "/streams?"+query.Encode(), &res)
+		if errors.Is(err, ErrUnauthorized) {
+			c.resetToken()
+			err = c.get(ctx, "/streams?"+query.Encode(), &res)
+		}
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, res.Data...)
+	}
+	return r, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, v any) error {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiUrl()+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Client-Id", c.Config.ClientId)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := c.Config.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return ErrUnauthorized
+	}
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("helix GET %s status code %d %s", path, res.StatusCode, string(body))
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (c *Client) getToken(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.token != "" && time.Now().Before(c.tokenExpire) {
+		return c.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("client_id", c.Config.ClientId)
+	form.Set("client_secret", c.Config.ClientSecret)
+	form.Set("grant_type", "client_credentials")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.authUrl(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := c.Config.HttpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("helix token status code %d", res.StatusCode)
+	}
+	token := tokenResponse{}
+	err = json.NewDecoder(res.Body).Decode(&token)
+	if err != nil {
+		return "", err
+	}
+	c.token = token.AccessToken
+	// обновляем токен заранее, чтобы не получить 401 на границе срока
+	c.tokenExpire = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	if c.Logger != nil {
+		c.Logger.Debugf("Helix app access token refreshed")
+	}
+	return c.token, nil
+}
+
+func (c *Client) resetToken() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.token = ""
+}
+
+func (c *Client) authUrl() string {
+	if c.Config.AuthUrl != "" {
+		return c.Config.AuthUrl
+	}
+	return DefaultAuthUrl
+}
+
+func (c *Client) apiUrl() string {
+	if c.Config.ApiUrl != "" {
+		return c.Config.ApiUrl
+	}
+	return DefaultApiUrl
+}
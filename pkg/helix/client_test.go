@@ -0,0 +1,89 @@
+package helix
+
+import (
+	"bufio"
+	"context"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+type HttpClientMock struct {
+	tokenRequests int
+	unauthorized  bool
+}
+
+func (c *HttpClientMock) Do(req *http.Request) (*http.Response, error) {
+	switch req.URL.Path {
+	case "/oauth2/token":
+		c.tokenRequests++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"access_token":"token","expires_in":5000000,"token_type":"bearer"}`)),
+		}, nil
+	case "/helix/streams":
+		if c.unauthorized {
+			c.unauthorized = false
+			return &http.Response{StatusCode: 401, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		if req.Header.Get("Authorization") != "Bearer token" || req.Header.Get("Client-Id") != "client" {
+			return &http.Response{StatusCode: 401, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		file, _ := os.Open("./streams.json")
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bufio.NewReader(file))}, nil
+	}
+	return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func TestGetStreams(t *testing.T) {
+	mock := &HttpClientMock{}
+	client := getClient(mock)
+	streams, err := client.GetStreams(context.Background(), []string{"tushqa", "offline"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 1 {
+		t.Fatalf("Incorrect len %d", len(streams))
+	}
+	if streams[0].UserLogin != "tushqa" || streams[0].GameName != "Just Chatting" {
+		t.Fatalf("Incorrect stream %v", streams[0])
+	}
+	if streams[0].Thumbnail(1280, 720) != "https://static-cdn.jtvnw.net/previews-ttv/live_user_tushqa-1280x720.jpg" {
+		t.Fatalf("Incorrect thumbnail %s", streams[0].Thumbnail(1280, 720))
+	}
+
+	_, err = client.GetStreams(context.Background(), []string{"tushqa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mock.tokenRequests != 1 {
+		t.Fatalf("Token requested %d times, want 1", mock.tokenRequests)
+	}
+}
+
+func TestGetStreamsUnauthorized(t *testing.T) {
+	mock := &HttpClientMock{unauthorized: true}
+	client := getClient(mock)
+	streams, err := client.GetStreams(context.Background(), []string{"tushqa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 1 {
+		t.Fatalf("Incorrect len %d", len(streams))
+	}
+	if mock.tokenRequests != 2 {
+		t.Fatalf("Token requested %d times, want 2", mock.tokenRequests)
+	}
+}
+
+func getClient(mock *HttpClientMock) *Client {
+	cfg := ClientConfig{
+		HttpClient:   mock,
+		ClientId:     "client",
+		ClientSecret: "secret",
+	}
+	return &Client{Config: cfg, Logger: logrus.New()}
+}
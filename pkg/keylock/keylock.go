@@ -0,0 +1,31 @@
+// Package keylock блокировки по ключу внутри процесса
+package keylock
+
+import "sync"
+
+// Locks набор занятых ключей. Нулевое значение готово к использованию
+type Locks struct {
+	mutex sync.Mutex
+	keys  map[string]struct{}
+}
+
+// TryLock занимает ключ и возвращает false, если он уже занят.
+// Повторный опрос того же ключа пропускается, пока первый не закончит работу
+func (l *Locks) TryLock(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, busy := l.keys[key]; busy {
+		return false
+	}
+	if l.keys == nil {
+		l.keys = make(map[string]struct{})
+	}
+	l.keys[key] = struct{}{}
+	return true
+}
+
+func (l *Locks) Unlock(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.keys, key)
+}
@@ -0,0 +1,20 @@
+package keylock
+
+import "testing"
+
+func TestLocks(t *testing.T) {
+	l := Locks{}
+	if !l.TryLock("a") {
+		t.Fatal("TryLock(a) = false, want true")
+	}
+	if l.TryLock("a") {
+		t.Error("second TryLock(a) = true, want false")
+	}
+	if !l.TryLock("b") {
+		t.Error("TryLock(b) = false, want true")
+	}
+	l.Unlock("a")
+	if !l.TryLock("a") {
+		t.Error("TryLock(a) after Unlock = false, want true")
+	}
+}
@@ -1,46 +1,79 @@
 package kinozal
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/text/encoding/charmap"
+	"makarov.dev/bot/pkg/polite"
 )
 
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/108.0.0.0 Safari/537.36"
+
 type Client struct {
-	Config    ClientConfig
-	dlPageUrl string
-	Logger    *logrus.Logger
+	Config ClientConfig
+	Logger *logrus.Logger
+
+	// cookieMutex защищает Config.Cookie, который меняется при перезагрузке конфигурации и после входа
+	cookieMutex sync.RWMutex
+	// loginMutex не дает параллельным запросам входить одновременно
+	loginMutex sync.Mutex
 }
 
 type ClientConfig struct {
 	HttpClient  HttpClient
 	MainPageUrl string
 	Cookie      string
+	// Username и Password включают повторный вход, когда сессия истекла
+	Username string
+	Password string
+	// OnLogin получает cookie после успешного входа, чтобы сессию можно было сохранить
+	OnLogin func(cookie string)
+	// OnScrape получает замер после каждой разобранной страницы
+	OnScrape func(s Scrape)
 }
 
 type HttpClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// StatusError трекер ответил кодом ошибки
+type StatusError struct {
+	Code int
+	Url  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("wrong status code %d for %s", e.Code, e.Url)
+}
+
+// StatusCode код ответа, по нему планировщик опросов узнает ограничение частоты запросов
+func (e *StatusError) StatusCode() int {
+	return e.Code
+}
+
 type Element struct {
 	Name    string
 	Torrent []byte
 }
 
-func (c Client) GetRoot() ([]int64, error) {
+func (c *Client) GetRoot(ctx context.Context) ([]int64, error) {
 	ids := make([]int64, 0, 50)
 
-	doc, err := c.getDoc(c.Config.MainPageUrl + "/browse.php")
+	s := &Scrape{Page: "root"}
+	defer c.report(s)
+	// главная страница редко меняется между опросами, сервер может ответить 304
+	doc, err := c.getDoc(polite.Conditional(ctx), c.Config.MainPageUrl+"/browse.php", s)
 	if err != nil {
 		return nil, err
 	}
@@ -66,110 +99,166 @@ func (c Client) GetRoot() ([]int64, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.Items = len(ids)
+	if len(ids) == 0 {
+		s.missing("tr a[href*=id]")
+	}
 
 	return ids, nil
 }
 
-func (c Client) GetName(id int64) (string, error) {
+func (c *Client) GetName(ctx context.Context, id int64) (string, error) {
 	idStr := strconv.FormatInt(id, 10)
-	doc, err := c.getDoc(c.Config.MainPageUrl + "/details.php?id=" + idStr)
+	s := &Scrape{Page: "details"}
+	defer c.report(s)
+	doc, err := c.getDoc(ctx, c.Config.MainPageUrl+"/details.php?id="+idStr, s)
 	if err != nil {
 		return "", err
 	}
 	name := doc.Find(".content a").Eq(0).Text()
+	if name == "" {
+		s.missing(".content a")
+	}
 	decoder := charmap.Windows1251.NewDecoder()
 	name, err = decoder.String(name)
 	if err != nil {
+		s.Err = err
 		return "", err
 	}
+	if name != "" {
+		s.Items = 1
+	}
 	return name, nil
 }
 
-func (c Client) GetElement(id int64) (*Element, error) {
-	if c.dlPageUrl == "" {
-		parse, err := url.Parse(c.Config.MainPageUrl)
-		if err != nil {
-			return nil, err
-		}
-		c.dlPageUrl = fmt.Sprintf("%s://dl.%s", parse.Scheme, parse.Host)
-	}
-	idStr := strconv.FormatInt(id, 10)
-	name, err := c.GetName(id)
+func (c *Client) GetElement(ctx context.Context, id int64) (*Element, error) {
+	parse, err := url.Parse(c.Config.MainPageUrl)
 	if err != nil {
 		return nil, err
 	}
-	r, err := c.getRequest(fmt.Sprintf("%s/download.php?id=%s", c.dlPageUrl, idStr))
+	dlPageUrl := fmt.Sprintf("%s://dl.%s", parse.Scheme, parse.Host)
+	idStr := strconv.FormatInt(id, 10)
+	name, err := c.GetName(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	bytes, err := ioutil.ReadAll(r)
+	bytes, err := withSession(ctx, c, func() ([]byte, error) {
+		res, err := c.do(ctx, fmt.Sprintf("%s/download.php?id=%s", dlPageUrl, idStr), nil)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		ct := res.Header.Get("Content-Type")
+		expectedCt := "application/x-bittorrent"
+		if ct != expectedCt {
+			c.Logger.Errorf("Error while kinozal GET request. Wrong content type %s. Expected %s", ct, expectedCt)
+			// без сессии вместо торрента отдается html страница
+			if strings.HasPrefix(ct, "text/html") {
+				return nil, ErrLoggedOut
+			}
+			return nil, errors.New("wrong content type")
+		}
+		return io.ReadAll(res.Body)
+	})
 	if err != nil {
 		return nil, err
 	}
 	return &Element{Name: name, Torrent: bytes}, nil
 }
 
-func (c Client) Listing(ch chan int64, interval time.Duration) {
+// SetCookie меняет cookie для следующих запросов
+func (c *Client) SetCookie(cookie string) {
+	c.cookieMutex.Lock()
+	defer c.cookieMutex.Unlock()
+	c.Config.Cookie = cookie
+}
+
+func (c *Client) cookie() string {
+	c.cookieMutex.RLock()
+	defer c.cookieMutex.RUnlock()
+	return c.Config.Cookie
+}
+
+// Listing читает главную страницу. wait получает ошибку чтения и возвращает паузу до следующего,
+// так расписание учитывает перезагрузку конфигурации и сбои сайта. После отмены ctx чтение прекращается и ch закрывается
+func (c *Client) Listing(ctx context.Context, ch chan<- int64, wait func(err error) time.Duration) {
+	defer close(ch)
 	for {
 		c.Logger.Debugf("Read updates from Kinozal")
-		ids, err := c.GetRoot()
-		if err != nil {
-			time.Sleep(interval)
-			continue
-		}
+		ids, err := c.GetRoot(ctx)
 		for _, element := range ids {
-			ch <- element
+			select {
+			case ch <- element:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-time.After(wait(err)):
+		case <-ctx.Done():
+			return
 		}
-		time.Sleep(interval)
 	}
 }
 
-func (c Client) getDoc(url string) (*goquery.Document, error) {
-	body, err := c.getRequest(url)
-	if err != nil {
-		c.Logger.Error(err.Error())
-		return nil, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+// getDoc загружает страницу, s при наличии получает код ответа, время и ошибку
+func (c *Client) getDoc(ctx context.Context, url string, s *Scrape) (*goquery.Document, error) {
+	start := time.Now()
+	doc, err := withSession(ctx, c, func() (*goquery.Document, error) {
+		res, err := c.do(ctx, url, s)
 		if err != nil {
 			c.Logger.Error(err.Error())
+			return nil, err
 		}
-	}(body)
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				c.Logger.Error(err.Error())
+			}
+		}(res.Body)
 
-	return goquery.NewDocumentFromReader(body)
+		doc, err := goquery.NewDocumentFromReader(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		// без логина и пароля страницы читаются анонимно, форма входа в шапке для них обычна
+		if c.canLogin() && isLoginPage(doc) {
+			return nil, ErrLoggedOut
+		}
+		return doc, nil
+	})
+	s.done(url, start, err)
+	return doc, err
 }
 
-func (c Client) getRequest(url string) (io.ReadCloser, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// do выполняет GET запрос с текущей cookie, s получает код ответа. Редирект на страницу входа возвращается как ErrLoggedOut
+func (c *Client) do(ctx context.Context, url string, s *Scrape) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		c.Logger.Error(err.Error())
 		return nil, err
 	}
 
-	req.Header.Set("cookie", c.Config.Cookie)
+	req.Header.Set("cookie", c.cookie())
 	req.Header.Set("referer", c.Config.MainPageUrl)
-	req.Header.Set("user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/108.0.0.0 Safari/537.36")
+	req.Header.Set("user-agent", userAgent)
 
 	res, err := c.Config.HttpClient.Do(req)
 	if err != nil {
 		c.Logger.Error(err)
 		return nil, err
 	}
+	s.status(res.StatusCode)
 
 	if res.StatusCode < 200 || res.StatusCode > 399 {
+		_ = res.Body.Close()
 		c.Logger.Errorf("Error while kinozal GET request. Status code %d. URL %s", res.StatusCode, url)
-		return nil, errors.New("wrong status code")
+		return nil, &StatusError{Code: res.StatusCode, Url: url}
 	}
-
-	if strings.Contains(url, "://dl.") {
-		ct := res.Header.Get("Content-Type")
-		expectedCt := "application/x-bittorrent"
-		if ct != expectedCt {
-			c.Logger.Errorf("Error while kinozal GET request. Wrong content type %s. Expected %s", ct, expectedCt)
-			return nil, errors.New("wrong content type")
-		}
+	if loggedOut(res) {
+		_ = res.Body.Close()
+		return nil, ErrLoggedOut
 	}
 
-	return res.Body, nil
+	return res, nil
 }
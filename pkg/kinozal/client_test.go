@@ -2,11 +2,15 @@ package kinozal
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/sirupsen/logrus"
 )
 
 type HttpClientMock struct {
@@ -56,7 +60,7 @@ func TestClient_getRoot(t *testing.T) {
 			c := Client{
 				Config: tt.fields.Config,
 			}
-			if ids, err := c.GetRoot(); (err != nil) != tt.wantErr {
+			if ids, err := c.GetRoot(context.Background()); (err != nil) != tt.wantErr {
 				if len(ids) != 50 {
 					t.Errorf("getRoot() len(ids) = %v, want %v", len(ids), 50)
 				}
@@ -106,7 +110,7 @@ func TestClient_getTorrent(t *testing.T) {
 			c := Client{
 				Config: tt.fields.Config,
 			}
-			got, err := c.GetElement(tt.args.id)
+			got, err := c.GetElement(context.Background(), tt.args.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getTorrent() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -120,3 +124,39 @@ func TestClient_getTorrent(t *testing.T) {
 		})
 	}
 }
+
+type layoutMock struct{}
+
+func (layoutMock) Do(*http.Request) (*http.Response, error) {
+	body := `<html><body><div class="bx1"><a href="/browse.php">Раздачи</a></div></body></html>`
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+}
+
+func TestClient_scrapeReport(t *testing.T) {
+	samples := make([]Scrape, 0)
+	c := Client{
+		Config: ClientConfig{
+			HttpClient:  &HttpClientMock{},
+			MainPageUrl: "http://kinozal.tv",
+			OnScrape:    func(s Scrape) { samples = append(samples, s) },
+		},
+		Logger: logrus.New(),
+	}
+	if _, err := c.GetRoot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// таблицы раздач нет, так выглядит главная после смены верстки
+	c.Config.HttpClient = layoutMock{}
+	if _, err := c.GetRoot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("samples = %+v", samples)
+	}
+	if samples[0].Items == 0 || len(samples[0].Missing) != 0 || samples[0].Status != 200 {
+		t.Errorf("root sample = %+v", samples[0])
+	}
+	if samples[1].Items != 0 || len(samples[1].Missing) != 1 {
+		t.Errorf("changed layout sample = %+v", samples[1])
+	}
+}
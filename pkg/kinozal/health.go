@@ -0,0 +1,43 @@
+package kinozal
+
+import "time"
+
+// Scrape замер загрузки и разбора одной страницы. По нулю элементов или пропавшим селекторам видно, что сайт сменил верстку
+type Scrape struct {
+	// Page вид страницы: root, details
+	Page    string
+	Url     string
+	Status  int
+	Latency time.Duration
+	// Items сколько элементов разобрано со страницы
+	Items int
+	// Missing обязательные селекторы, которых не нашлось на странице
+	Missing []string
+	Err     error
+}
+
+func (s *Scrape) status(code int) {
+	if s != nil {
+		s.Status = code
+	}
+}
+
+// done учитывает запрос страницы, время ответа суммируется, если страница собирается из нескольких запросов
+func (s *Scrape) done(url string, start time.Time, err error) {
+	if s != nil {
+		s.Url = url
+		s.Latency += time.Since(start)
+		s.Err = err
+	}
+}
+
+func (s *Scrape) missing(selector string) {
+	s.Missing = append(s.Missing, selector)
+}
+
+// report передает замер в Config.OnScrape
+func (c *Client) report(s *Scrape) {
+	if c.Config.OnScrape != nil {
+		c.Config.OnScrape(*s)
+	}
+}
@@ -0,0 +1,118 @@
+package kinozal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/text/encoding/charmap"
+)
+
+var (
+	// ErrLoggedOut сессия истекла, а логин и пароль не заданы или вход не удался
+	ErrLoggedOut = errors.New("kinozal session expired")
+	// ErrLoginFailed сайт не выдал cookie сессии на логин и пароль
+	ErrLoginFailed = errors.New("kinozal login failed")
+)
+
+// Login входит по Config.Username и Config.Password, применяет новую cookie и передает ее в Config.OnLogin
+func (c *Client) Login(ctx context.Context) error {
+	if !c.canLogin() {
+		return ErrLoggedOut
+	}
+	// сайт в windows-1251, логин на кириллице иначе не совпадет
+	username, err := charmap.Windows1251.NewEncoder().String(c.Config.Username)
+	if err != nil {
+		return err
+	}
+	form := url.Values{
+		"username": {username},
+		"password": {c.Config.Password},
+		"returnto": {""},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Config.MainPageUrl+"/takelogin.php", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("referer", c.Config.MainPageUrl)
+	req.Header.Set("user-agent", userAgent)
+	// cookie сессии приходят вместе с редиректом, следовать ему нельзя
+	res, err := noRedirect(c.Config.HttpClient).Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+
+	parts := make([]string, 0, 2)
+	for _, cookie := range res.Cookies() {
+		if (cookie.Name == "uid" || cookie.Name == "pass") && cookie.Value != "" && cookie.Value != "deleted" {
+			parts = append(parts, cookie.Name+"="+cookie.Value)
+		}
+	}
+	if len(parts) != 2 {
+		return fmt.Errorf("%w: status %d", ErrLoginFailed, res.StatusCode)
+	}
+	session := strings.Join(parts, "; ")
+	c.SetCookie(session)
+	c.Logger.Infof("Logged in to Kinozal as %s", c.Config.Username)
+	if c.Config.OnLogin != nil {
+		c.Config.OnLogin(session)
+	}
+	return nil
+}
+
+// canLogin заданы логин и пароль
+func (c *Client) canLogin() bool {
+	return c.Config.Username != "" && c.Config.Password != ""
+}
+
+// relogin входит заново, если cookie не успели обновить в другой горутине после запроса с expired
+func (c *Client) relogin(ctx context.Context, expired string) error {
+	c.loginMutex.Lock()
+	defer c.loginMutex.Unlock()
+	if c.cookie() != expired {
+		return nil
+	}
+	c.Logger.Warnf("Kinozal session expired, logging in again")
+	return c.Login(ctx)
+}
+
+// withSession выполняет запрос и при ErrLoggedOut один раз повторяет его после входа
+func withSession[T any](ctx context.Context, c *Client, fn func() (T, error)) (T, error) {
+	cookie := c.cookie()
+	v, err := fn()
+	if !errors.Is(err, ErrLoggedOut) {
+		return v, err
+	}
+	if err = c.relogin(ctx, cookie); err != nil {
+		return v, err
+	}
+	return fn()
+}
+
+func noRedirect(client HttpClient) HttpClient {
+	hc, ok := client.(*http.Client)
+	if !ok {
+		return client
+	}
+	withoutRedirect := *hc
+	withoutRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &withoutRedirect
+}
+
+// loggedOut редирект на страницу входа
+func loggedOut(res *http.Response) bool {
+	return res.Request != nil && res.Request.URL != nil && strings.Contains(res.Request.URL.Path, "login")
+}
+
+// isLoginPage без сессии в шапке страницы форма входа вместо ссылки выхода
+func isLoginPage(doc *goquery.Document) bool {
+	return doc.Find("form[action*=takelogin], input[type=password]").Length() > 0
+}
@@ -0,0 +1,81 @@
+package kinozal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SessionMock отдает форму входа, пока в запросе нет cookie, выданной на takelogin.php
+type SessionMock struct {
+	logins int
+}
+
+func (c *SessionMock) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/takelogin.php" {
+		c.logins++
+		_ = req.ParseForm()
+		res := &http.Response{StatusCode: 302, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}
+		if req.PostForm.Get("username") == "user" && req.PostForm.Get("password") == "secret" {
+			res.Header["Set-Cookie"] = []string{"uid=1; path=/", "pass=new; path=/"}
+		}
+		return res, nil
+	}
+	if req.Header.Get("cookie") != "uid=1; pass=new" {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`<form action="/takelogin.php"><input type="password" name="password"></form>`)),
+			Header:     http.Header{"Content-Type": {"text/html"}},
+		}, nil
+	}
+	file, _ := os.Open("./main_page.thtml")
+	return &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bufio.NewReader(file)),
+		Header:     http.Header{"Content-Type": {"text/html"}},
+	}, nil
+}
+
+func TestClient_relogin(t *testing.T) {
+	mock := &SessionMock{}
+	saved := ""
+	c := &Client{
+		Config: ClientConfig{
+			HttpClient:  mock,
+			MainPageUrl: "http://kinozal.tv",
+			Cookie:      "uid=1; pass=old",
+			Username:    "user",
+			Password:    "secret",
+			OnLogin:     func(cookie string) { saved = cookie },
+		},
+		Logger: logrus.New(),
+	}
+	ids, err := c.GetRoot(context.Background())
+	if err != nil {
+		t.Fatalf("GetRoot() error = %v", err)
+	}
+	if len(ids) == 0 {
+		t.Errorf("GetRoot() returned no ids after login")
+	}
+	if mock.logins != 1 || saved != "uid=1; pass=new" || c.cookie() != saved {
+		t.Errorf("logins = %d, saved = %q, cookie = %q", mock.logins, saved, c.cookie())
+	}
+
+	// без логина и пароля страницы с формой входа читаются анонимно
+	anonymous := &SessionMock{}
+	c = &Client{Config: ClientConfig{HttpClient: anonymous, MainPageUrl: "http://kinozal.tv"}, Logger: logrus.New()}
+	if _, err = c.GetRoot(context.Background()); err != nil || anonymous.logins != 0 {
+		t.Errorf("anonymous GetRoot() error = %v, logins = %d", err, anonymous.logins)
+	}
+	c.Config.Username, c.Config.Password = "user", "wrong"
+	if err = c.Login(context.Background()); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("Login() error = %v, want ErrLoginFailed", err)
+	}
+}
@@ -1,33 +1,63 @@
 package lostfilm
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"makarov.dev/bot/pkg/polite"
 )
 
 type ClientConfig struct {
 	HttpClient  HttpClient
 	MainPageUrl string
 	Cookie      http.Cookie
+	// Username и Password включают повторный вход, когда сессия истекла
+	Username string
+	Password string
+	// OnLogin получает cookie после успешного входа, чтобы сессию можно было сохранить
+	OnLogin func(cookie http.Cookie)
+	// OnScrape получает замер после каждой разобранной страницы
+	OnScrape func(s Scrape)
 }
 
 type Client struct {
 	Config ClientConfig
 	Logger *logrus.Logger
+
+	// cookieMutex защищает Config.Cookie, который меняется при перезагрузке конфигурации и после входа
+	cookieMutex sync.RWMutex
+	// loginMutex не дает параллельным запросам входить одновременно
+	loginMutex sync.Mutex
 }
 
 type HttpClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// StatusError трекер ответил кодом ошибки
+type StatusError struct {
+	Code int
+	Url  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("wrong status code %d for %s", e.Code, e.Url)
+}
+
+// StatusCode код ответа, по нему планировщик опросов узнает ограничение частоты запросов
+func (e *StatusError) StatusCode() int {
+	return e.Code
+}
+
 type RootElement struct {
 	Page          string    // /series/Heels/season_1/episode_4/
 	Name          string    // Хилы
@@ -66,13 +96,28 @@ type FullItemTorrent struct {
 	Torrent     []byte `json:"-"`
 }
 
-func (c Client) GetRoot() ([]RootElement, error) {
-	doc, err := c.getDoc(c.Config.MainPageUrl + "/new")
+func (c *Client) GetRoot(ctx context.Context) ([]RootElement, error) {
+	return c.GetRootPage(ctx, 1)
+}
+
+// GetRootPage возвращает серии со страницы новинок, страницы нумеруются с 1
+func (c *Client) GetRootPage(ctx context.Context, page int) ([]RootElement, error) {
+	url := c.Config.MainPageUrl + "/new"
+	if page > 1 {
+		url += "/page_" + strconv.Itoa(page)
+	}
+	s := &Scrape{Page: "root"}
+	defer c.report(s)
+	// страница новинок редко меняется между опросами, сервер может ответить 304
+	doc, err := c.getDoc(polite.Conditional(ctx), url, s)
 	if err != nil {
 		c.Logger.Error(err.Error())
 		return nil, err
 	}
 	rows := doc.Find(".row")
+	if rows.Length() == 0 {
+		s.missing(".row")
+	}
 	r := make([]RootElement, 0, 15)
 	parseRow := func(i int, row *goquery.Selection) {
 		link, foundLink := row.Find("a").Eq(0).Attr("href")
@@ -105,18 +150,31 @@ func (c Client) GetRoot() ([]RootElement, error) {
 		})
 	}
 	rows.Each(parseRow)
+	s.Items = len(r)
+	if rows.Length() > 0 && len(r) == 0 {
+		s.missing(".row a[href]")
+	}
+	for _, e := range r {
+		if e.Name == "" {
+			s.missing(".name-ru")
+			break
+		}
+	}
 
 	return r, nil
 }
 
-func (c Client) GetEpisode(page string) (*Episode, error) {
-	doc, err := c.getDoc(c.Config.MainPageUrl + page)
+func (c *Client) GetEpisode(ctx context.Context, page string) (*Episode, error) {
+	s := &Scrape{Page: "episode"}
+	defer c.report(s)
+	doc, err := c.getDoc(ctx, c.Config.MainPageUrl+page, s)
 	if err != nil {
 		c.Logger.Error(err.Error())
 		return nil, err
 	}
 	onClick, found := doc.Find(".external-btn").Attr("onclick")
 	if !found {
+		s.missing(".external-btn")
 		return nil, nil
 	}
 
@@ -124,14 +182,18 @@ func (c Client) GetEpisode(page string) (*Episode, error) {
 	id, err := strconv.ParseInt(rawId, 10, 64)
 	if err != nil {
 		c.Logger.Error(err.Error())
+		s.Err = err
 		return nil, err
 	}
+	s.Items = 1
 
 	return &Episode{Id: id}, nil
 }
 
-func (c Client) GetTorrentRefs(episodeId int64) ([]TorrentRef, error) {
-	doc, err := c.getDoc(c.Config.MainPageUrl + "/v_search.php?a=" + strconv.FormatInt(episodeId, 10))
+func (c *Client) GetTorrentRefs(ctx context.Context, episodeId int64) ([]TorrentRef, error) {
+	s := &Scrape{Page: "torrent_refs"}
+	defer c.report(s)
+	doc, err := c.getDoc(ctx, c.Config.MainPageUrl+"/v_search.php?a="+strconv.FormatInt(episodeId, 10), s)
 	if err != nil {
 		c.Logger.Error(err.Error())
 		return nil, err
@@ -139,13 +201,24 @@ func (c Client) GetTorrentRefs(episodeId int64) ([]TorrentRef, error) {
 
 	trackUrl, exists := doc.Find("meta").Attr("content")
 	if !exists {
-		return nil, errors.New("track url not exists")
+		s.missing("meta[content]")
+		s.Err = errors.New("track url not exists")
+		return nil, s.Err
 	}
 	trackUrl = strings.Replace(trackUrl, "0; url=", "", -1)
 
-	doc, err = c.getDoc(trackUrl)
+	doc, err = c.getDoc(ctx, trackUrl, s)
+	if err != nil {
+		return nil, err
+	}
 
 	r := make([]TorrentRef, 0, 3)
+	defer func() {
+		s.Items = len(r)
+		if len(r) == 0 {
+			s.missing(".inner-box--item a[href]")
+		}
+	}()
 
 	nameFull := strings.TrimSpace(doc.Find(".inner-box--text").Text())
 	nameFull = strings.ReplaceAll(nameFull, "\t\t\t", " ")
@@ -169,28 +242,63 @@ func (c Client) GetTorrentRefs(episodeId int64) ([]TorrentRef, error) {
 	return r, nil
 }
 
-func (c Client) GetTorrent(url string) ([]byte, error) {
-	body, err := c.getRequest(url)
+func (c *Client) GetTorrent(ctx context.Context, url string) ([]byte, error) {
+	b, err := withSession(ctx, c, func() ([]byte, error) {
+		res, err := c.do(ctx, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		// без сессии вместо торрента отдается html страница
+		if strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+			return nil, ErrLoggedOut
+		}
+		return io.ReadAll(res.Body)
+	})
 	if err != nil {
 		c.Logger.Error(err.Error())
-		return nil, err
 	}
-	return ioutil.ReadAll(body)
+	return b, err
+}
+
+// SetCookie меняет cookie для следующих запросов
+func (c *Client) SetCookie(cookie http.Cookie) {
+	c.cookieMutex.Lock()
+	defer c.cookieMutex.Unlock()
+	c.Config.Cookie = cookie
 }
 
-func (c Client) Listing(ch chan RootElement, interval time.Duration) {
+func (c *Client) cookie() http.Cookie {
+	c.cookieMutex.RLock()
+	defer c.cookieMutex.RUnlock()
+	return c.Config.Cookie
+}
+
+// Listing читает страницу новых серий. wait получает ошибку чтения и возвращает паузу до следующего,
+// так расписание учитывает перезагрузку конфигурации и сбои сайта. После отмены ctx чтение прекращается и ch закрывается
+func (c *Client) Listing(ctx context.Context, ch chan<- RootElement, wait func(err error) time.Duration) {
+	defer close(ch)
 	for {
 		c.Logger.Debugf("Read updates from LostFilm")
-		elements, _ := c.GetRoot()
+		elements, err := c.GetRoot(ctx)
 		for _, element := range elements {
-			ch <- element
+			select {
+			case ch <- element:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-time.After(wait(err)):
+		case <-ctx.Done():
+			return
 		}
-		time.Sleep(interval)
 	}
 }
 
-func (c Client) getRequest(url string) (io.ReadCloser, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// do выполняет GET запрос с текущей cookie, s получает код ответа. Редирект на страницу входа возвращается как ErrLoggedOut
+func (c *Client) do(ctx context.Context, url string, s *Scrape) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		c.Logger.Error(err.Error())
 		return nil, err
@@ -199,29 +307,54 @@ func (c Client) getRequest(url string) (io.ReadCloser, error) {
 	if strings.HasPrefix(url, c.Config.MainPageUrl) {
 		req.Header.Set("referer", c.Config.MainPageUrl)
 	}
-	req.Header.Set("Cookie", c.Config.Cookie.Name+"="+c.Config.Cookie.Value)
+	cookie := c.cookie()
+	req.Header.Set("Cookie", cookie.Name+"="+cookie.Value)
 
 	res, err := c.Config.HttpClient.Do(req)
 	if err != nil {
 		c.Logger.Error(err.Error())
 		return nil, err
 	}
+	s.status(res.StatusCode)
+	// страницы с 4xx разбираются как раньше, ошибкой считаются только перегрузка и сбои сайта
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+		_ = res.Body.Close()
+		return nil, &StatusError{Code: res.StatusCode, Url: url}
+	}
+	if loggedOut(res) {
+		_ = res.Body.Close()
+		return nil, ErrLoggedOut
+	}
 
-	return res.Body, nil
+	return res, nil
 }
 
-func (c Client) getDoc(url string) (*goquery.Document, error) {
-	body, err := c.getRequest(url)
-	if err != nil {
-		c.Logger.Error(err.Error())
-		return nil, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+// getDoc загружает страницу, s при наличии получает код ответа, время и ошибку
+func (c *Client) getDoc(ctx context.Context, url string, s *Scrape) (*goquery.Document, error) {
+	start := time.Now()
+	doc, err := withSession(ctx, c, func() (*goquery.Document, error) {
+		res, err := c.do(ctx, url, s)
 		if err != nil {
 			c.Logger.Error(err.Error())
+			return nil, err
 		}
-	}(body)
+		defer func(Body io.ReadCloser) {
+			err := Body.Close()
+			if err != nil {
+				c.Logger.Error(err.Error())
+			}
+		}(res.Body)
 
-	return goquery.NewDocumentFromReader(body)
+		doc, err := goquery.NewDocumentFromReader(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		// с одной cookie форма входа на странице не признак истекшей сессии, войти все равно нельзя
+		if c.canLogin() && isLoginPage(doc) {
+			return nil, ErrLoggedOut
+		}
+		return doc, nil
+	})
+	s.done(url, start, err)
+	return doc, err
 }
@@ -2,6 +2,7 @@ package lostfilm
 
 import (
 	"bufio"
+	"context"
 	"github.com/sirupsen/logrus"
 	"io"
 	"net/http"
@@ -40,7 +41,7 @@ func (c *HttpClientMock) Do(req *http.Request) (*http.Response, error) {
 
 func TestGetRoot(t *testing.T) {
 	client := getClient()
-	r, err := client.GetRoot()
+	r, err := client.GetRoot(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -59,7 +60,7 @@ func TestGetRoot(t *testing.T) {
 
 func TestGetEpisode(t *testing.T) {
 	client := getClient()
-	r, err := client.GetEpisode("/series/Heels/season_1/episode_4/")
+	r, err := client.GetEpisode(context.Background(), "/series/Heels/season_1/episode_4/")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -73,7 +74,7 @@ func TestGetEpisode(t *testing.T) {
 
 func TestGetEpisodeWithMovie(t *testing.T) {
 	client := getClient()
-	e, err := client.GetEpisode("/movies/JurassicWorldDominion")
+	e, err := client.GetEpisode(context.Background(), "/movies/JurassicWorldDominion")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -87,7 +88,7 @@ func TestGetEpisodeWithMovie(t *testing.T) {
 
 func TestGetTorrentRef(t *testing.T) {
 	client := getClient()
-	r, err := client.GetTorrentRefs(611001004)
+	r, err := client.GetTorrentRefs(context.Background(), 611001004)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -101,7 +102,7 @@ func TestGetTorrentRef(t *testing.T) {
 
 func TestGetTorrent(t *testing.T) {
 	client := getClient()
-	r, err := client.GetTorrent("http://n.tracktor.site/td.php?s=G1RFzE%2F%2FDtWo0CJNsFptuIQwyTICEAoQF8rR%2Fvg0ONBTuhzMHaTPZo372ohX6P99NIGWP5plNOqcVtAh4GPYn9SpAPjkW86gdiqAk6z29yWC%2Bcmqpabd95%2ByeiAb8Rg%2B")
+	r, err := client.GetTorrent(context.Background(), "http://n.tracktor.site/td.php?s=G1RFzE%2F%2FDtWo0CJNsFptuIQwyTICEAoQF8rR%2Fvg0ONBTuhzMHaTPZo372ohX6P99NIGWP5plNOqcVtAh4GPYn9SpAPjkW86gdiqAk6z29yWC%2Bcmqpabd95%2ByeiAb8Rg%2B")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -113,19 +114,30 @@ func TestGetTorrent(t *testing.T) {
 func TestListing(t *testing.T) {
 	ch := make(chan RootElement)
 	client := getClient()
+	ctx, cancel := context.WithCancel(context.Background())
 
-	go client.Listing(ch, 1*time.Minute)
+	go client.Listing(ctx, ch, func(error) time.Duration { return 1 * time.Minute })
 
-	for i := range ch {
-		if i.Page != "" {
-			return
+	if i := <-ch; i.Page == "" {
+		t.Fatal("Empty page")
+	}
+	// после отмены чтение прекращается, не дожидаясь паузы, и канал закрывается
+	cancel()
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Listing not stopped")
 		}
 	}
 }
 
-func getClient() Client {
+func getClient() *Client {
 	cfg := ClientConfig{
 		HttpClient: &HttpClientMock{},
 	}
-	return Client{Config: cfg, Logger: logrus.New()}
+	return &Client{Config: cfg, Logger: logrus.New()}
 }
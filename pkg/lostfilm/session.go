@@ -0,0 +1,121 @@
+package lostfilm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const sessionCookieName = "lf_session"
+
+var (
+	// ErrLoggedOut сессия истекла, а логин и пароль не заданы или вход не удался
+	ErrLoggedOut = errors.New("lostfilm session expired")
+	// ErrLoginFailed сайт отклонил логин и пароль или запросил капчу
+	ErrLoginFailed = errors.New("lostfilm login failed")
+)
+
+type loginResponse struct {
+	Success     bool `json:"success"`
+	Error       int  `json:"error"`
+	NeedCaptcha bool `json:"need_captcha"`
+}
+
+// Login входит по Config.Username и Config.Password, применяет новую cookie и передает ее в Config.OnLogin
+func (c *Client) Login(ctx context.Context) error {
+	if !c.canLogin() {
+		return ErrLoggedOut
+	}
+	form := url.Values{
+		"act":  {"users"},
+		"type": {"login"},
+		"mail": {c.Config.Username},
+		"pass": {c.Config.Password},
+		"rem":  {"1"},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Config.MainPageUrl+"/ajaxik.users.php", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("referer", c.Config.MainPageUrl+"/login")
+	res, err := c.Config.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	answer := loginResponse{}
+	if err = json.NewDecoder(res.Body).Decode(&answer); err != nil {
+		return fmt.Errorf("%w: %s", ErrLoginFailed, err.Error())
+	}
+	if !answer.Success {
+		return fmt.Errorf("%w: error %d, captcha %t", ErrLoginFailed, answer.Error, answer.NeedCaptcha)
+	}
+	name := c.cookie().Name
+	if name == "" {
+		name = sessionCookieName
+	}
+	for _, cookie := range res.Cookies() {
+		if cookie.Name != name || cookie.Value == "" {
+			continue
+		}
+		session := http.Cookie{Name: cookie.Name, Value: cookie.Value}
+		c.SetCookie(session)
+		c.Logger.Infof("Logged in to LostFilm as %s", c.Config.Username)
+		if c.Config.OnLogin != nil {
+			c.Config.OnLogin(session)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: no %s cookie in response", ErrLoginFailed, name)
+}
+
+// canLogin заданы логин и пароль
+func (c *Client) canLogin() bool {
+	return c.Config.Username != "" && c.Config.Password != ""
+}
+
+// relogin входит заново, если cookie не успели обновить в другой горутине после запроса с expired
+func (c *Client) relogin(ctx context.Context, expired http.Cookie) error {
+	c.loginMutex.Lock()
+	defer c.loginMutex.Unlock()
+	if current := c.cookie(); current.Name != expired.Name || current.Value != expired.Value {
+		return nil
+	}
+	c.Logger.Warnf("LostFilm session expired, logging in again")
+	return c.Login(ctx)
+}
+
+// withSession выполняет запрос и при ErrLoggedOut один раз повторяет его после входа
+func withSession[T any](ctx context.Context, c *Client, fn func() (T, error)) (T, error) {
+	cookie := c.cookie()
+	v, err := fn()
+	if !errors.Is(err, ErrLoggedOut) {
+		return v, err
+	}
+	if err = c.relogin(ctx, cookie); err != nil {
+		return v, err
+	}
+	return fn()
+}
+
+// loggedOut редирект на страницу входа
+func loggedOut(res *http.Response) bool {
+	return res.Request != nil && res.Request.URL != nil && strings.HasPrefix(res.Request.URL.Path, "/login")
+}
+
+// isLoginPage вместо запрошенной страницы пришла форма входа или переадресация на нее
+func isLoginPage(doc *goquery.Document) bool {
+	if doc.Find("input[type=password]").Length() > 0 {
+		return true
+	}
+	refresh, _ := doc.Find("meta[http-equiv=refresh]").Attr("content")
+	return strings.Contains(refresh, "/login")
+}
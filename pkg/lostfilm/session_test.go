@@ -0,0 +1,115 @@
+package lostfilm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SessionMock переадресует на /login, пока в запросе нет cookie, выданной на ajaxik.users.php
+type SessionMock struct {
+	logins int
+}
+
+func (c *SessionMock) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/ajaxik.users.php" {
+		c.logins++
+		_ = req.ParseForm()
+		if req.PostForm.Get("mail") != "user@example.com" || req.PostForm.Get("pass") != "secret" {
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"error":3}`))}, nil
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader(`{"name":"user","success":true,"result":"ok"}`)),
+			Header:     http.Header{"Set-Cookie": {"lf_session=new; path=/"}},
+		}, nil
+	}
+	if req.Header.Get("Cookie") != "lf_session=new" {
+		login, _ := url.Parse("https://www.lostfilm.tv/login")
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader("<html></html>")),
+			Request:    &http.Request{URL: login},
+		}, nil
+	}
+	file, _ := os.Open("./root_page.thtml")
+	return &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bufio.NewReader(file)),
+		Request:    req,
+	}, nil
+}
+
+// LoginFormMock отдает главную страницу с формой входа, как при истекшей сессии
+type LoginFormMock struct{}
+
+func (c *LoginFormMock) Do(req *http.Request) (*http.Response, error) {
+	page, err := os.ReadFile("./root_page.thtml")
+	if err != nil {
+		return nil, err
+	}
+	page = append(page, `<form action="/login"><input type="password" name="pass"></form>`...)
+	return &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewReader(page)),
+		Request:    req,
+	}, nil
+}
+
+func TestClient_relogin(t *testing.T) {
+	mock := &SessionMock{}
+	var saved http.Cookie
+	c := &Client{
+		Config: ClientConfig{
+			HttpClient:  mock,
+			MainPageUrl: "https://www.lostfilm.tv",
+			Cookie:      http.Cookie{Name: "lf_session", Value: "old"},
+			Username:    "user@example.com",
+			Password:    "secret",
+			OnLogin:     func(cookie http.Cookie) { saved = cookie },
+		},
+		Logger: logrus.New(),
+	}
+	r, err := c.GetRoot(context.Background())
+	if err != nil {
+		t.Fatalf("GetRoot() error = %v", err)
+	}
+	if len(r) != 15 {
+		t.Errorf("GetRoot() len = %d after login", len(r))
+	}
+	if mock.logins != 1 || saved.Value != "new" || c.cookie().Value != "new" {
+		t.Errorf("logins = %d, saved = %v, cookie = %v", mock.logins, saved, c.cookie())
+	}
+
+	// без логина и пароля истекшая сессия возвращается как ошибка
+	c = &Client{Config: ClientConfig{HttpClient: &SessionMock{}, MainPageUrl: "https://www.lostfilm.tv"}, Logger: logrus.New()}
+	if _, err = c.GetRoot(context.Background()); !errors.Is(err, ErrLoggedOut) {
+		t.Errorf("GetRoot() error = %v, want ErrLoggedOut", err)
+	}
+	c.Config.Username, c.Config.Password = "user@example.com", "wrong"
+	if err = c.Login(context.Background()); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("Login() error = %v, want ErrLoginFailed", err)
+	}
+
+	// с одной cookie форма входа на странице не считается истекшей сессией
+	c = &Client{
+		Config: ClientConfig{
+			HttpClient:  &LoginFormMock{},
+			MainPageUrl: "https://www.lostfilm.tv",
+			Cookie:      http.Cookie{Name: "lf_session", Value: "manual"},
+		},
+		Logger: logrus.New(),
+	}
+	if r, err = c.GetRoot(context.Background()); err != nil || len(r) != 15 {
+		t.Errorf("cookie only GetRoot() len = %d, error = %v", len(r), err)
+	}
+}
@@ -0,0 +1,110 @@
+package polite
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// maxPages сколько страниц списков помнит кэш условных запросов
+const maxPages = 256
+
+type conditionalKey struct{}
+
+// Conditional помечает запросы страниц списков: Transport повторяет их с If-None-Match и If-Modified-Since,
+// а на 304 отдает сохраненную страницу
+func Conditional(ctx context.Context) context.Context {
+	return context.WithValue(ctx, conditionalKey{}, true)
+}
+
+func isConditional(ctx context.Context) bool {
+	conditional, _ := ctx.Value(conditionalKey{}).(bool)
+	return conditional
+}
+
+type page struct {
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+// pageCache последние ответы страниц списков с валидаторами. Ключ включает cookie, чтобы не отдать страницу другой сессии
+type pageCache struct {
+	mutex sync.Mutex
+	pages map[string]page
+}
+
+func pageKey(req *http.Request) string {
+	return req.URL.String() + "\n" + req.Header.Get("Cookie")
+}
+
+// prepare добавляет валидаторы сохраненной страницы
+func (c *pageCache) prepare(req *http.Request) {
+	c.mutex.Lock()
+	p, found := c.pages[pageKey(req)]
+	c.mutex.Unlock()
+	if !found {
+		return
+	}
+	if p.etag != "" && req.Header.Get("If-None-Match") == "" {
+		req.Header.Set("If-None-Match", p.etag)
+	}
+	if p.lastModified != "" && req.Header.Get("If-Modified-Since") == "" {
+		req.Header.Set("If-Modified-Since", p.lastModified)
+	}
+}
+
+// response на 304 подменяет ответ сохраненной страницей, ответ 200 с валидаторами сохраняет
+func (c *pageCache) response(req *http.Request, res *http.Response) (*http.Response, error) {
+	key := pageKey(req)
+	switch {
+	case res.StatusCode == http.StatusNotModified:
+		c.mutex.Lock()
+		p, found := c.pages[key]
+		c.mutex.Unlock()
+		if !found {
+			return res, nil
+		}
+		_ = res.Body.Close()
+		return &http.Response{
+			Status:     "200 OK",
+			StatusCode: http.StatusOK,
+			Proto:      res.Proto,
+			ProtoMajor: res.ProtoMajor,
+			ProtoMinor: res.ProtoMinor,
+			Header:     p.header.Clone(),
+			Body:       io.NopCloser(bytes.NewReader(p.body)),
+			Request:    res.Request,
+		}, nil
+	case res.StatusCode == http.StatusOK && (res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""):
+		body, err := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		c.mutex.Lock()
+		if c.pages == nil {
+			c.pages = make(map[string]page)
+		}
+		if _, found := c.pages[key]; !found && len(c.pages) >= maxPages {
+			for k := range c.pages {
+				delete(c.pages, k)
+				break
+			}
+		}
+		c.pages[key] = page{
+			etag:         res.Header.Get("ETag"),
+			lastModified: res.Header.Get("Last-Modified"),
+			header:       res.Header.Clone(),
+			body:         body,
+		}
+		c.mutex.Unlock()
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		return res, nil
+	default:
+		return res, nil
+	}
+}
@@ -0,0 +1,266 @@
+// Package polite вежливый обход сайтов: ограничение частоты и числа одновременных запросов к хосту,
+// ожидание по Retry-After, повторы после временных сбоев и условные запросы страниц списков
+package polite
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Transport http.RoundTripper поверх Base. Нулевые ограничения отключают соответствующую защиту
+type Transport struct {
+	Base http.RoundTripper
+	// Rate запросов в секунду к одному хосту, Burst сколько запросов можно сделать сразу сверх Rate
+	Rate  float64
+	Burst int
+	// MaxConcurrent запросов к одному хосту одновременно. Место освобождается, когда закрыто тело ответа
+	MaxConcurrent int
+	// MaxRetries повторы идемпотентных запросов после сетевой ошибки, 429 и 502-504
+	MaxRetries int
+	// RetryBackoff пауза перед первым повтором, удваивается с каждым следующим
+	RetryBackoff time.Duration
+	// MaxRetryAfter самое долгое ожидание по Retry-After, ответ с большим ожиданием возвращается как есть
+	MaxRetryAfter time.Duration
+	// Timeout одной попытки вместе с чтением тела. Таймаут http.Client ограничил бы и ожидание лимитов с повторами
+	Timeout time.Duration
+	// OnRetry вызывается перед каждым повтором, reason - код ответа или error
+	OnRetry func(host string, attempt int, reason string)
+
+	mutex sync.Mutex
+	hosts map[string]*host
+	pages pageCache
+}
+
+// host ограничения одного хоста
+type host struct {
+	limiter *rate.Limiter
+	slots   chan struct{}
+	// pausedUntil хост просил подождать через Retry-After
+	pausedUntil time.Time
+	mutex       sync.Mutex
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := t.host(req.URL.Host)
+	conditional := isConditional(req.Context())
+	if conditional {
+		// RoundTripper не должен менять запрос вызывающего
+		req = req.Clone(req.Context())
+		t.pages.prepare(req)
+	}
+	for attempt := 0; ; attempt++ {
+		res, err := t.attempt(req, h)
+		wait, retry := t.retryAfter(req, res, err, attempt)
+		if !retry {
+			if conditional && err == nil {
+				return t.pages.response(req, res)
+			}
+			return res, err
+		}
+		reason := "error"
+		if res != nil {
+			reason = strconv.Itoa(res.StatusCode)
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+			_ = res.Body.Close()
+		}
+		if t.OnRetry != nil {
+			t.OnRetry(req.URL.Host, attempt+1, reason)
+		}
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			next := req.Clone(req.Context())
+			if next.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+			req = next
+		}
+	}
+}
+
+// attempt одна попытка с ожиданием лимитов хоста
+func (t *Transport) attempt(req *http.Request, h *host) (*http.Response, error) {
+	ctx := req.Context()
+	if err := sleep(ctx, time.Until(h.paused())); err != nil {
+		return nil, err
+	}
+	if err := h.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	release := func() {}
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-h.slots }
+	}
+	cancel := context.CancelFunc(func() {})
+	if t.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		req = req.WithContext(ctx)
+	}
+	res, err := t.base().RoundTrip(req)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	// хост попросил подождать, остальные запросы к нему тоже ждут
+	if wait, ok := retryAfterHeader(res); ok && (res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable) {
+		h.pause(min(wait, t.MaxRetryAfter))
+	}
+	res.Body = &releaseBody{ReadCloser: res.Body, release: func() {
+		cancel()
+		release()
+	}}
+	return res, nil
+}
+
+// retryAfter решает, нужен ли повтор, и возвращает паузу перед ним
+func (t *Transport) retryAfter(req *http.Request, res *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= t.MaxRetries || req.Context().Err() != nil || !idempotent(req) {
+		return 0, false
+	}
+	if err == nil && !transient(res.StatusCode) {
+		return 0, false
+	}
+	if err != nil && errors.Is(err, context.Canceled) {
+		return 0, false
+	}
+	backoff := t.RetryBackoff << attempt
+	if backoff > 0 {
+		// разброс, чтобы параллельные запросы не повторялись одновременно
+		backoff += rand.N(backoff/2 + 1)
+	}
+	if res != nil {
+		if wait, ok := retryAfterHeader(res); ok {
+			if wait > t.MaxRetryAfter {
+				return 0, false
+			}
+			backoff = max(backoff, wait)
+		}
+	}
+	return backoff, true
+}
+
+func (t *Transport) host(name string) *host {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.hosts == nil {
+		t.hosts = make(map[string]*host)
+	}
+	h, found := t.hosts[name]
+	if !found {
+		limit := rate.Inf
+		if t.Rate > 0 {
+			limit = rate.Limit(t.Rate)
+		}
+		h = &host{limiter: rate.NewLimiter(limit, max(t.Burst, 1))}
+		if t.MaxConcurrent > 0 {
+			h.slots = make(chan struct{}, t.MaxConcurrent)
+		}
+		t.hosts[name] = h
+	}
+	return h
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (h *host) pause(wait time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if until := time.Now().Add(wait); until.After(h.pausedUntil) {
+		h.pausedUntil = until
+	}
+}
+
+func (h *host) paused() time.Time {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.pausedUntil
+}
+
+// releaseBody освобождает место хоста при закрытии или дочитывании тела ответа
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// retryAfterHeader пауза из Retry-After в секундах или датой
+func retryAfterHeader(res *http.Response) (time.Duration, bool) {
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+func transient(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// idempotent повторять можно только запросы без побочных эффектов, тело которых можно прочитать заново
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	default:
+		return false
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package polite
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, client *http.Client, ctx context.Context, url string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return res, string(body)
+}
+
+func TestTransport_retry(t *testing.T) {
+	var calls atomic.Int32
+	var retryAfter atomic.Value
+	retryAfter.Store("0")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", retryAfter.Load().(string))
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	retries := make([]string, 0)
+	tr := &Transport{MaxRetries: 3, RetryBackoff: time.Millisecond, MaxRetryAfter: time.Second,
+		OnRetry: func(_ string, _ int, reason string) { retries = append(retries, reason) }}
+	client := &http.Client{Transport: tr}
+	res, body := get(t, client, context.Background(), srv.URL)
+	if res.StatusCode != http.StatusOK || body != "ok" {
+		t.Fatalf("status = %d, body = %q", res.StatusCode, body)
+	}
+	if strings.Join(retries, ",") != "502,429" {
+		t.Errorf("retries = %v", retries)
+	}
+
+	// POST не повторяется
+	calls.Store(1)
+	res, err := client.Post(srv.URL, "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests || calls.Load() != 2 {
+		t.Errorf("POST status = %d, calls = %d", res.StatusCode, calls.Load())
+	}
+	// ответ с Retry-After дольше MaxRetryAfter возвращается как есть
+	retryAfter.Store("120")
+	calls.Store(1)
+	if res, _ = get(t, client, context.Background(), srv.URL); res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status with long Retry-After = %d", res.StatusCode)
+	}
+}
+
+func TestTransport_limits(t *testing.T) {
+	var current, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		current.Add(-1)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{Rate: 50, Burst: 1, MaxConcurrent: 2}}
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, client, context.Background(), srv.URL)
+		}()
+	}
+	wg.Wait()
+	if peak.Load() > 2 {
+		t.Errorf("concurrent requests = %d, want at most 2", peak.Load())
+	}
+	// шесть запросов при 50 в секунду без запаса занимают не меньше 5 интервалов по 20ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("6 requests took %s, rate limit not applied", elapsed)
+	}
+}
+
+func TestTransport_conditional(t *testing.T) {
+	var notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("listing"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+	ctx := Conditional(context.Background())
+	for i := 0; i < 2; i++ {
+		res, body := get(t, client, ctx, srv.URL)
+		if res.StatusCode != http.StatusOK || body != "listing" {
+			t.Fatalf("request %d status = %d, body = %q", i, res.StatusCode, body)
+		}
+	}
+	if notModified.Load() != 1 {
+		t.Errorf("304 responses = %d, want 1", notModified.Load())
+	}
+	// без пометки запрос идет без валидаторов
+	if _, body := get(t, client, context.Background(), srv.URL); body != "listing" || notModified.Load() != 1 {
+		t.Errorf("unconditional request body = %q, 304 responses = %d", body, notModified.Load())
+	}
+}
@@ -0,0 +1,86 @@
+package proxypool
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// connectDialer соединяется через HTTP прокси методом CONNECT
+type connectDialer struct {
+	addr    string
+	user    *url.Userinfo
+	forward *unreachableDialer
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, network, d.addr)
+	if err != nil {
+		return nil, err
+	}
+	// рукопожатие прерывается вместе с ctx
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.user != nil {
+		password, _ := d.user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy connect %w", ctxErr(ctx, err))
+	}
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy connect %w", ctxErr(ctx, err))
+	}
+	// тело ответа не читается: после 200 в соединении уже данные цели
+	if res.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		err := fmt.Errorf("proxy connect to %s status %s", addr, res.Status)
+		if res.StatusCode == http.StatusProxyAuthRequired {
+			// прокси не пускает с этими учетными данными, пробуем следующий
+			return nil, &unreachableError{err: err}
+		}
+		return nil, err
+	}
+	if !stop() {
+		_ = conn.Close()
+		return nil, ctx.Err()
+	}
+	if reader.Buffered() > 0 {
+		// цель ответила раньше, чем был прочитан ответ прокси
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,150 @@
+// Package schedule расписание периодических опросов: интервал или cron выражение, случайный разброс,
+// тихие часы и увеличение паузы после ошибок
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// Spec момент следующего опроса после заданного времени
+type Spec interface {
+	Next(t time.Time) time.Time
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// Parse разбирает интервал вида 5m или cron выражение из пяти полей, например */5 * * * *, а также @hourly и @every 5m
+func Parse(spec string) (Spec, error) {
+	spec = strings.TrimSpace(spec)
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("interval %s must be positive", spec)
+		}
+		return every(d), nil
+	}
+	s, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q is neither interval nor cron expression %w", spec, err)
+	}
+	return s, nil
+}
+
+// QuietHours время суток, в которое опросы не выполняются. Интервал может переходить через полночь
+type QuietHours struct {
+	// From и To смещение от начала суток
+	From time.Duration
+	To   time.Duration
+}
+
+// ParseQuietHours разбирает интервал вида 01:00-07:00, пустая строка отключает тихие часы
+func ParseQuietHours(s string) (*QuietHours, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	from, to, found := strings.Cut(s, "-")
+	if !found {
+		return nil, fmt.Errorf("quiet hours %s must be in HH:MM-HH:MM format", s)
+	}
+	q := &QuietHours{}
+	var err error
+	if q.From, err = parseClock(from); err != nil {
+		return nil, err
+	}
+	if q.To, err = parseClock(to); err != nil {
+		return nil, err
+	}
+	if q.From == q.To {
+		return nil, fmt.Errorf("quiet hours %s are empty", s)
+	}
+	return q, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("quiet hours time %s must be in HH:MM format", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// End конец тихих часов, в которые попадает t, или t, если оно вне тихих часов
+func (q *QuietHours) End(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	clock := t.Sub(midnight)
+	switch {
+	case q.From < q.To && clock >= q.From && clock < q.To:
+		return midnight.Add(q.To)
+	case q.From > q.To && clock >= q.From:
+		return midnight.AddDate(0, 0, 1).Add(q.To)
+	case q.From > q.To && clock < q.To:
+		return midnight.Add(q.To)
+	default:
+		return t
+	}
+}
+
+// Schedule расписание опросов одного провайдера
+type Schedule struct {
+	Spec Spec
+	// Jitter случайная добавка к каждой паузе в пределах [0, Jitter)
+	Jitter time.Duration
+	// Quiet тихие часы, nil если не заданы
+	Quiet *QuietHours
+	// MaxBackoff предел паузы после ошибок подряд, 0 отключает увеличение паузы
+	MaxBackoff time.Duration
+}
+
+// Delay пауза от now до следующего опроса. Каждая ошибка из failures удваивает паузу по расписанию до MaxBackoff,
+// опрос в тихие часы переносится на их конец
+func (s *Schedule) Delay(now time.Time, failures int) time.Duration {
+	next := s.Spec.Next(now)
+	base := next.Sub(now)
+	delay := base
+	for i := 0; i < failures && delay < s.MaxBackoff; i++ {
+		delay = min(delay*2, s.MaxBackoff)
+	}
+	if s.Quiet != nil {
+		delay = s.Quiet.End(now.Add(delay)).Sub(now)
+	}
+	if s.Jitter > 0 {
+		delay += rand.N(s.Jitter)
+	}
+	return delay
+}
+
+// statusCoder ошибка клиента трекера с кодом ответа
+type statusCoder interface {
+	StatusCode() int
+}
+
+// Backoff считает ошибки опросов подряд. Нулевое значение готово к использованию
+type Backoff struct {
+	failures int
+}
+
+// Record учитывает результат опроса и возвращает вес ошибок подряд для Schedule.Delay.
+// Ответ 429 весит вдвое больше остальных ошибок, удачный опрос обнуляет счетчик
+func (b *Backoff) Record(err error) int {
+	var status statusCoder
+	switch {
+	case err == nil:
+		b.failures = 0
+	case errors.As(err, &status) && status.StatusCode() == http.StatusTooManyRequests:
+		b.failures += 2
+	default:
+		b.failures++
+	}
+	return b.failures
+}
@@ -0,0 +1,108 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type statusError int
+
+func (e statusError) Error() string {
+	return "wrong status code"
+}
+
+func (e statusError) StatusCode() int {
+	return int(e)
+}
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 2, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"90s", now.Add(90 * time.Second)},
+		{"*/5 * * * *", time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+		}
+		if got := s.Next(now); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next() = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+	for _, spec := range []string{"", "-1m", "every minute", "* * *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) error = nil", spec)
+		}
+	}
+}
+
+func TestQuietHours_End(t *testing.T) {
+	night, err := ParseQuietHours("23:00-07:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(h, m int) time.Time { return time.Date(2024, 5, 1, h, m, 0, 0, time.UTC) }
+	tests := []struct {
+		t    time.Time
+		want time.Time
+	}{
+		{day(23, 30), time.Date(2024, 5, 2, 7, 0, 0, 0, time.UTC)},
+		{day(3, 0), day(7, 0)},
+		{day(7, 0), day(7, 0)},
+		{day(12, 0), day(12, 0)},
+	}
+	for _, tt := range tests {
+		if got := night.End(tt.t); !got.Equal(tt.want) {
+			t.Errorf("End(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+	for _, s := range []string{"23:00", "25:00-07:00", "07:00-07:00"} {
+		if _, err := ParseQuietHours(s); err == nil {
+			t.Errorf("ParseQuietHours(%q) error = nil", s)
+		}
+	}
+	if q, err := ParseQuietHours(""); q != nil || err != nil {
+		t.Errorf("ParseQuietHours(\"\") = %v, %v", q, err)
+	}
+}
+
+func TestSchedule_Delay(t *testing.T) {
+	spec, _ := Parse("1m")
+	quiet, _ := ParseQuietHours("01:00-07:00")
+	s := &Schedule{Spec: spec, Quiet: quiet, MaxBackoff: 10 * time.Minute}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	b := &Backoff{}
+	if d := s.Delay(now, b.Record(nil)); d != time.Minute {
+		t.Errorf("Delay() = %s, want 1m", d)
+	}
+	if d := s.Delay(now, b.Record(errors.New("timeout"))); d != 2*time.Minute {
+		t.Errorf("Delay() after error = %s, want 2m", d)
+	}
+	if d := s.Delay(now, b.Record(statusError(429))); d != 8*time.Minute {
+		t.Errorf("Delay() after 429 = %s, want 8m", d)
+	}
+	if d := s.Delay(now, b.Record(errors.New("timeout"))); d != 10*time.Minute {
+		t.Errorf("Delay() = %s, want MaxBackoff", d)
+	}
+	if d := s.Delay(now, b.Record(nil)); d != time.Minute {
+		t.Errorf("Delay() after success = %s, want 1m", d)
+	}
+	// опрос в 00:59 попадает в тихие часы и переносится на 07:00
+	if d := s.Delay(time.Date(2024, 5, 1, 0, 59, 30, 0, time.UTC), 0); d != 6*time.Hour+30*time.Second {
+		t.Errorf("Delay() in quiet hours = %s", d)
+	}
+
+	s.Jitter = time.Minute
+	for i := 0; i < 20; i++ {
+		if d := s.Delay(now, 0); d < time.Minute || d >= 2*time.Minute {
+			t.Fatalf("Delay() with jitter = %s", d)
+		}
+	}
+}